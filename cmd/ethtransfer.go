@@ -1,39 +1,63 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
-	"log"
+	"fmt"
 	"math/big"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
 	ee "github.com/primev/preconf_blob_bidder/core/eth"
+	"github.com/primev/preconf_blob_bidder/core/logging"
 	bb "github.com/primev/preconf_blob_bidder/core/mevcommit"
 )
 
-func sendTransfer() {
-	endpoint := flag.String("endpoint", "", "The Ethereum client endpoint")
-	privateKeyHex := flag.String("privatekey", "", "The private key in hex format")
-	flag.Parse()
-	if *endpoint == "" {
-		log.Fatal("Endpoint is required. Use the -endpoint flag to provide it.")
+// sendTransfer sends an ETH transfer from the first sending account to itself through the first rpc endpoint.
+func sendTransfer(args []string) error {
+	var value, data *string
+	var gasLimit *uint64
+	cfg, err := loadConfig("transfer", args, func(fs *flag.FlagSet) {
+		value = fs.String("value", "100000", "Amount to transfer in wei")
+		gasLimit = fs.Uint64("gas-limit", 3000000, "Gas limit of the transfer")
+		data = fs.String("data", "0x4cdceb20", "Hex data of the transfer")
+	})
+	if err != nil {
+		return err
+	}
+	if err := errors.Join(cfg.Require("rpc_endpoints"), cfg.RequireAccounts()); err != nil {
+		return err
 	}
 
-	// Start Client
-	client, err := bb.NewGethClient(*endpoint)
+	amount, ok := new(big.Int).SetString(*value, 10)
+	if !ok {
+		return fmt.Errorf("invalid value %q", *value)
+	}
+	input, err := hexutil.Decode(*data)
 	if err != nil {
-		log.Fatalf("Failed to connect to client: %v", err)
+		return fmt.Errorf("invalid data: %w", err)
 	}
 
-	// Authenticate address
-	authAcct, err := bb.AuthenticateAddress(*privateKeyHex, client)
+	accounts, err := loadAccounts(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+
+	// Start Client
+	endpoint := cfg.RPCEndpoints[0]
+	client, err := bb.NewGethClient(endpoint)
 	if err != nil {
-		log.Fatalf("Failed to authenticate private key: %v", err)
+		return fmt.Errorf("failed to connect to client: %w", err)
 	}
+	defer client.Close()
 
 	// Send ETH Transfer
-	txHash, err := ee.SelfETHTransfer(client, *authAcct, big.NewInt(100000), 3000000, []byte{0x4c, 0xdc, 0xeb, 0x20})
+	txHash, err := ee.SelfETHTransfer(client, ee.NewRawTxSubmitter(endpoint), accounts[0], amount, *gasLimit, input)
 	if err != nil {
-		log.Fatalf("Failed to send transaction: %v", err)
+		return fmt.Errorf("failed to send transaction: %w", err)
 	}
 
-	log.Printf("tx sent: %s", txHash)
+	log.Info("tx sent", logging.KeyTxHash, txHash, logging.KeyAccount, accounts[0].Address)
+	return nil
 }
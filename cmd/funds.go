@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// deposit deposits into a bidding window from the bidder node's account.
+func deposit(args []string) error {
+	var amount *float64
+	var window *uint64
+	cfg, err := loadConfig("deposit", args, func(fs *flag.FlagSet) {
+		amount = fs.Float64("amount", 0, "Amount to deposit in ETH")
+		window = fs.Uint64("window", 0, "Bidding window to deposit into (0 for the current window)")
+	})
+	if err != nil {
+		return err
+	}
+	wei := etherToWei(*amount)
+	if wei == nil {
+		return errors.New("use the amount flag to provide a positive deposit")
+	}
+
+	bidderClient, err := dialBidder(cfg)
+	if err != nil {
+		return err
+	}
+	defer bidderClient.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.RPCTimeout)
+	defer cancel()
+	deposited, depositWindow, err := bidderClient.Deposit(ctx, wei, *window)
+	if err != nil {
+		return err
+	}
+	log.Info("deposit successful", "amount", weiToEther(deposited), "window", depositWindow)
+	return nil
+}
+
+// withdraw withdraws the deposit of a settled bidding window to the bidder node's account.
+func withdraw(args []string) error {
+	var window *uint64
+	cfg, err := loadConfig("withdraw", args, func(fs *flag.FlagSet) {
+		window = fs.Uint64("window", 0, "Bidding window to withdraw from")
+	})
+	if err != nil {
+		return err
+	}
+	if *window == 0 {
+		return errors.New("use the window flag to provide the window to withdraw from")
+	}
+
+	bidderClient, err := dialBidder(cfg)
+	if err != nil {
+		return err
+	}
+	defer bidderClient.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.RPCTimeout)
+	defer cancel()
+	withdrawn, withdrawWindow, err := bidderClient.Withdraw(ctx, *window)
+	if err != nil {
+		return err
+	}
+	log.Info("withdrawal successful", "amount", weiToEther(withdrawn), "window", withdrawWindow)
+	return nil
+}
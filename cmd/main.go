@@ -0,0 +1,126 @@
+// Command bidder sends blob transactions with mev-commit preconfirmation bids and provides the tools
+// around it, all sharing one config file, environment and flag set.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/primev/preconf_blob_bidder/core/config"
+	"github.com/primev/preconf_blob_bidder/core/logging"
+)
+
+// command is a subcommand of the binary.
+type command struct {
+	name  string                    // The name given as first argument.
+	usage string                    // One line describing the command.
+	run   func(args []string) error // Runs the command with the arguments after its name.
+}
+
+// commands lists the subcommands in the order they are shown in the usage.
+var commands = []command{
+	{"send-blob", "Send blob transactions with preconfirmation bids every block", sendBlob},
+	{"transfer", "Send an ETH transfer to the sending account itself", sendTransfer},
+	{"monitor", "Record the blob transactions of the mempool and their inclusion in the data folder", monitor},
+	{"deposit", "Deposit into a bidding window through the bidder node", deposit},
+	{"withdraw", "Withdraw the deposit of a settled bidding window through the bidder node", withdraw},
+	{"providers", "List the registered preconfirmation providers and their stake", providers},
+	{"status", "Show the bidder node, deposit, rpc endpoints and sending accounts", status},
+	{"report", "Report the L1 fees and bid payments of the sent transactions per tx, day or provider", report},
+}
+
+var (
+	// errConfigPrinted is returned by loadConfig after printing the config for -print-config.
+	errConfigPrinted = errors.New("config printed")
+
+	// errUsage is returned by loadConfig when the flags cannot be parsed, after the flag set printed the problem.
+	errUsage = errors.New("invalid flags")
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		err := cmd.run(os.Args[2:])
+		switch {
+		case err == nil, errors.Is(err, flag.ErrHelp), errors.Is(err, errConfigPrinted):
+		case errors.Is(err, errUsage):
+			os.Exit(2)
+		default:
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if name != "help" && name != "-h" && name != "--help" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+	usage()
+}
+
+// usage prints the commands of the binary.
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun %s <command> -h for the flags of a command. All commands read the same config file (-config) and environment.\n", os.Args[0])
+}
+
+// loadConfig parses the flags of a command, loads the effective settings and sets up the default logger.
+//
+// Parameters:
+// - name: The name of the command.
+// - args: The arguments after the command name.
+// - register: Adds the flags that only the command has. Nil adds none.
+//
+// Returns:
+// - The effective settings, or an error if the flags or the settings are invalid. After printing the config
+// for -print-config, the error is errConfigPrinted.
+func loadConfig(name string, args []string, register func(fs *flag.FlagSet)) (*config.Config, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	defaults := config.Default()
+	configPath := defaults.RegisterFlags(fs)
+	printConfig := fs.Bool("print-config", false, "Print the effective config with secrets redacted and exit")
+	if register != nil {
+		register(fs)
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, errUsage
+	}
+
+	cfg, err := config.Load(fs, *configPath, os.LookupEnv)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if *printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			return nil, fmt.Errorf("failed to print config: %w", err)
+		}
+		return nil, errConfigPrinted
+	}
+
+	// All packages log through the default logger, in a single format with shared field names
+	logger, err := logging.New(os.Stderr, cfg.LogFmt, cfg.LogLevel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log settings: %w", err)
+	}
+	log.SetDefault(logger)
+	return cfg, nil
+}
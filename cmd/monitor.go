@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	ee "github.com/primev/preconf_blob_bidder/core/eth"
+)
+
+// monitor records the blob transactions of the mempool seen by the first WebSocket endpoint and the
+// blocks that include them in the data folder, until a subscription fails or the process is signalled.
+func monitor(args []string) error {
+	cfg, err := loadConfig("monitor", args, nil)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Require("ws_endpoint"); err != nil {
+		return err
+	}
+
+	// SIGINT and SIGTERM stop the monitor after it wrote its data files
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return ee.Monitor(ctx, cfg.WSEndpoint[0])
+}
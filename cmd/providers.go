@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	bb "github.com/primev/preconf_blob_bidder/core/mevcommit"
+)
+
+// providers lists the preconfirmation providers registered on the mev-commit chain with their stake.
+func providers(args []string) error {
+	cfg, err := loadConfig("providers", args, nil)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Require("mev_commit_endpoint"); err != nil {
+		return err
+	}
+
+	client, err := bb.NewGethClient(cfg.MevCommitEndpoint)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the mev-commit chain: %w", err)
+	}
+	defer client.Close()
+
+	registered, err := bb.ListProviders(context.Background(), client)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tSTAKE (ETH)\tREGISTERED STAKE (ETH)\tREGISTERED AT BLOCK")
+	for _, provider := range registered {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", provider.Address, weiToEther(provider.Stake), weiToEther(provider.RegisteredStake), provider.RegisteredAtBlock)
+	}
+	return w.Flush()
+}
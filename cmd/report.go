@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/primev/preconf_blob_bidder/core/accounting"
+	bb "github.com/primev/preconf_blob_bidder/core/mevcommit"
+)
+
+// report prints what the transactions of the ledger cost in L1 fees and bid payments, per transaction, per day
+// or per provider, as CSV or JSON. Bid payments and refunds are read from the mev-commit chain when its endpoint
+// is set, otherwise they are reported as zero.
+func report(args []string) error {
+	var by, format, out *string
+	var fromBlock *uint64
+	cfg, err := loadConfig("report", args, func(fs *flag.FlagSet) {
+		by = fs.String("by", accounting.ByDay, "Table to report: tx, day or provider")
+		format = fs.String("format", accounting.FormatCSV, "Output format: csv or json")
+		out = fs.String("out", "", "File to write the report to (empty writes to stdout)")
+		fromBlock = fs.Uint64("from-block", 0, "First mev-commit chain block searched for bid payments and refunds")
+	})
+	if err != nil {
+		return err
+	}
+	if err := cfg.Require("rpc_endpoints", "ledger_file"); err != nil {
+		return err
+	}
+	if *by != accounting.ByTx && *by != accounting.ByDay && *by != accounting.ByProvider {
+		return fmt.Errorf("by must be %s, %s or %s, got %q", accounting.ByTx, accounting.ByDay, accounting.ByProvider, *by)
+	}
+	if *format != accounting.FormatCSV && *format != accounting.FormatJSON {
+		return fmt.Errorf("format must be %s or %s, got %q", accounting.FormatCSV, accounting.FormatJSON, *format)
+	}
+
+	entries, err := accounting.ReadLedger(cfg.LedgerFile)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	var settlements []bb.Settlement
+	if cfg.MevCommitEndpoint != "" {
+		client, err := bb.NewGethClient(cfg.MevCommitEndpoint)
+		if err != nil {
+			return fmt.Errorf("failed to connect to the mev-commit chain: %w", err)
+		}
+		defer client.Close()
+		settlements, err = bb.ListSettlements(ctx, client, accounting.Digests(entries), *fromBlock)
+		if err != nil {
+			return err
+		}
+	} else {
+		log.Warn("no mev_commit_endpoint, bid payments and refunds are not reported")
+	}
+
+	rpcPool, err := newRPCPool(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer rpcPool.Close()
+	client, err := rpcPool.Best()
+	if err != nil {
+		return err
+	}
+	costs, err := accounting.Build(ctx, client, entries, settlements)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("failed to create report file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+	return costs.Write(w, *by, *format)
+}
@@ -2,218 +2,595 @@ package main
 
 import (
 	"context"
+	"crypto/ecdsa"
 	"errors"
-	"flag"
 	"fmt"
-	"math"
+	"math/big"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/primev/preconf_blob_bidder/core/accounting"
+	pb "github.com/primev/preconf_blob_bidder/core/bidderpb"
+	"github.com/primev/preconf_blob_bidder/core/config"
 	ee "github.com/primev/preconf_blob_bidder/core/eth"
+	"github.com/primev/preconf_blob_bidder/core/logging"
 	bb "github.com/primev/preconf_blob_bidder/core/mevcommit"
+	"github.com/primev/preconf_blob_bidder/core/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/exp/rand"
 )
 
-var NUM_BLOBS = 6
-var MAX_PRECONF_ATTEMPTS = 50
-var RECONNECT_INTERVAL = 30 * time.Second // Interval to wait before attempting to reconnect
-var MAX_RPC_RETRIES = 5                   // Max retries for RPC endpoint
-var RPC_TIMEOUT = 30 * time.Second        // Timeout for RPC calls
+// blobConfig holds the effective settings, loaded from the config file, the environment and the flags
+var blobConfig *config.Config
 
-func main() {
-	rpcEndpoints := flag.String("rpc-endpoints", "", "Comma-separated list of Ethereum client endpoints")
-	wsEndpoint := flag.String("ws-endpoint", "", "The Ethereum client WebSocket endpoint")
-	privateKeyHex := flag.String("privatekey", "", "The private key in hex format")
-	offset := flag.Uint64("offset", 1, "Number of blocks to delay the transaction")
-	usePayload := flag.Bool("use-payload", false, "Set to true to send transactions using payload instead of transaction hashes")
+// blobMetrics are served on the metrics address in the Prometheus text format
+var blobMetrics = ee.NewMetrics()
 
-	glogger := log.NewGlogHandler(log.NewTerminalHandler(os.Stderr, true))
-	glogger.Verbosity(log.LevelInfo)
-	log.SetDefault(log.NewLogger(glogger))
+// blobHealth answers the liveness and readiness probes on the metrics address
+var blobHealth *ee.Health
 
-	flag.Parse()
-	if *rpcEndpoints == "" {
-		log.Crit("use the rpc-endpoints flag to provide it.", "err", errors.New("endpoints are required"))
+// blobLedger records the transactions the loop stopped tracking for the report command, nil if disabled
+var blobLedger *accounting.Ledger
+
+// blobBudget holds the spending and run limits of the loop and what was spent against them
+var blobBudget *ee.Budget
+
+// sendBlob runs the bidder loop: every block it sends blob transactions from the sending accounts to the
+// submission endpoints and bids for their preconfirmation until they are included.
+func sendBlob(args []string) error {
+	cfg, err := loadConfig("send-blob", args, nil)
+	if err != nil {
+		return err
+	}
+	if err := errors.Join(cfg.Require("rpc_endpoints"), cfg.RequireAccounts()); err != nil {
+		return err
+	}
+	blobConfig = cfg
+
+	// SIGINT and SIGTERM stop the loop. The slot in progress finishes its bid streams and submissions, but
+	// builds no new transactions and is cancelled once ShutdownTimeout passed. A second signal exits at once.
+	stopCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	workCtx, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+	context.AfterFunc(stopCtx, func() {
+		stop()
+		log.Info("shutting down, waiting for the work in flight", "timeout", cfg.ShutdownTimeout)
+		time.AfterFunc(cfg.ShutdownTimeout, cancelWork)
+	})
+
+	var effective strings.Builder
+	if err := cfg.Print(&effective); err == nil {
+		log.Info("effective config", "config", effective.String())
 	}
 
-	if *wsEndpoint == "" {
-		log.Crit("use the ws-endpoint flag to provide it.", "err", errors.New("endpoint is required"))
+	if len(cfg.WSEndpoint) == 0 {
+		log.Warn("no ws-endpoint given, polling the rpc endpoints for new heads")
 	}
 
-	authAcct, err := bb.AuthenticateAddress(*privateKeyHex)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.OTLPEndpoint, "sendblob")
 	if err != nil {
-		log.Crit("Failed to authenticate private key:", "err", err)
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	defer shutdownTracing(context.Background())
+	if cfg.OTLPEndpoint != "" {
+		log.Info("exporting traces", logging.KeyEndpoint, cfg.Redacted().OTLPEndpoint)
 	}
 
-	bidderAddress := os.Getenv("BIDDER_ADDRESS")
-	if bidderAddress == "" {
-		bidderAddress = "127.0.0.1:13524"
+	accounts, err := loadAccounts(context.Background(), cfg)
+	if err != nil {
+		return err
 	}
 
-	cfg := bb.BidderConfig{
-		ServerAddress: bidderAddress,
-		LogFmt:        "json",
-		LogLevel:      "info",
+	accountPool, err := ee.NewAccountPool(accounts, cfg.AccountSelection)
+	if err != nil {
+		return fmt.Errorf("failed to create account pool, use the keystore or remote-signer flag to provide accounts: %w", err)
 	}
+	log.Info("loaded sending accounts", "count", accountPool.Len(), "selection", cfg.AccountSelection)
 
-	bidderClient, err := bb.NewBidderClient(cfg)
+	bidderClient, err := dialBidder(cfg)
 	if err != nil {
-		log.Crit("failed to create bidder client, remember to connect to the mev-commit p2p bidder node.", "err", err)
+		return err
 	}
+	defer bidderClient.Close()
 
 	log.Info("connected to mev-commit client")
 
-	// Split the RPC endpoints and connect to each
-	rpcEndpointsList := strings.Split(*rpcEndpoints, ",")
-	var rpcClients []*ethclient.Client
-	for _, endpoint := range rpcEndpointsList {
-		client := connectRPCClientWithRetries(endpoint, MAX_RPC_RETRIES, RPC_TIMEOUT)
-		if client == nil {
-			log.Error("failed to connect to RPC client, skipping endpoint", "endpoint", endpoint)
+	// Reads go to the healthiest RPC endpoint, endpoints are health-checked in the background
+	rpcPool, err := newRPCPool(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	defer rpcPool.Close()
+	rpcPool.Start(stopCtx)
+
+	// Bundle requests are signed with a separate searcher reputation key that holds no funds
+	var bundleSigningKey *ecdsa.PrivateKey
+	if cfg.BundleSigningKey != "" {
+		bundleSigningKey, err = crypto.LoadECDSA(cfg.BundleSigningKey)
+		if err != nil {
+			return fmt.Errorf("failed to load bundle signing key: %w", err)
+		}
+		log.Info("bundle requests are signed", "searcher", crypto.PubkeyToAddress(bundleSigningKey.PublicKey))
+	}
+
+	bundleOptions := ee.BundleOptions{
+		SigningKey:         bundleSigningKey,
+		MinTimestampOffset: cfg.BundleMinTimestampOffset,
+		MaxTimestampOffset: cfg.BundleMaxTimestampOffset,
+	}
+
+	// Every transaction is broadcast to all submission endpoints, each with its own method
+	submitSpecs := cfg.RPCEndpoints
+	if len(cfg.SubmitEndpoints) > 0 {
+		submitSpecs = cfg.SubmitEndpoints
+	}
+	var submitters []ee.Submitter
+	for _, spec := range submitSpecs {
+		if strings.TrimSpace(spec) == "" {
 			continue
 		}
-		rpcClients = append(rpcClients, client)
-		log.Info("(rpc) geth client connected", "endpoint", endpoint)
+		submitter, err := ee.ParseSubmitter(spec, ee.MethodBundle, bundleOptions)
+		if err != nil {
+			return fmt.Errorf("invalid submission endpoint %s: %w", config.RedactURL(spec), err)
+		}
+		submitters = append(submitters, submitter)
+		log.Info("submission endpoint configured", logging.KeyEndpoint, submitter.Endpoint(), "method", submitter.Method())
 	}
+	endpoints := newSubmitterSet(submitters)
 
-	// Initial WebSocket connection
-	wsClient, err := connectWSClient(*wsEndpoint)
-	if err != nil {
-		log.Crit("failed to connect to geth client", "err", err)
+	// Bundles that revert or underpay in simulation are refused or repriced before submission
+	var simulator *ee.BundleSimulator
+	if cfg.SimulationEndpoint != "" {
+		simulator = ee.NewBundleSimulator(cfg.SimulationEndpoint, bundleSigningKey)
+		log.Info("bundles are simulated before submission", logging.KeyEndpoint, cfg.Redacted().SimulationEndpoint)
 	}
-	log.Info("(ws) geth client connected")
+	minCoinbaseDiff := gweiToWei(cfg.MinCoinbaseDiffGwei)
 
-	headers := make(chan *types.Header)
-	sub, err := wsClient.SubscribeNewHead(context.Background(), headers)
+	// Bundles are resubmitted for later blocks until the transaction is included
+	scheduler := ee.NewBundleScheduler(cfg.BundleBlocks, cfg.BundleLookahead)
+
+	// Relays report whether builders received, simulated and considered each bundle
+	var statusPoller *ee.BundleStatusPoller
+	if cfg.PollBundleStatus {
+		statusPoller = ee.NewBundleStatusPoller(bundleSigningKey)
+	}
+
+	if cfg.LedgerFile != "" {
+		blobLedger = accounting.NewLedger(cfg.LedgerFile)
+	}
+
+	// Reaching a spending or run limit stops the loop, reaching the bid spend of a window pauses it until the next window
+	if cfg.BlobBudget > 0 {
+		log.Warn("blob_budget is deprecated, use max_fee_spend", "max fee spend (ETH)", cfg.FeeSpendLimit())
+	}
+	blobBudget = ee.NewBudget(ee.BudgetLimits{
+		MaxBidSpend:     etherToWei(cfg.MaxBidSpend),
+		MaxFeeSpend:     etherToWei(cfg.FeeSpendLimit()),
+		MaxTxs:          cfg.MaxTxs,
+		MaxWindowSpend:  etherToWei(cfg.MaxWindowBidSpend),
+		BlocksPerWindow: cfg.BlocksPerWindow,
+		MaxDuration:     cfg.RunDuration,
+	})
+
+	// Transactions that were pending when the bidder stopped are tracked again and reserve their fees, the journal
+	// follows every change
+	var journal *ee.Journal
+	if cfg.JournalFile != "" {
+		journal = ee.NewJournal(cfg.JournalFile)
+		if err := resumePending(context.Background(), journal, rpcPool, accountPool, scheduler, endpoints); err != nil {
+			return err
+		}
+	}
+	saveJournal := func() {
+		if journal == nil {
+			return
+		}
+		if err := journal.Save(accountPool.Pending()); err != nil {
+			log.Error("failed to save the journal of pending transactions", logging.KeyErr, err)
+		}
+	}
+	saveJournal()
+	defer saveJournal()
+
+	// Fee raises for accounts whose last transaction was rejected as underpriced
+	replacements := make(map[common.Address]int)
+	blobFeeRaises := make(map[common.Address]uint64)
+
+	// New heads come from every WebSocket endpoint, with the rpc pool polled while they are all down
+	headSource := ee.NewHeadSource(ee.HeadSourceConfig{
+		WSEndpoints:       cfg.WSEndpoint,
+		Poll:              rpcPool,
+		PollInterval:      cfg.HeadPollInterval,
+		StaleAfter:        cfg.HeadStaleAfter,
+		ReconnectInterval: cfg.ReconnectInterval,
+	})
+	headSource.Start(stopCtx)
+
+	minDeposit := etherToWei(cfg.HealthMinDeposit)
+	blobHealth = ee.NewHealth(ee.HealthConfig{
+		Heads:      headSource,
+		Pool:       rpcPool,
+		Bidder:     bidderClient,
+		MaxHeadAge: cfg.HealthMaxHeadAge,
+		MinDeposit: minDeposit,
+		MaxBidAge:  cfg.HealthMaxBidAge,
+	})
+	if cfg.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", blobMetrics.Handler())
+		mux.Handle("/healthz", blobHealth.Handler())
+		mux.Handle("/readyz", blobHealth.Handler())
+		server := &http.Server{Addr: cfg.MetricsAddr, Handler: mux}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Error("metrics server stopped", logging.KeyErr, err)
+			}
+		}()
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.RPCTimeout)
+			defer cancel()
+			server.Shutdown(ctx)
+		}()
+		log.Info("serving metrics and health checks", "addr", cfg.MetricsAddr)
+	}
+
+	blobPolicy := ee.DefaultBlobPolicy()
+	blobPolicy.MinBlobs = cfg.MinBlobs
+	blobPolicy.MaxBlobs = cfg.MaxBlobs
+	if cfg.MaxBlobFee > 0 {
+		blobPolicy.MaxBlobFee = new(big.Int).SetUint64(cfg.MaxBlobFee)
+	}
+
+	feeCfg := ee.FeeConfig{
+		Strategy:       cfg.FeeStrategy,
+		HistoryBlocks:  cfg.FeeHistoryBlocks,
+		Percentile:     cfg.FeePercentile,
+		HeadroomBlocks: cfg.FeeHeadroomBlocks,
+		TipCap:         gweiToWei(cfg.TipGwei),
+		FeeCap:         gweiToWei(cfg.FeeCapGwei),
+		MaxTipCap:      gweiToWei(cfg.MaxTipGwei),
+	}
+	fees, err := ee.NewFeeStrategy(feeCfg)
 	if err != nil {
-		log.Crit("failed to subscribe to new blocks", "err", err)
+		return fmt.Errorf("invalid fee strategy settings: %w", err)
 	}
 
-	timer := time.NewTimer(24 * 14 * time.Hour)
-	pendingTxs := make(map[string]int64)
-	preconfCount := make(map[string]int)
+	pendingPayload := cfg.PayloadBytes
+
+	// Recent blocks are remembered to roll back inclusions when a reorg drops them
+	chainWindow := ee.NewChainWindow(cfg.ReorgWindow)
+
+	var deadline <-chan time.Time
+	if end, ok := blobBudget.Deadline(); ok {
+		timer := time.NewTimer(time.Until(end))
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	var head uint64
+	paused := false
+	defer func() { logBudget(log.Root(), "remaining budget", head) }()
 
 	for {
 		select {
-		case <-timer.C:
+		case <-deadline:
+			log.Info("Run duration reached, stopping the loop.", "duration", cfg.RunDuration)
+			return nil
+		case <-stopCtx.Done():
 			log.Info("Stopping the loop.")
-			return
-		case err := <-sub.Err():
-			log.Warn("subscription error", "err", err)
-			wsClient, sub = reconnectWSClient(*wsEndpoint, headers)
-			continue
-		case header := <-headers:
-			log.Info("new block generated", "block", header.Number)
-			if len(pendingTxs) == 0 {
-				for _, rpcEndpoint := range rpcEndpointsList {
-					// Check if the RPC client is valid
-					if rpcEndpoint == "" {
-						log.Warn("Skipping empty RPC endpoint")
-						continue
+			return nil
+		case event := <-headSource.Heads():
+			header := event.Header
+			head = header.Number.Uint64()
+			ctx, slotSpan := tracing.StartSlot(workCtx, header)
+			ctx = logging.WithFields(ctx, logging.KeySlot, header.Number)
+			slotLog := logging.FromContext(ctx, nil)
+			slotLog.Info("new block generated", "hash", header.Hash())
+			if event.Reorg != nil {
+				slotLog.Warn("chain reorg", "old head", event.Reorg.OldHead.Number, "old hash", event.Reorg.OldHead.Hash(), "new head", header.Number, "new hash", header.Hash())
+			}
+
+			client, err := rpcPool.Best()
+			if err != nil {
+				slotLog.Error("skipping block", logging.KeyErr, err)
+				tracing.End(slotSpan, err)
+				continue
+			}
+
+			reorg, err := chainWindow.Update(ctx, client, header)
+			if err != nil {
+				slotLog.Warn("failed to check block for reorgs", logging.KeyErr, err)
+			}
+			if reorg != nil {
+				reverted, untracked := accountPool.Revert(reorg.Dropped)
+				slotLog.Warn("reorg dropped blocks, rolling back inclusions",
+					"ancestor", reorg.Ancestor,
+					"dropped blocks", len(reorg.Dropped),
+					"new head", header.Number,
+					"reverted txs", len(reverted))
+				// The fees of the dropped blocks are reserved again until the transactions are included again.
+				// Untracked transactions stay charged, the newer transaction of their account needs them included.
+				for _, tx := range reverted {
+					blobBudget.RefundFees(tx.Fees)
+					if tx.Tx != nil {
+						blobBudget.Reserve(tx.Hash, ee.MaxTxFees(tx.Tx))
 					}
-					signedTx, blockNumber, err := ee.ExecuteBlobTransaction(wsClient, rpcEndpoint, header, authAcct, NUM_BLOBS, *offset)
-					log.Info("Transaction fee values",
-						"GasTipCap", signedTx.GasTipCap(),
-						"GasFeeCap", signedTx.GasFeeCap(),
-						"GasLimit", signedTx.Gas(),
-						"BlobFeeCap", signedTx.BlobGasFeeCap(),
-					)
-					if *usePayload {
-						// If use-payload is true, send the transaction payload to mev-commit. Don't send bundle
-						sendPreconfBid(bidderClient, signedTx, int64(blockNumber))
-					} else {
-						_, err = ee.SendBundle(rpcEndpoint, signedTx, blockNumber)
-						if err != nil {
-							log.Error("Failed to send transaction", "rpcEndpoint", rpcEndpoint, "error", err)
-						}
-						sendPreconfBid(bidderClient, signedTx.Hash().String(), int64(blockNumber))
+					blobMetrics.RevertInclusion(tx.Fees)
+					slotLog.Info("transaction is pending again after reorg", logging.KeyTxHash, tx.Hash, logging.KeyAccount, tx.From, "nonce", tx.Nonce)
+				}
+				for _, tx := range untracked {
+					slotLog.Warn("reorged transaction precedes a newer pending transaction, no longer tracked", logging.KeyTxHash, tx.Hash, logging.KeyAccount, tx.From, "nonce", tx.Nonce)
+				}
+				saveJournal()
+			}
+			if header.Number.Uint64() > cfg.ReorgWindow {
+				accountPool.Prune(header.Number.Uint64() - cfg.ReorgWindow)
+			}
+			updateGauges(bidderClient, rpcPool, accountPool)
+
+			// Check pending transactions and resend preconfirmation bids if necessary
+			if len(accountPool.Pending()) > 0 {
+				checkPendingTxs(ctx, rpcPool, bidderClient, accountPool, scheduler, statusPoller, header.Number.Uint64())
+				saveJournal()
+			}
+
+			// Submit the bundles of pending transactions for the blocks that came within reach
+			advanced := scheduler.Advance(ctx, endpoints.active(header.Number.Uint64()), header)
+			endpoints.report(advanced, header.Number.Uint64())
+			if statusPoller != nil {
+				statusPoller.Track(advanced)
+				for txHash, statuses := range statusPoller.Poll(ctx, header.Number.Uint64()) {
+					accountPool.SetBundleStatuses(txHash, statuses)
+				}
+			}
+
+			// New transactions and bids stop once a limit is reached
+			if err := blobBudget.Check(header.Number.Uint64() + 1); err != nil {
+				if errors.Is(err, ee.ErrBudgetExhausted) {
+					slotLog.Warn("budget exhausted, stopping the loop", "reason", err)
+					slotSpan.End()
+					return nil
+				}
+				if !paused {
+					slotLog.Warn("bidding window budget exhausted, pausing until the next window", "reason", err)
+					logBudget(slotLog, "remaining budget", header.Number.Uint64())
+				}
+				paused = true
+				slotSpan.End()
+				continue
+			}
+			if paused {
+				slotLog.Info("new bidding window, resuming", "window", blobBudget.WindowOf(header.Number.Uint64()+1))
+				paused = false
+			}
+
+			// The blob count is planned against what is left of the fee spend limit
+			remainingFees := blobBudget.Report(header.Number.Uint64()).RemainingFeeSpend
+			plan := blobPolicy.Plan(header, pendingPayload, remainingFees)
+			if plan.BlobBaseFee.IsInt64() {
+				blobMetrics.BlobBaseFeeWei.Update(plan.BlobBaseFee.Int64())
+			}
+			if len(plan.Txs) == 0 {
+				slotLog.Warn("no blob transaction fits the remaining budget", "blobBaseFee", plan.BlobBaseFee, "remaining fee spend (ETH)", weiToEther(remainingFees))
+				slotSpan.End()
+				continue
+			}
+			slotLog.Info("blob plan",
+				"blobBaseFee", plan.BlobBaseFee,
+				"parentBlobGasUsed", header.BlobGasUsed,
+				"txs", plan.Txs,
+				"estimatedCost", plan.Cost,
+			)
+
+			// Every transaction of the plan is sent from its own account, transactions that find
+			// no free account follow in later blocks.
+			for _, numBlobs := range plan.Txs {
+				if stopCtx.Err() != nil {
+					slotLog.Info("shutting down, not building more transactions")
+					break
+				}
+				if blobBudget.Check(header.Number.Uint64()+1) != nil {
+					break // The next block reports the limit
+				}
+				acct, err := accountPool.Next(ctx, client)
+				if err != nil {
+					if !errors.Is(err, ee.ErrNoFreeAccount) {
+						slotLog.Error("failed to select sending account", logging.KeyErr, err)
 					}
+					break
+				}
 
-					// handle ExecuteBlob error
+				// Build and sign the transaction once, every endpoint receives the same transaction
+				// Accounts whose previous transaction was rejected for its fees are sent with raised fees
+				txFees, txBlobFeeBlocks := fees, cfg.BlobFeeBlocks
+				if bumps := replacements[acct.Address]; bumps > 0 {
+					txFees = &ee.ScaledFeeStrategy{Base: fees, Percent: 100 << bumps}
+					txBlobFeeBlocks += uint64(bumps) * ee.BlobFeeDoublingBlocks
+				}
+				txBlobFeeBlocks += blobFeeRaises[acct.Address]
+
+				signedTx, blockNumber, err := ee.ExecuteBlobTransaction(ctx, client, header, acct.AuthAcct, numBlobs, cfg.Offset, txFees, txBlobFeeBlocks)
+				if err != nil {
+					slotLog.Warn("failed to execute blob tx", logging.KeyAccount, acct.Address, logging.KeyErr, err)
+					continue
+				}
+				blobMetrics.BlobTxsBuilt.Inc(1)
+				blobMetrics.BlobsBuilt.Inc(int64(len(signedTx.BlobHashes())))
+				if simulator != nil && !cfg.UsePayload {
+					simulated, err := simulateBundle(ctx, simulator, signedTx, blockNumber, minCoinbaseDiff, func(percent int64) (*types.Transaction, error) {
+						repriced, _, err := ee.ExecuteBlobTransaction(ctx, client, header, acct.AuthAcct, numBlobs, cfg.Offset, &ee.ScaledFeeStrategy{Base: txFees, Percent: percent}, txBlobFeeBlocks)
+						return repriced, err
+					})
 					if err != nil {
-						log.Warn("failed to execute blob tx", "err", err)
-						continue // Skip to the next endpoint
+						slotLog.Warn("refusing bundle after simulation", logging.KeyAccount, acct.Address, logging.KeyTxHash, signedTx.Hash(), logging.KeyErr, err)
+						continue
+					}
+					signedTx = simulated
+				}
+				slotLog.Info("Transaction fee values",
+					logging.KeyAccount, acct.Address,
+					logging.KeyTxHash, signedTx.Hash(),
+					"GasTipCap", signedTx.GasTipCap(),
+					"GasFeeCap", signedTx.GasFeeCap(),
+					"GasLimit", signedTx.Gas(),
+					"BlobFeeCap", signedTx.BlobGasFeeCap(),
+				)
+
+				var bid ee.Bid
+				if cfg.UsePayload {
+					// If use-payload is true, send the transaction payload to mev-commit. Don't submit it to the endpoints
+					bid = sendPreconfBid(ctx, bidderClient, signedTx, int64(blockNumber))
+				} else {
+					results := scheduler.Schedule(ctx, endpoints.active(header.Number.Uint64()), signedTx, header, blockNumber)
+					delivered := endpoints.report(results, header.Number.Uint64())
+					for _, result := range results {
+						switch {
+						case errors.Is(result.Err, ee.ErrNonceTooLow):
+							// The nonce was already used, the next transaction of the account picks up the new nonce
+							slotLog.Warn("nonce already used, dropping transaction", logging.KeyAccount, acct.Address, "nonce", signedTx.Nonce())
+						case errors.Is(result.Err, ee.ErrReplacementUnderpriced):
+							// Another transaction with this nonce is pending, the next attempt doubles all fees to replace it
+							replacements[acct.Address] = min(replacements[acct.Address]+1, cfg.MaxFeeBumps)
+						case errors.Is(result.Err, ee.ErrBlobFeeTooLow):
+							blobFeeRaises[acct.Address] += ee.BlobFeeDoublingBlocks / 2
+						}
+					}
+					if delivered == 0 {
+						slotLog.Warn("transaction was not delivered to any endpoint, skipping bid", logging.KeyTxHash, signedTx.Hash())
+						scheduler.Cancel(ctx, signedTx.Hash(), header.Number.Uint64())
+						continue
+					}
+					if statusPoller != nil {
+						statusPoller.Track(results)
 					}
+					// A single bid covers the transaction on every endpoint
+					bid = sendPreconfBid(ctx, bidderClient, signedTx.Hash().String(), int64(blockNumber))
+				}
 
+				delete(replacements, acct.Address)
+				delete(blobFeeRaises, acct.Address)
+				accountPool.MarkPending(ee.PendingTx{
+					From:        acct.Address,
+					Hash:        signedTx.Hash(),
+					Nonce:       signedTx.Nonce(),
+					TargetBlock: blockNumber,
+					SentAt:      time.Now(),
+					Preconfs:    1,
+					Bids:        []ee.Bid{bid},
+					Tx:          signedTx,
+				})
+				saveJournal()
+				blobBudget.RecordTx()
+				blobBudget.Reserve(signedTx.Hash(), ee.MaxTxFees(signedTx))
+
+				if cfg.PayloadBytes > 0 {
+					pendingPayload -= numBlobs * ee.BlobUsableBytes
+					if pendingPayload <= 0 {
+						pendingPayload = cfg.PayloadBytes
+					}
 				}
-			} else {
-				// Check pending transactions and resend preconfirmation bids if necessary
-				checkPendingTxs(rpcClients, bidderClient, pendingTxs, preconfCount)
 			}
+			slotSpan.End()
 		}
 	}
 }
 
-// Function to connect to RPC client with retry logic and timeout
-func connectRPCClientWithRetries(rpcEndpoint string, maxRetries int, timeout time.Duration) *ethclient.Client {
-	var rpcClient *ethclient.Client
-	var err error
+// submitterSet tracks which submission endpoints are usable, based on the errors they returned.
+type submitterSet struct {
+	submitters   []ee.Submitter
+	backoffUntil map[string]uint64
+	disabled     map[string]bool
+}
 
-	for i := 0; i < maxRetries; i++ {
-		ctx, cancel := context.WithTimeout(context.Background(), timeout)
-		defer cancel()
+func newSubmitterSet(submitters []ee.Submitter) *submitterSet {
+	return &submitterSet{
+		submitters:   submitters,
+		backoffUntil: make(map[string]uint64),
+		disabled:     make(map[string]bool),
+	}
+}
 
-		rpcClient, err = ethclient.DialContext(ctx, rpcEndpoint)
-		if err == nil {
-			return rpcClient
+// active returns the submitters that are neither disabled nor backing off at the given block.
+func (s *submitterSet) active(blockNumber uint64) []ee.Submitter {
+	var active []ee.Submitter
+	for _, submitter := range s.submitters {
+		if s.disabled[submitter.Endpoint()] || blockNumber < s.backoffUntil[submitter.Endpoint()] {
+			continue
 		}
+		active = append(active, submitter)
+	}
+	return active
+}
 
-		log.Warn("failed to connect to RPC client, retrying...", "attempt", i+1, "err", err)
-		time.Sleep(RECONNECT_INTERVAL * time.Duration(math.Pow(2, float64(i)))) // Exponential backoff
+// report logs failed submissions, backs off rate limiting endpoints and disables endpoints that do not
+// support their method. It returns the number of successful submissions.
+func (s *submitterSet) report(results []ee.SubmitResult, head uint64) int {
+	blobMetrics.RecordSubmissions(results)
+	succeeded := 0
+	for _, result := range results {
+		if result.Err == nil {
+			succeeded++
+			continue
+		}
+		log.Error("Failed to send transaction", logging.KeyEndpoint, result.Endpoint, "method", result.Method, logging.KeyTxHash, result.TxHash, logging.KeyBlock, result.Block, logging.KeyErr, result.Err)
+
+		switch {
+		case errors.Is(result.Err, ee.ErrRateLimited):
+			s.backoff(result.Endpoint, head+blobConfig.RateLimitBackoffBlocks)
+		case errors.Is(result.Err, ee.ErrUnknownMethod):
+			log.Error("endpoint does not support its submission method, disabling it", logging.KeyEndpoint, result.Endpoint, "method", result.Method)
+			s.disable(result.Endpoint)
+		}
 	}
+	return succeeded
+}
 
-	log.Error("failed to connect to RPC client after retries", "err", err)
-	return nil
+// backoff skips an endpoint until the given block.
+func (s *submitterSet) backoff(endpoint string, untilBlock uint64) {
+	log.Warn("endpoint is rate limiting, backing off", logging.KeyEndpoint, endpoint, "until block", untilBlock)
+	s.backoffUntil[endpoint] = untilBlock
 }
 
-func connectWSClient(wsEndpoint string) (*ethclient.Client, error) {
-	wsClient, err := bb.NewGethClient(wsEndpoint)
-	if err != nil {
-		log.Warn("failed to connect to websocket client", "err", err)
-		time.Sleep(RECONNECT_INTERVAL)
-		return connectWSClient(wsEndpoint)
-	}
-	return wsClient, nil
+// disable removes an endpoint for the rest of the run.
+func (s *submitterSet) disable(endpoint string) {
+	s.disabled[endpoint] = true
 }
 
-// Reconnect function for WebSocket client
-func reconnectWSClient(wsEndpoint string, headers chan *types.Header) (*ethclient.Client, ethereum.Subscription) {
-	var wsClient *ethclient.Client
-	var sub ethereum.Subscription
-	var err error
-
-	for i := 0; i < 10; i++ { // Retry logic for WebSocket connection
-		wsClient, err = connectWSClient(wsEndpoint)
-		if err == nil {
-			log.Info("(ws) geth client reconnected")
-			sub, err = wsClient.SubscribeNewHead(context.Background(), headers)
-			if err == nil {
-				return wsClient, sub
-			}
-		}
-		log.Warn("failed to reconnect WebSocket client, retrying...", "attempt", i+1, "err", err)
-		time.Sleep(RECONNECT_INTERVAL)
+// gweiToWei converts an amount in gwei to wei. Zero amounts return nil so that they mean "not set".
+func gweiToWei(gwei float64) *big.Int {
+	if gwei <= 0 {
+		return nil
 	}
-	log.Crit("failed to reconnect WebSocket client after retries", "err", err)
-	return nil, nil
+	wei, _ := new(big.Float).Mul(big.NewFloat(gwei), big.NewFloat(params.GWei)).Int(nil)
+	return wei
 }
 
 // sendPreconfBid sends a preconfirmation bid to the bidder client for a specified transaction.
 //
 // Parameters:
+//   - ctx (context.Context): The context for the bid, which also holds the span of the slot.
 //   - bidderClient (*bb.Bidder): The bidder client used to send the bid.
 //   - input (interface{}): The input can either be a transaction hash (string) or a pointer to a types.Transaction object.
 //   - blockNumber (int64): The block number at which the bid is valid.
 //
 // The function generates a random bid amount between 0.00001 and 0.05 ETH, converts it to wei, and sends the bid with a decay time window.
 // If the input type is not supported, the function logs a warning and exits.
-func sendPreconfBid(bidderClient *bb.Bidder, input interface{}, blockNumber int64) {
+//
+// Returns:
+//   - The record of the bid, with the error of a failed bid.
+func sendPreconfBid(ctx context.Context, bidderClient *bb.Bidder, input interface{}, blockNumber int64) ee.Bid {
+	logger := logging.FromContext(ctx, nil)
+
 	// Seed the random number generator
 	rand.Seed(uint64(time.Now().UnixNano()))
 
@@ -236,78 +613,335 @@ func sendPreconfBid(bidderClient *bb.Bidder, input interface{}, blockNumber int6
 	decayEnd := currentTime + int64(time.Duration(36*time.Second).Milliseconds()) // bid decay is 36 seconds (2 blocks)
 
 	// Determine how to handle the input
-	var err error
+	var (
+		commitments []*pb.Commitment
+		err         error
+	)
+	sentAt := time.Now()
+	bid := ee.Bid{Block: uint64(blockNumber), Amount: big.NewInt(randomWeiAmount), SentAt: sentAt}
 	switch v := input.(type) {
 	case string:
 		// Input is a string, process it as a transaction hash
 		txHash := strings.TrimPrefix(v, "0x")
-		log.Info("sending bid with transaction hash", "tx", input)
+		logger.Info("sending bid with transaction hash", logging.KeyTxHash, input)
 		// Send the bid with tx hash string
-		_, err = bidderClient.SendBid([]string{txHash}, amount, blockNumber, decayStart, decayEnd)
+		commitments, err = bidderClient.SendBid(ctx, []string{txHash}, amount, blockNumber, decayStart, decayEnd)
 
 	case *types.Transaction:
 		// Input is a transaction object, send the transaction object
-		log.Info("sending bid with tx payload", "tx", input.(*types.Transaction).Hash().String())
+		logger.Info("sending bid with tx payload", logging.KeyTxHash, input.(*types.Transaction).Hash().String())
 		// Send the bid with the full transaction object
-		_, err = bidderClient.SendBid([]*types.Transaction{v}, amount, blockNumber, decayStart, decayEnd)
+		commitments, err = bidderClient.SendBid(ctx, []*types.Transaction{v}, amount, blockNumber, decayStart, decayEnd)
 
 	default:
-		log.Warn("unsupported input type, must be string or *types.Transaction")
-		return
+		logger.Warn("unsupported input type, must be string or *types.Transaction")
+		bid.Err = "unsupported input type"
+		return bid
+	}
+
+	blobMetrics.BidsSent.Inc(1)
+	if err != nil {
+		blobMetrics.BidsFailed.Inc(1)
+		logger.Warn("failed to send bid", logging.KeyErr, err)
+		bid.Err = err.Error()
+		return bid
+	}
+	logger.Info("sent preconfirmation bid", logging.KeyBlock, blockNumber, "amount (ETH)", randomEthAmount, "commitments", len(commitments))
+	if len(commitments) > 0 {
+		blobHealth.RecordBid(time.Now())
+		blobBudget.RecordBid(bid.Block, bid.Amount)
 	}
 
+	for _, commitment := range commitments {
+		latency := time.Since(sentAt)
+		if commitment.DispatchTimestamp > 0 {
+			latency = time.UnixMilli(commitment.DispatchTimestamp).Sub(sentAt)
+		}
+		bidAmount, _ := new(big.Int).SetString(commitment.BidAmount, 10)
+		blobMetrics.RecordCommitment(commitment.ProviderAddress, latency, bidAmount)
+		bid.Committed = append(bid.Committed, ee.BidCommitment{
+			Digest:   common.HexToHash(commitment.CommitmentDigest),
+			Provider: common.HexToAddress(commitment.ProviderAddress),
+		})
+	}
+	bid.Commitments = len(commitments)
+	return bid
+}
+
+// updateGauges refreshes the metrics that reflect the current state rather than events.
+func updateGauges(bidderClient *bb.Bidder, rpcPool *ee.RPCPool, accountPool *ee.AccountPool) {
+	blobMetrics.PendingTxs.Update(int64(len(accountPool.Pending())))
+	blobMetrics.HealthyRPCClients.Update(int64(len(rpcPool.Healthy())))
+
+	ctx, cancel := context.WithTimeout(context.Background(), blobConfig.RPCTimeout)
+	defer cancel()
+	deposit, err := bidderClient.GetDeposit(ctx)
 	if err != nil {
-		log.Warn("failed to send bid", "err", err)
-	} else {
-		log.Info("sent preconfirmation bid", "block", blockNumber, "amount (ETH)", randomEthAmount)
+		log.Debug("failed to get bidder deposit", logging.KeyErr, err)
+		return
 	}
+	blobHealth.RecordDeposit(deposit)
+	blobMetrics.DepositGwei.Update(new(big.Int).Div(deposit, big.NewInt(params.GWei)).Int64())
 }
 
-func checkPendingTxs(clients []*ethclient.Client, bidderClient *bb.Bidder, pendingTxs map[string]int64, preconfCount map[string]int) {
-	for txHash, initialBlock := range pendingTxs {
-		for _, client := range clients {
-			if client == nil {
-				continue // Skip nil clients
+// checkPendingTxs checks the status of every pending transaction of the account pool against the healthiest RPC
+// endpoint. Transactions that are still pending get a new preconfirmation bid, included transactions and
+// transactions whose nonce was consumed free their sending account and cancel their remaining bundles.
+func checkPendingTxs(ctx context.Context, rpcPool *ee.RPCPool, bidderClient *bb.Bidder, accountPool *ee.AccountPool, scheduler *ee.BundleScheduler, statusPoller *ee.BundleStatusPoller, head uint64) {
+	logger := logging.FromContext(ctx, nil)
+
+	ctx, span := tracing.Start(ctx, "pending.check", attribute.Int("pending", len(accountPool.Pending())))
+	defer span.End()
+
+	client, err := rpcPool.Best()
+	if err != nil {
+		logger.Error("cannot check pending transactions", logging.KeyErr, err)
+		return
+	}
+
+	for _, pending := range accountPool.Pending() {
+		receiptCtx, receiptSpan := tracing.Start(ctx, "receipt.check", tracing.TxHash(pending.Hash), tracing.Block(pending.TargetBlock))
+		receipt, err := client.TransactionReceipt(receiptCtx, pending.Hash)
+		receiptSpan.SetAttributes(attribute.Bool("included", err == nil))
+		tracing.End(receiptSpan, ignoreNotFound(err))
+		if err != nil {
+			if err != ethereum.NotFound {
+				logger.Error("Error checking transaction receipt", logging.KeyErr, err)
+				continue
 			}
-			receipt, err := client.TransactionReceipt(context.Background(), common.HexToHash(txHash))
+
+			// Another transaction with the same nonce may have been included instead.
+			nonce, err := client.NonceAt(ctx, pending.From, nil)
 			if err != nil {
-				if err == ethereum.NotFound {
-					// Transaction is still pending, resend preconfirmation bid
-					currentBlockNumber, err := client.BlockNumber(context.Background())
-					if err != nil {
-						log.Error("failed to retrieve current block number", "err", err)
-						continue
-					}
-					if currentBlockNumber > uint64(initialBlock) {
-						sendPreconfBid(bidderClient, txHash, int64(currentBlockNumber)+1)
-						preconfCount[txHash]++
-
-						log.Info("Resent preconfirmation bid for tx",
-							"txHash", txHash,
-							"block number", currentBlockNumber,
-							"total preconfirmations", preconfCount[txHash])
-
-						// Check if preconfCount exceeds MAX_PRECONF_ATTEMPTS
-						if preconfCount[txHash] >= MAX_PRECONF_ATTEMPTS {
-							log.Warn("Max preconfirmation attempts reached for tx. Restarting with a new transaction.",
-								"txHash", txHash)
-							delete(pendingTxs, txHash)
-							delete(preconfCount, txHash)
-						}
-					}
-				} else {
-					log.Error("Error checking transaction receipt", "err", err)
+				logger.Error("failed to retrieve account nonce", logging.KeyAccount, pending.From, logging.KeyErr, err)
+				continue
+			}
+			if nonce > pending.Nonce {
+				logger.Info("Transaction nonce consumed by another transaction",
+					logging.KeyTxHash, pending.Hash,
+					logging.KeyAccount, pending.From,
+					"nonce", pending.Nonce,
+					"total preconfirmations", pending.Preconfs,
+					"bundle stage", ee.FurthestStage(pending.Bundles))
+				accountPool.ClearPending(pending.From)
+				blobBudget.Release(pending.Hash)
+				stopTracking(ctx, scheduler, statusPoller, pending.Hash, head)
+				recordSettled(pending, accounting.OutcomeReplaced, 0)
+				continue
+			}
+
+			// Transaction is still pending, resend preconfirmation bid
+			if head > pending.TargetBlock {
+				if err := blobBudget.Check(head + 1); err != nil {
+					logger.Info("not resending preconfirmation bid", logging.KeyTxHash, pending.Hash, "reason", err)
+					continue
+				}
+				bid := sendPreconfBid(ctx, bidderClient, pending.Hash.String(), int64(head)+1)
+				pending.Preconfs++
+				pending.Bids = append(pending.Bids, bid)
+				accountPool.MarkPending(pending)
+
+				logger.Info("Resent preconfirmation bid for tx",
+					logging.KeyTxHash, pending.Hash,
+					logging.KeyAccount, pending.From,
+					logging.KeySlot, head,
+					"total preconfirmations", pending.Preconfs)
+
+				// Check if the number of preconfirmations exceeds the max preconf attempts
+				if pending.Preconfs >= blobConfig.MaxPreconfAttempts {
+					logger.Warn("Max preconfirmation attempts reached for tx. Restarting with a new transaction.",
+						logging.KeyTxHash, pending.Hash,
+						"bundle stage", ee.FurthestStage(pending.Bundles))
+					accountPool.ClearPending(pending.From)
+					blobBudget.Release(pending.Hash)
+					stopTracking(ctx, scheduler, statusPoller, pending.Hash, head)
+					recordSettled(pending, accounting.OutcomeAbandoned, 0)
 				}
-			} else {
-				// Transaction is confirmed, remove from pendingTxs
-				delete(pendingTxs, txHash)
-				log.Info("Transaction confirmed",
-					"txHash", txHash,
-					"confirmed block", receipt.BlockNumber.Uint64(),
-					"initially sent block", initialBlock,
-					"total preconfirmations", preconfCount[txHash])
-				delete(preconfCount, txHash)
 			}
+			continue
 		}
+
+		// Transaction is confirmed, free the account but keep it until its block can no longer be reorged out
+		fees := receiptFees(receipt)
+		accountPool.Confirm(pending, receipt.BlockNumber.Uint64(), receipt.BlockHash, fees)
+		blobMetrics.RecordInclusion(pending.TargetBlock, receipt.BlockNumber.Uint64(), fees)
+		blobBudget.Settle(pending.Hash, fees)
+		recordSettled(pending, accounting.OutcomeIncluded, receipt.BlockNumber.Uint64())
+		stopTracking(ctx, scheduler, statusPoller, pending.Hash, head)
+		logger.Info("Transaction confirmed",
+			logging.KeyTxHash, pending.Hash,
+			logging.KeyAccount, pending.From,
+			"confirmed block", receipt.BlockNumber.Uint64(),
+			"initially sent block", pending.TargetBlock,
+			"total preconfirmations", pending.Preconfs,
+			"bundle stage", ee.FurthestStage(pending.Bundles))
+	}
+}
+
+// recordSettled appends a transaction the loop stopped tracking to the ledger, if it is enabled.
+func recordSettled(tx ee.PendingTx, outcome string, block uint64) {
+	if blobLedger == nil {
+		return
+	}
+	if err := blobLedger.Record(accounting.NewEntry(tx, outcome, block)); err != nil {
+		log.Error("failed to record transaction in the ledger", logging.KeyTxHash, tx.Hash, "outcome", outcome, logging.KeyErr, err)
+	}
+}
+
+// logBudget logs what the loop spent and what is left of its limits. Unlimited amounts are shown as "-".
+func logBudget(logger log.Logger, msg string, head uint64) {
+	report := blobBudget.Report(head)
+	remainingTxs := "-"
+	if report.RemainingTxs != nil {
+		remainingTxs = fmt.Sprint(*report.RemainingTxs)
+	}
+	remainingTime := "-"
+	if _, ok := blobBudget.Deadline(); ok {
+		remainingTime = report.RemainingTime.Round(time.Second).String()
+	}
+	logger.Info(msg,
+		"bid spend (ETH)", weiToEther(report.BidSpend),
+		"remaining bid spend (ETH)", weiToEther(report.RemainingBidSpend),
+		"fee spend (ETH)", weiToEther(report.FeeSpend),
+		"reserved fee spend (ETH)", weiToEther(report.ReservedFeeSpend),
+		"remaining fee spend (ETH)", weiToEther(report.RemainingFeeSpend),
+		"txs", report.Txs,
+		"remaining txs", remainingTxs,
+		"window", report.Window,
+		"window bid spend (ETH)", weiToEther(report.WindowSpend),
+		"remaining window bid spend (ETH)", weiToEther(report.RemainingWindowSpend),
+		"remaining run time", remainingTime,
+	)
+}
+
+// resumePending loads the transactions of the journal and reconciles them with the chain. Transactions that were
+// included or replaced while the bidder was stopped are settled, the others become the pending transactions of their
+// accounts again and are resubmitted for the next block. Bids for them resume with the next head.
+func resumePending(ctx context.Context, journal *ee.Journal, rpcPool *ee.RPCPool, accountPool *ee.AccountPool, scheduler *ee.BundleScheduler, endpoints *submitterSet) error {
+	journaled, err := journal.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load the journal of pending transactions: %w", err)
+	}
+	if len(journaled) == 0 {
+		return nil
+	}
+
+	client, err := rpcPool.Best()
+	if err != nil {
+		return err
+	}
+	chainCtx, cancel := context.WithTimeout(ctx, blobConfig.RPCTimeout)
+	defer cancel()
+	header, err := client.HeaderByNumber(chainCtx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get the latest block: %w", err)
+	}
+	head := header.Number.Uint64()
+	reconciled, err := ee.Reconcile(chainCtx, client, journaled)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile the journal of pending transactions: %w", err)
+	}
+
+	for _, tx := range reconciled {
+		if !accountPool.Contains(tx.From) {
+			log.Warn("journaled transaction was sent from an account that is no longer configured, not tracking it", logging.KeyTxHash, tx.Hash, logging.KeyAccount, tx.From, "nonce", tx.Nonce)
+			continue
+		}
+		switch tx.State {
+		case ee.TxIncluded:
+			accountPool.Confirm(tx.PendingTx, tx.Receipt.BlockNumber.Uint64(), tx.Receipt.BlockHash, receiptFees(tx.Receipt))
+			blobMetrics.RecordInclusion(tx.TargetBlock, tx.Receipt.BlockNumber.Uint64(), receiptFees(tx.Receipt))
+			recordSettled(tx.PendingTx, accounting.OutcomeIncluded, tx.Receipt.BlockNumber.Uint64())
+			log.Info("journaled transaction was included while stopped", logging.KeyTxHash, tx.Hash, logging.KeyAccount, tx.From, logging.KeyBlock, tx.Receipt.BlockNumber, "total preconfirmations", tx.Preconfs)
+		case ee.TxReplaced:
+			log.Info("journaled transaction nonce was consumed by another transaction while stopped", logging.KeyTxHash, tx.Hash, logging.KeyAccount, tx.From, "nonce", tx.Nonce)
+			recordSettled(tx.PendingTx, accounting.OutcomeReplaced, 0)
+		default:
+			accountPool.MarkPending(tx.PendingTx)
+			if tx.Tx != nil {
+				blobBudget.Reserve(tx.Hash, ee.MaxTxFees(tx.Tx))
+			}
+			log.Info("resuming journaled transaction", logging.KeyTxHash, tx.Hash, logging.KeyAccount, tx.From, "nonce", tx.Nonce, "target block", tx.TargetBlock, "total preconfirmations", tx.Preconfs)
+			if tx.Tx == nil || blobConfig.UsePayload {
+				continue
+			}
+			// The bundles sent before the restart targeted blocks that have passed
+			endpoints.report(scheduler.Schedule(ctx, endpoints.active(head), tx.Tx, header, head+1), head)
+		}
+	}
+	return nil
+}
+
+// simulateBundle simulates signedTx as a single transaction bundle. A bundle that pays the builder less than
+// minCoinbaseDiff is rebuilt once by reprice with scaled fees and simulated again. When the simulation endpoint
+// itself fails the transaction is submitted unsimulated.
+func simulateBundle(ctx context.Context, simulator *ee.BundleSimulator, signedTx *types.Transaction, blockNumber uint64, minCoinbaseDiff *big.Int, reprice func(percent int64) (*types.Transaction, error)) (*types.Transaction, error) {
+	logger := logging.FromContext(ctx, nil)
+
+	sim, err := simulator.CallBundle(ctx, []*types.Transaction{signedTx}, blockNumber)
+	if err != nil {
+		logger.Warn("bundle simulation failed, submitting unsimulated", logging.KeyTxHash, signedTx.Hash(), logging.KeyErr, err)
+		return signedTx, nil
+	}
+	logger.Info("simulated bundle", logging.KeyTxHash, signedTx.Hash(), "gasUsed", sim.GasUsed, "coinbaseDiff", sim.CoinbaseDiff, "reverted", sim.Reverted())
+
+	err = sim.Check(minCoinbaseDiff)
+	if !errors.Is(err, ee.ErrBundleUnderpaid) {
+		return signedTx, err
+	}
+	percent := sim.RepricePercent(minCoinbaseDiff)
+	if percent == 0 || percent > blobConfig.MaxRepricePercent {
+		return nil, err
+	}
+
+	repriced, err := reprice(percent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reprice bundle: %w", err)
+	}
+	sim, err = simulator.CallBundle(ctx, []*types.Transaction{repriced}, blockNumber)
+	if err != nil {
+		logger.Warn("bundle simulation failed, submitting unsimulated", logging.KeyTxHash, repriced.Hash(), logging.KeyErr, err)
+		return repriced, nil
+	}
+	logger.Info("simulated repriced bundle", logging.KeyTxHash, repriced.Hash(), "percent", percent, "gasUsed", sim.GasUsed, "coinbaseDiff", sim.CoinbaseDiff, "reverted", sim.Reverted())
+	if err := sim.Check(minCoinbaseDiff); err != nil {
+		return nil, err
+	}
+	return repriced, nil
+}
+
+// stopTracking stops resubmitting a transaction, cancels the bundles already sent for later blocks and stops
+// polling their status.
+func stopTracking(ctx context.Context, scheduler *ee.BundleScheduler, statusPoller *ee.BundleStatusPoller, txHash common.Hash, head uint64) {
+	logger := logging.FromContext(ctx, nil)
+
+	if statusPoller != nil {
+		statusPoller.Forget(txHash)
+	}
+	for _, result := range scheduler.Cancel(ctx, txHash, head) {
+		if result.Err != nil {
+			logger.Warn("failed to cancel bundle", logging.KeyEndpoint, result.Endpoint, logging.KeyTxHash, txHash, logging.KeyBlock, result.Block, logging.KeyErr, result.Err)
+		}
+	}
+}
+
+// ignoreNotFound returns err unless it reports a missing transaction receipt, which is expected while a
+// transaction is pending.
+func ignoreNotFound(err error) error {
+	if errors.Is(err, ethereum.NotFound) {
+		return nil
+	}
+	return err
+}
+
+// receiptFees returns the execution and blob fees paid by an included transaction.
+func receiptFees(receipt *types.Receipt) *big.Int {
+	fees := new(big.Int)
+	if receipt.EffectiveGasPrice != nil {
+		fees.Mul(receipt.EffectiveGasPrice, new(big.Int).SetUint64(receipt.GasUsed))
+	}
+	if receipt.BlobGasPrice != nil {
+		fees.Add(fees, new(big.Int).Mul(receipt.BlobGasPrice, new(big.Int).SetUint64(receipt.BlobGasUsed)))
 	}
+	return fees
 }
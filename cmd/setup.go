@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/primev/preconf_blob_bidder/core/config"
+	ee "github.com/primev/preconf_blob_bidder/core/eth"
+	"github.com/primev/preconf_blob_bidder/core/logging"
+	bb "github.com/primev/preconf_blob_bidder/core/mevcommit"
+)
+
+// loadAccounts loads the sending accounts from every configured source: the private key, the key file,
+// the keystore and the remote signer, in that order.
+//
+// Parameters:
+// - ctx: The context for connecting to the remote signer.
+// - cfg: The effective settings.
+//
+// Returns:
+// - The accounts, or an error if a source cannot be loaded.
+func loadAccounts(ctx context.Context, cfg *config.Config) ([]bb.AuthAcct, error) {
+	var accounts []bb.AuthAcct
+	if cfg.PrivateKey != "" {
+		authAcct, err := bb.AuthenticateAddress(cfg.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to authenticate private key: %w", err)
+		}
+		accounts = append(accounts, authAcct)
+	}
+	if cfg.Keys != "" {
+		loaded, err := bb.LoadAccounts(cfg.Keys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load private keys: %w", err)
+		}
+		accounts = append(accounts, loaded...)
+	}
+	if cfg.Keystore != "" {
+		loaded, err := bb.LoadKeystoreAccounts(cfg.Keystore, cfg.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load keystore: %w", err)
+		}
+		accounts = append(accounts, loaded...)
+	}
+	if cfg.RemoteSigner != "" {
+		for _, address := range cfg.RemoteSignerAccounts {
+			signer, err := bb.NewRemoteSigner(ctx, cfg.RemoteSigner, common.HexToAddress(address), cfg.RemoteSignerMethod)
+			if err != nil {
+				return nil, fmt.Errorf("failed to connect to remote signer: %w", err)
+			}
+			accounts = append(accounts, bb.NewAuthAcct(signer))
+		}
+	}
+	return accounts, nil
+}
+
+// newRPCPool creates the pool of the configured rpc endpoints and logs the outcome of its first health check.
+//
+// Parameters:
+// - ctx: The context for the first health check.
+// - cfg: The effective settings.
+//
+// Returns:
+// - The pool, not yet checking in the background, or an error if no endpoint is configured.
+func newRPCPool(ctx context.Context, cfg *config.Config) (*ee.RPCPool, error) {
+	poolCfg := ee.DefaultRPCPoolConfig()
+	poolCfg.CheckInterval = cfg.RPCCheckInterval
+	poolCfg.Timeout = cfg.RPCTimeout
+	poolCfg.MaxBlockLag = cfg.RPCMaxLag
+	poolCfg.MaxLatency = cfg.RPCMaxLatency
+	rpcPool, err := ee.NewRPCPool(ctx, cfg.RPCEndpoints, poolCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rpc pool: %w", err)
+	}
+	for _, state := range rpcPool.State() {
+		logging.FromContext(ctx, nil).Info("(rpc) endpoint checked", logging.KeyEndpoint, state.URL, "healthy", state.Healthy, logging.KeyBlock, state.BlockNumber, "latency", state.Latency, logging.KeyErr, state.LastError)
+	}
+	return rpcPool, nil
+}
+
+// dialBidder connects to the mev-commit bidder node.
+func dialBidder(cfg *config.Config) (*bb.Bidder, error) {
+	bidderClient, err := bb.NewBidderClient(cfg.BidderConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bidder client, remember to connect to the mev-commit p2p bidder node: %w", err)
+	}
+	return bidderClient, nil
+}
+
+// etherToWei converts an amount in ETH to wei. Zero amounts return nil so that they mean "not set".
+func etherToWei(ether float64) *big.Int {
+	if ether <= 0 {
+		return nil
+	}
+	wei, _ := new(big.Float).Mul(big.NewFloat(ether), big.NewFloat(params.Ether)).Int(nil)
+	return wei
+}
+
+// weiToEther formats an amount of wei in ETH.
+func weiToEther(wei *big.Int) string {
+	if wei == nil {
+		return "-"
+	}
+	return new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.Ether)).Text('f', 6)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/primev/preconf_blob_bidder/core/config"
+	bb "github.com/primev/preconf_blob_bidder/core/mevcommit"
+)
+
+// status prints the state of everything the bidder loop depends on: the bidder node and its deposit, the
+// bidding window, the rpc endpoints and the balances and nonces of the sending accounts. Parts whose
+// settings are missing are skipped, parts that fail show the error.
+func status(args []string) error {
+	cfg, err := loadConfig("status", args, nil)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	// Bidder node
+	fmt.Fprintln(w, "BIDDER NODE\tSTATE\tDEPOSIT (ETH)")
+	bidderClient, err := dialBidder(cfg)
+	if err != nil {
+		fmt.Fprintf(w, "%s\t%v\t-\n", cfg.BidderAddress, err)
+	} else {
+		defer bidderClient.Close()
+		depositCtx, cancel := context.WithTimeout(ctx, cfg.RPCTimeout)
+		amount, err := bidderClient.GetDeposit(depositCtx)
+		cancel()
+		deposit := weiToEther(amount)
+		if err != nil {
+			deposit = err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", cfg.BidderAddress, bidderClient.ConnState(), deposit)
+	}
+
+	// Bidding window on the mev-commit chain
+	if cfg.MevCommitEndpoint != "" {
+		fmt.Fprintln(w, "\nMEV-COMMIT CHAIN\tWINDOW\tMIN DEPOSIT (ETH)")
+		fmt.Fprintln(w, windowStatus(cfg))
+	}
+
+	if len(cfg.RPCEndpoints) == 0 {
+		return nil
+	}
+
+	// RPC endpoints
+	rpcPool, err := newRPCPool(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "\nRPC ENDPOINT\tHEALTHY\tBLOCK\tLAG\tLATENCY\tERROR")
+	for _, state := range rpcPool.State() {
+		fmt.Fprintf(w, "%s\t%t\t%d\t%d\t%s\t%s\n", config.RedactURL(state.URL), state.Healthy, state.BlockNumber, state.Lag, state.Latency, state.LastError)
+	}
+
+	if cfg.RequireAccounts() != nil {
+		return nil
+	}
+
+	// Sending accounts
+	accounts, err := loadAccounts(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	client, err := rpcPool.Best()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "\nACCOUNT\tBALANCE (ETH)\tNONCE\tPENDING NONCE")
+	for _, acct := range accounts {
+		fmt.Fprintln(w, accountStatus(ctx, cfg, client, acct.Address))
+	}
+	return nil
+}
+
+// windowStatus returns the status row of the mev-commit chain: its endpoint, the current bidding window
+// and the minimum deposit.
+func windowStatus(cfg *config.Config) string {
+	endpoint := cfg.Redacted().MevCommitEndpoint
+	client, err := bb.NewGethClient(cfg.MevCommitEndpoint)
+	if err != nil {
+		return fmt.Sprintf("%s\t%v\t-", endpoint, err)
+	}
+	defer client.Close()
+
+	window, err := bb.WindowHeight(client)
+	if err != nil {
+		return fmt.Sprintf("%s\t%v\t-", endpoint, err)
+	}
+	minDeposit, err := bb.GetMinDeposit(client)
+	if err != nil {
+		return fmt.Sprintf("%s\t%s\t%v", endpoint, window, err)
+	}
+	return fmt.Sprintf("%s\t%s\t%s", endpoint, window, weiToEther(minDeposit))
+}
+
+// accountStatus returns the status row of a sending account: its balance, its nonce and its pending nonce.
+func accountStatus(ctx context.Context, cfg *config.Config, client *ethclient.Client, address common.Address) string {
+	ctx, cancel := context.WithTimeout(ctx, cfg.RPCTimeout)
+	defer cancel()
+
+	balance, err := client.BalanceAt(ctx, address, nil)
+	if err != nil {
+		return fmt.Sprintf("%s\t%v\t-\t-", address, err)
+	}
+	nonce, err := client.NonceAt(ctx, address, nil)
+	if err != nil {
+		return fmt.Sprintf("%s\t%s\t%v\t-", address, weiToEther(balance), err)
+	}
+	pendingNonce, err := client.PendingNonceAt(ctx, address)
+	if err != nil {
+		return fmt.Sprintf("%s\t%s\t%d\t%v", address, weiToEther(balance), nonce, err)
+	}
+	return fmt.Sprintf("%s\t%s\t%d\t%d", address, weiToEther(balance), nonce, pendingNonce)
+}
@@ -0,0 +1,117 @@
+// Package accounting records every transaction the bidder loop settled with the bids sent for it, and
+// reports what the transactions cost in L1 fees and bid payments per transaction, per day and per provider.
+package accounting
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/primev/preconf_blob_bidder/core/datafile"
+	ee "github.com/primev/preconf_blob_bidder/core/eth"
+)
+
+// Outcomes of a settled transaction.
+const (
+	OutcomeIncluded  = "included"  // The transaction was included.
+	OutcomeReplaced  = "replaced"  // Another transaction with the same nonce was included.
+	OutcomeAbandoned = "abandoned" // The bidder gave up on the transaction after too many bids.
+)
+
+// Entry is a transaction of the ledger, written once the bidder loop stopped tracking it.
+type Entry struct {
+	Hash        common.Hash    `json:"hash"`
+	From        common.Address `json:"from"`
+	Nonce       uint64         `json:"nonce"`
+	TargetBlock uint64         `json:"targetBlock"`
+	SentAt      time.Time      `json:"sentAt"`
+	Outcome     string         `json:"outcome"`
+	Block       uint64         `json:"block,omitempty"` // The block the transaction was included in.
+	SettledAt   time.Time      `json:"settledAt"`
+	Bids        []ee.Bid       `json:"bids"`
+}
+
+// NewEntry creates the ledger entry of a transaction the bidder loop stopped tracking.
+//
+// Parameters:
+// - tx: The transaction.
+// - outcome: OutcomeIncluded, OutcomeReplaced or OutcomeAbandoned.
+// - block: The block an included transaction was included in, 0 otherwise.
+//
+// Returns:
+// - The ledger entry.
+func NewEntry(tx ee.PendingTx, outcome string, block uint64) Entry {
+	return Entry{
+		Hash:        tx.Hash,
+		From:        tx.From,
+		Nonce:       tx.Nonce,
+		TargetBlock: tx.TargetBlock,
+		SentAt:      tx.SentAt,
+		Outcome:     outcome,
+		Block:       block,
+		SettledAt:   time.Now(),
+		Bids:        tx.Bids,
+	}
+}
+
+// Ledger appends the settled transactions to a file of the data folder, one JSON entry per line.
+type Ledger struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewLedger creates a ledger that is stored at path.
+func NewLedger(path string) *Ledger {
+	return &Ledger{path: path}
+}
+
+// Record appends an entry to the ledger.
+func (l *Ledger) Record(entry Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return datafile.AppendJSONLine(l.path, entry)
+}
+
+// ReadLedger reads the entries of the ledger at path. A transaction that was recorded again, for example
+// because a reorg dropped its block and it was included again, keeps its position and its latest entry.
+//
+// Parameters:
+// - path: The ledger file.
+//
+// Returns:
+// - The entries in the order their transactions were first recorded, or an error if the ledger cannot be read.
+func ReadLedger(path string) ([]Entry, error) {
+	var entries []Entry
+	index := make(map[common.Hash]int)
+	err := datafile.ReadJSONLines(path, func(line []byte) error {
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return err
+		}
+		if i, ok := index[entry.Hash]; ok {
+			entries[i] = entry
+			return nil
+		}
+		index[entry.Hash] = len(entries)
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Digests returns the digests of all commitments received for the bids of the entries.
+func Digests(entries []Entry) []common.Hash {
+	var digests []common.Hash
+	for _, entry := range entries {
+		for _, bid := range entry.Bids {
+			for _, commitment := range bid.Committed {
+				digests = append(digests, commitment.Digest)
+			}
+		}
+	}
+	return digests
+}
@@ -0,0 +1,331 @@
+package accounting
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	bb "github.com/primev/preconf_blob_bidder/core/mevcommit"
+)
+
+// Tables of a Report.
+const (
+	ByTx       = "tx"       // One row per transaction.
+	ByDay      = "day"      // One row per UTC day the transactions were sent on, and a total row.
+	ByProvider = "provider" // One row per provider that committed to a bid.
+)
+
+// Formats a Report is written in.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// TxCost is what one transaction cost. Amounts are in wei, bid payments and refunds are those settled so far.
+type TxCost struct {
+	Hash              common.Hash      `json:"hash"`
+	From              common.Address   `json:"from"`
+	Day               string           `json:"day"`
+	Outcome           string           `json:"outcome"`
+	Block             uint64           `json:"block"`
+	Blobs             int              `json:"blobs"`
+	GasUsed           uint64           `json:"gasUsed"`
+	EffectiveGasPrice *big.Int         `json:"effectiveGasPrice"`
+	BlobGasUsed       uint64           `json:"blobGasUsed"`
+	BlobGasPrice      *big.Int         `json:"blobGasPrice"`
+	ExecutionFee      *big.Int         `json:"executionFee"`
+	BlobFee           *big.Int         `json:"blobFee"`
+	Bids              int              `json:"bids"`
+	Commitments       int              `json:"commitments"`
+	BidPaid           *big.Int         `json:"bidPaid"`
+	BidRefunded       *big.Int         `json:"bidRefunded"`
+	PaidProviders     []common.Address `json:"paidProviders"`
+	TotalCost         *big.Int         `json:"totalCost"` // Execution fee, blob fee and bid payments.
+}
+
+// DayCost is what the transactions sent on one UTC day cost. Amounts are in wei.
+type DayCost struct {
+	Day          string   `json:"day"`
+	Txs          int      `json:"txs"`
+	IncludedTxs  int      `json:"includedTxs"`
+	Blobs        int      `json:"blobs"`
+	ExecutionFee *big.Int `json:"executionFee"`
+	BlobFee      *big.Int `json:"blobFee"`
+	BidPaid      *big.Int `json:"bidPaid"`
+	BidRefunded  *big.Int `json:"bidRefunded"`
+	TotalCost    *big.Int `json:"totalCost"`
+	CostPerBlob  *big.Int `json:"costPerBlob"` // Total cost per included blob, nil without included blobs.
+}
+
+// ProviderCost is what was paid to one provider for its commitments. Amounts are in wei.
+type ProviderCost struct {
+	Provider    common.Address `json:"provider"`
+	Commitments int            `json:"commitments"`
+	Payments    int            `json:"payments"`
+	Refunds     int            `json:"refunds"`
+	BidPaid     *big.Int       `json:"bidPaid"`
+	BidRefunded *big.Int       `json:"bidRefunded"`
+	PaidBlobs   int            `json:"paidBlobs"`  // The included blobs of the transactions the provider was paid for.
+	BidPerBlob  *big.Int       `json:"bidPerBlob"` // Bid payments per paid blob, nil without paid blobs.
+}
+
+// Report holds the costs of the ledger's transactions per transaction, per day and per provider.
+type Report struct {
+	Txs       []TxCost
+	Days      []DayCost // Sorted by day, followed by the total over all days.
+	Providers []ProviderCost
+}
+
+// Build combines the entries of the ledger with the receipts of their transactions and the settlements of
+// their commitments. Transactions that were abandoned but included later are reported as included.
+//
+// Parameters:
+// - ctx: The context for the client requests.
+// - client: The L1 client the receipts are read from.
+// - entries: The entries of the ledger.
+// - settlements: The settlements of the commitments of the entries, nil if they are unknown.
+//
+// Returns:
+// - The report, or an error if a receipt cannot be read.
+func Build(ctx context.Context, client *ethclient.Client, entries []Entry, settlements []bb.Settlement) (*Report, error) {
+	// Every commitment digest belongs to one transaction and one provider
+	type commitment struct {
+		tx       int
+		provider common.Address
+	}
+	commitments := make(map[common.Hash]commitment)
+	providers := make(map[common.Address]*ProviderCost)
+	provider := func(address common.Address) *ProviderCost {
+		if providers[address] == nil {
+			providers[address] = &ProviderCost{Provider: address, BidPaid: new(big.Int), BidRefunded: new(big.Int)}
+		}
+		return providers[address]
+	}
+
+	report := &Report{}
+	for i, entry := range entries {
+		cost := TxCost{
+			Hash:              entry.Hash,
+			From:              entry.From,
+			Day:               entry.SentAt.UTC().Format("2006-01-02"),
+			Outcome:           entry.Outcome,
+			EffectiveGasPrice: new(big.Int),
+			BlobGasPrice:      new(big.Int),
+			ExecutionFee:      new(big.Int),
+			BlobFee:           new(big.Int),
+			Bids:              len(entry.Bids),
+			BidPaid:           new(big.Int),
+			BidRefunded:       new(big.Int),
+			TotalCost:         new(big.Int),
+		}
+		for _, bid := range entry.Bids {
+			for _, c := range bid.Committed {
+				commitments[c.Digest] = commitment{tx: i, provider: c.Provider}
+				provider(c.Provider).Commitments++
+				cost.Commitments++
+			}
+		}
+
+		receipt, err := client.TransactionReceipt(ctx, entry.Hash)
+		if err != nil && !errors.Is(err, ethereum.NotFound) {
+			return nil, fmt.Errorf("failed to get receipt of %s: %w", entry.Hash, err)
+		}
+		if err == nil {
+			cost.Outcome = OutcomeIncluded
+			cost.Block = receipt.BlockNumber.Uint64()
+			cost.GasUsed = receipt.GasUsed
+			cost.BlobGasUsed = receipt.BlobGasUsed
+			cost.Blobs = int(receipt.BlobGasUsed / params.BlobTxBlobGasPerBlob)
+			if receipt.EffectiveGasPrice != nil {
+				cost.EffectiveGasPrice.Set(receipt.EffectiveGasPrice)
+			}
+			if receipt.BlobGasPrice != nil {
+				cost.BlobGasPrice.Set(receipt.BlobGasPrice)
+			}
+			cost.ExecutionFee.Mul(cost.EffectiveGasPrice, new(big.Int).SetUint64(cost.GasUsed))
+			cost.BlobFee.Mul(cost.BlobGasPrice, new(big.Int).SetUint64(cost.BlobGasUsed))
+		} else if cost.Outcome == OutcomeIncluded {
+			cost.Outcome = OutcomeReplaced // Its block was reorged out and another transaction took its nonce
+		}
+		report.Txs = append(report.Txs, cost)
+	}
+
+	for _, settlement := range settlements {
+		c, ok := commitments[settlement.Digest]
+		if !ok || settlement.Amount == nil {
+			continue
+		}
+		cost := &report.Txs[c.tx]
+		switch settlement.Kind {
+		case bb.SettlementPayment:
+			cost.BidPaid.Add(cost.BidPaid, settlement.Amount)
+			cost.PaidProviders = append(cost.PaidProviders, settlement.Provider)
+			paid := provider(settlement.Provider)
+			paid.Payments++
+			paid.BidPaid.Add(paid.BidPaid, settlement.Amount)
+			paid.PaidBlobs += cost.Blobs
+		case bb.SettlementRefund:
+			cost.BidRefunded.Add(cost.BidRefunded, settlement.Amount)
+			refunded := provider(c.provider)
+			refunded.Refunds++
+			refunded.BidRefunded.Add(refunded.BidRefunded, settlement.Amount)
+		}
+	}
+
+	days := make(map[string]*DayCost)
+	total := newDayCost("total")
+	for i := range report.Txs {
+		cost := &report.Txs[i]
+		cost.TotalCost.Add(cost.ExecutionFee, cost.BlobFee)
+		cost.TotalCost.Add(cost.TotalCost, cost.BidPaid)
+		if days[cost.Day] == nil {
+			days[cost.Day] = newDayCost(cost.Day)
+		}
+		days[cost.Day].add(cost)
+		total.add(cost)
+	}
+	for _, day := range days {
+		report.Days = append(report.Days, *day.finish())
+	}
+	sort.Slice(report.Days, func(i, j int) bool { return report.Days[i].Day < report.Days[j].Day })
+	report.Days = append(report.Days, *total.finish())
+
+	for _, cost := range providers {
+		if cost.PaidBlobs > 0 {
+			cost.BidPerBlob = new(big.Int).Div(cost.BidPaid, big.NewInt(int64(cost.PaidBlobs)))
+		}
+		report.Providers = append(report.Providers, *cost)
+	}
+	sort.Slice(report.Providers, func(i, j int) bool {
+		if c := report.Providers[i].BidPaid.Cmp(report.Providers[j].BidPaid); c != 0 {
+			return c > 0
+		}
+		return report.Providers[i].Provider.Cmp(report.Providers[j].Provider) < 0
+	})
+	return report, nil
+}
+
+// Write writes one table of the report.
+//
+// Parameters:
+// - w: The destination.
+// - by: The table, ByTx, ByDay or ByProvider.
+// - format: FormatCSV or FormatJSON.
+//
+// Returns:
+// - An error if the table or the format is unknown or the report cannot be written.
+func (r *Report) Write(w io.Writer, by, format string) error {
+	var (
+		rows   interface{}
+		header []string
+		record func(i int) []string
+		count  int
+	)
+	switch by {
+	case ByTx:
+		rows, count = r.Txs, len(r.Txs)
+		header = []string{"hash", "from", "day", "outcome", "block", "blobs", "gas_used", "effective_gas_price_wei", "blob_gas_used", "blob_gas_price_wei",
+			"execution_fee_wei", "blob_fee_wei", "bids", "commitments", "bid_paid_wei", "bid_refunded_wei", "paid_providers", "total_cost_wei"}
+		record = func(i int) []string {
+			tx := r.Txs[i]
+			paid := make([]string, len(tx.PaidProviders))
+			for j, address := range tx.PaidProviders {
+				paid[j] = address.Hex()
+			}
+			return []string{tx.Hash.Hex(), tx.From.Hex(), tx.Day, tx.Outcome, fmt.Sprint(tx.Block), fmt.Sprint(tx.Blobs), fmt.Sprint(tx.GasUsed),
+				amount(tx.EffectiveGasPrice), fmt.Sprint(tx.BlobGasUsed), amount(tx.BlobGasPrice), amount(tx.ExecutionFee), amount(tx.BlobFee),
+				fmt.Sprint(tx.Bids), fmt.Sprint(tx.Commitments), amount(tx.BidPaid), amount(tx.BidRefunded), strings.Join(paid, ";"), amount(tx.TotalCost)}
+		}
+	case ByDay:
+		rows, count = r.Days, len(r.Days)
+		header = []string{"day", "txs", "included_txs", "blobs", "execution_fee_wei", "blob_fee_wei", "bid_paid_wei", "bid_refunded_wei", "total_cost_wei", "cost_per_blob_wei"}
+		record = func(i int) []string {
+			day := r.Days[i]
+			return []string{day.Day, fmt.Sprint(day.Txs), fmt.Sprint(day.IncludedTxs), fmt.Sprint(day.Blobs), amount(day.ExecutionFee), amount(day.BlobFee),
+				amount(day.BidPaid), amount(day.BidRefunded), amount(day.TotalCost), amount(day.CostPerBlob)}
+		}
+	case ByProvider:
+		rows, count = r.Providers, len(r.Providers)
+		header = []string{"provider", "commitments", "payments", "refunds", "bid_paid_wei", "bid_refunded_wei", "paid_blobs", "bid_per_blob_wei"}
+		record = func(i int) []string {
+			provider := r.Providers[i]
+			return []string{provider.Provider.Hex(), fmt.Sprint(provider.Commitments), fmt.Sprint(provider.Payments), fmt.Sprint(provider.Refunds),
+				amount(provider.BidPaid), amount(provider.BidRefunded), fmt.Sprint(provider.PaidBlobs), amount(provider.BidPerBlob)}
+		}
+	default:
+		return fmt.Errorf("unknown report table %q, use %s, %s or %s", by, ByTx, ByDay, ByProvider)
+	}
+
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+		for i := 0; i < count; i++ {
+			if err := writer.Write(record(i)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		return fmt.Errorf("unknown report format %q, use %s or %s", format, FormatCSV, FormatJSON)
+	}
+}
+
+// newDayCost creates an empty DayCost.
+func newDayCost(day string) *DayCost {
+	return &DayCost{
+		Day:          day,
+		ExecutionFee: new(big.Int),
+		BlobFee:      new(big.Int),
+		BidPaid:      new(big.Int),
+		BidRefunded:  new(big.Int),
+		TotalCost:    new(big.Int),
+	}
+}
+
+// add adds the cost of a transaction to the day.
+func (d *DayCost) add(tx *TxCost) {
+	d.Txs++
+	if tx.Outcome == OutcomeIncluded {
+		d.IncludedTxs++
+	}
+	d.Blobs += tx.Blobs
+	d.ExecutionFee.Add(d.ExecutionFee, tx.ExecutionFee)
+	d.BlobFee.Add(d.BlobFee, tx.BlobFee)
+	d.BidPaid.Add(d.BidPaid, tx.BidPaid)
+	d.BidRefunded.Add(d.BidRefunded, tx.BidRefunded)
+	d.TotalCost.Add(d.TotalCost, tx.TotalCost)
+}
+
+// finish computes the cost per blob of the day.
+func (d *DayCost) finish() *DayCost {
+	if d.Blobs > 0 {
+		d.CostPerBlob = new(big.Int).Div(d.TotalCost, big.NewInt(int64(d.Blobs)))
+	}
+	return d
+}
+
+// amount formats an amount in wei for a CSV cell, empty if it is unknown.
+func amount(wei *big.Int) string {
+	if wei == nil {
+		return ""
+	}
+	return wei.String()
+}
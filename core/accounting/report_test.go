@@ -0,0 +1,222 @@
+package accounting
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	ee "github.com/primev/preconf_blob_bidder/core/eth"
+	bb "github.com/primev/preconf_blob_bidder/core/mevcommit"
+)
+
+// newReceiptStub starts a JSON-RPC endpoint that answers eth_getTransactionReceipt with the given receipts
+// and null for other transactions, and returns a client connected to it.
+func newReceiptStub(t *testing.T, receipts map[common.Hash]*types.Receipt) *ethclient.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params []common.Hash   `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "eth_getTransactionReceipt" || len(req.Params) != 1 {
+			t.Errorf("unexpected request %s: %v", req.Method, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": receipts[req.Params[0]]})
+	}))
+	t.Cleanup(server.Close)
+	client, err := ethclient.Dial(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+// blobReceipt returns the receipt of a blob transaction included in block.
+func blobReceipt(hash common.Hash, block uint64, gasPrice int64, blobs uint64, blobGasPrice int64) *types.Receipt {
+	return &types.Receipt{
+		Type:              types.BlobTxType,
+		Status:            types.ReceiptStatusSuccessful,
+		CumulativeGasUsed: 21000,
+		Logs:              []*types.Log{},
+		TxHash:            hash,
+		GasUsed:           21000,
+		EffectiveGasPrice: big.NewInt(gasPrice),
+		BlobGasUsed:       blobs * params.BlobTxBlobGasPerBlob,
+		BlobGasPrice:      big.NewInt(blobGasPrice),
+		BlockHash:         common.BigToHash(new(big.Int).SetUint64(block)),
+		BlockNumber:       new(big.Int).SetUint64(block),
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	var (
+		included  = common.HexToHash("0x0a") // Included with two commitments, one paid and one refunded.
+		abandoned = common.HexToHash("0x0b") // Abandoned with an unsettled commitment.
+		reorged   = common.HexToHash("0x0c") // Recorded as included, but its block was reorged out.
+		late      = common.HexToHash("0x0d") // Abandoned, but included later.
+
+		p1 = common.HexToAddress("0xa1")
+		p2 = common.HexToAddress("0xa2")
+		d  = func(n int64) common.Hash { return common.BigToHash(big.NewInt(n)) }
+
+		day1 = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		day2 = time.Date(2024, 1, 2, 23, 0, 0, 0, time.UTC)
+	)
+
+	// The abandoned transaction is recorded twice, the ledger keeps its position and its latest entry
+	ledger := NewLedger(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	for _, entry := range []Entry{
+		{Hash: included, SentAt: day1, Outcome: OutcomeIncluded, Block: 100, Bids: []ee.Bid{{
+			Block: 100, Amount: big.NewInt(500), Commitments: 2,
+			Committed: []ee.BidCommitment{{Digest: d(1), Provider: p1}, {Digest: d(2), Provider: p2}},
+		}}},
+		{Hash: abandoned, SentAt: day1, Outcome: OutcomeAbandoned, Bids: []ee.Bid{{Block: 101, Amount: big.NewInt(300)}}},
+		{Hash: reorged, SentAt: day2, Outcome: OutcomeIncluded, Block: 110, Bids: []ee.Bid{{
+			Block: 110, Amount: big.NewInt(100), Commitments: 1,
+			Committed: []ee.BidCommitment{{Digest: d(4), Provider: p2}},
+		}}},
+		{Hash: late, SentAt: day2, Outcome: OutcomeAbandoned},
+		{Hash: abandoned, SentAt: day1, Outcome: OutcomeAbandoned, Bids: []ee.Bid{{
+			Block: 101, Amount: big.NewInt(300), Commitments: 1,
+			Committed: []ee.BidCommitment{{Digest: d(3), Provider: p1}},
+		}}},
+	} {
+		if err := ledger.Record(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := ReadLedger(ledger.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(Digests(entries)); got != 4 {
+		t.Fatalf("ledger holds %d commitment digests, want 4", got)
+	}
+
+	client := newReceiptStub(t, map[common.Hash]*types.Receipt{
+		included: blobReceipt(included, 100, 10, 2, 3),
+		late:     blobReceipt(late, 111, 20, 1, 1),
+	})
+	settlements := []bb.Settlement{
+		{Kind: bb.SettlementPayment, Digest: d(1), Provider: p1, Amount: big.NewInt(500)},
+		{Kind: bb.SettlementRefund, Digest: d(2), Amount: big.NewInt(200)},
+		{Kind: bb.SettlementRefund, Digest: d(4), Amount: big.NewInt(100)},
+		{Kind: bb.SettlementPayment, Digest: d(9), Provider: p2, Amount: big.NewInt(1000)}, // Not a commitment of the ledger.
+	}
+	report, err := Build(context.Background(), client, entries, settlements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blobGas := int64(params.BlobTxBlobGasPerBlob)
+	txs := []struct {
+		hash                                         common.Hash
+		outcome                                      string
+		block                                        uint64
+		blobs, commitments                           int
+		executionFee, blobFee, paid, refunded, total int64
+		paidProviders                                []common.Address
+	}{
+		{included, OutcomeIncluded, 100, 2, 2, 21000 * 10, 2 * blobGas * 3, 500, 200, 21000*10 + 2*blobGas*3 + 500, []common.Address{p1}},
+		{abandoned, OutcomeAbandoned, 0, 0, 1, 0, 0, 0, 0, 0, nil},
+		{reorged, OutcomeReplaced, 0, 0, 1, 0, 0, 0, 100, 0, nil},
+		{late, OutcomeIncluded, 111, 1, 0, 21000 * 20, blobGas, 0, 0, 21000*20 + blobGas, nil},
+	}
+	if len(report.Txs) != len(txs) {
+		t.Fatalf("report has %d transactions, want %d", len(report.Txs), len(txs))
+	}
+	for i, want := range txs {
+		got := report.Txs[i]
+		if got.Hash != want.hash || got.Outcome != want.outcome || got.Block != want.block || got.Blobs != want.blobs || got.Commitments != want.commitments {
+			t.Errorf("tx %d = %s %s block %d, %d blobs, %d commitments, want %s %s block %d, %d blobs, %d commitments", i,
+				got.Hash, got.Outcome, got.Block, got.Blobs, got.Commitments, want.hash, want.outcome, want.block, want.blobs, want.commitments)
+		}
+		checkAmounts(t, "tx "+want.hash.Hex(), map[string]*big.Int{
+			"execution fee": got.ExecutionFee, "blob fee": got.BlobFee, "bid paid": got.BidPaid, "bid refunded": got.BidRefunded, "total cost": got.TotalCost,
+		}, map[string]int64{
+			"execution fee": want.executionFee, "blob fee": want.blobFee, "bid paid": want.paid, "bid refunded": want.refunded, "total cost": want.total,
+		})
+		if len(got.PaidProviders) != len(want.paidProviders) || (len(want.paidProviders) > 0 && got.PaidProviders[0] != want.paidProviders[0]) {
+			t.Errorf("tx %s paid providers %v, want %v", want.hash, got.PaidProviders, want.paidProviders)
+		}
+	}
+
+	day1Total := 21000*10 + 2*blobGas*3 + 500
+	day2Total := 21000*20 + blobGas
+	days := []struct {
+		day                                                   string
+		txs, included, blobs                                  int
+		executionFee, blobFee, paid, refunded, total, perBlob int64
+	}{
+		{"2024-01-01", 2, 1, 2, 21000 * 10, 2 * blobGas * 3, 500, 200, day1Total, day1Total / 2},
+		{"2024-01-02", 2, 1, 1, 21000 * 20, blobGas, 0, 100, day2Total, day2Total},
+		{"total", 4, 2, 3, 21000 * 30, 7 * blobGas, 500, 300, day1Total + day2Total, (day1Total + day2Total) / 3},
+	}
+	if len(report.Days) != len(days) {
+		t.Fatalf("report has %d days, want %d", len(report.Days), len(days))
+	}
+	for i, want := range days {
+		got := report.Days[i]
+		if got.Day != want.day || got.Txs != want.txs || got.IncludedTxs != want.included || got.Blobs != want.blobs {
+			t.Errorf("day %d = %s with %d txs, %d included, %d blobs, want %s with %d, %d, %d", i,
+				got.Day, got.Txs, got.IncludedTxs, got.Blobs, want.day, want.txs, want.included, want.blobs)
+		}
+		checkAmounts(t, "day "+want.day, map[string]*big.Int{
+			"execution fee": got.ExecutionFee, "blob fee": got.BlobFee, "bid paid": got.BidPaid, "bid refunded": got.BidRefunded,
+			"total cost": got.TotalCost, "cost per blob": got.CostPerBlob,
+		}, map[string]int64{
+			"execution fee": want.executionFee, "blob fee": want.blobFee, "bid paid": want.paid, "bid refunded": want.refunded,
+			"total cost": want.total, "cost per blob": want.perBlob,
+		})
+	}
+
+	providers := []struct {
+		provider                                  common.Address
+		commitments, payments, refunds, paidBlobs int
+		paid, refunded                            int64
+		perBlob                                   *big.Int
+	}{
+		{p1, 2, 1, 0, 2, 500, 0, big.NewInt(250)},
+		{p2, 2, 0, 2, 0, 0, 300, nil},
+	}
+	if len(report.Providers) != len(providers) {
+		t.Fatalf("report has %d providers, want %d", len(report.Providers), len(providers))
+	}
+	for i, want := range providers {
+		got := report.Providers[i]
+		if got.Provider != want.provider || got.Commitments != want.commitments || got.Payments != want.payments || got.Refunds != want.refunds || got.PaidBlobs != want.paidBlobs {
+			t.Errorf("provider %d = %+v, want %+v", i, got, want)
+		}
+		checkAmounts(t, "provider "+want.provider.Hex(), map[string]*big.Int{
+			"bid paid": got.BidPaid, "bid refunded": got.BidRefunded,
+		}, map[string]int64{
+			"bid paid": want.paid, "bid refunded": want.refunded,
+		})
+		if (got.BidPerBlob == nil) != (want.perBlob == nil) || (want.perBlob != nil && got.BidPerBlob.Cmp(want.perBlob) != 0) {
+			t.Errorf("provider %s bid per blob %v, want %v", want.provider, got.BidPerBlob, want.perBlob)
+		}
+	}
+}
+
+// checkAmounts compares the named amounts of a report row with the expected ones.
+func checkAmounts(t *testing.T, row string, got map[string]*big.Int, want map[string]int64) {
+	t.Helper()
+	for name, amount := range want {
+		if got[name] == nil || got[name].Cmp(big.NewInt(amount)) != 0 {
+			t.Errorf("%s %s = %v, want %d", row, name, got[name], amount)
+		}
+	}
+}
@@ -0,0 +1,551 @@
+// Package config loads the settings of the bidder from a YAML or TOML file, the environment and the
+// command line, in increasing order of precedence, and validates them.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ethereum/go-ethereum/common"
+	ee "github.com/primev/preconf_blob_bidder/core/eth"
+	"github.com/primev/preconf_blob_bidder/core/logging"
+	bb "github.com/primev/preconf_blob_bidder/core/mevcommit"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the name of every environment variable read by Load, so that generic variables such as
+// KEYS or OFFSET do not change the settings.
+const EnvPrefix = "BIDDER_"
+
+// MaxFeeBumps is the largest accepted max_fee_bumps. Every bump doubles the fees, so the limit already
+// allows fees 1024 times the suggested ones.
+const MaxFeeBumps = 10
+
+// Config holds every setting of the bidder. The yaml key of a field also names its flag, with dashes
+// instead of underscores, and its environment variable, in upper case with the EnvPrefix. Fields tagged
+// secret are redacted when the config is printed, fields tagged secret:"url" only lose the credentials in
+// their URLs. Fields tagged env:"-" are never read from the environment.
+type Config struct {
+	// Endpoints
+	RPCEndpoints       []string `yaml:"rpc_endpoints" toml:"rpc_endpoints" secret:"url" usage:"Ethereum client endpoints"`
+	SubmitEndpoints    []string `yaml:"submit_endpoints" toml:"submit_endpoints" secret:"url" usage:"[method=]url endpoints that receive every transaction, method is raw, bundle or private (default bundle). Defaults to a bundle endpoint per rpc endpoint"`
+	WSEndpoint         []string `yaml:"ws_endpoint" toml:"ws_endpoint" secret:"url" usage:"Ethereum client WebSocket endpoints, all subscribed to for new heads"`
+	SimulationEndpoint string   `yaml:"simulation_endpoint" toml:"simulation_endpoint" secret:"url" usage:"Endpoint that simulates every bundle with eth_callBundle before submission (empty disables simulation)"`
+	BidderAddress      string   `yaml:"bidder_address" toml:"bidder_address" usage:"gRPC address of the mev-commit bidder node"`
+	MevCommitEndpoint  string   `yaml:"mev_commit_endpoint" toml:"mev_commit_endpoint" secret:"url" usage:"RPC endpoint of the mev-commit chain, used to read the bidding window and the provider registry"`
+	BundleSigningKey   string   `yaml:"bundle_signing_key" toml:"bundle_signing_key" usage:"Path to a file holding the hex searcher reputation key that signs bundles in the X-Flashbots-Signature header"`
+
+	// Accounts
+	PrivateKey           string   `yaml:"privatekey" toml:"privatekey" secret:"true" env:"-" usage:"The private key in hex format, requires dev-raw-keys (for local testing only, use keystore or remote-signer)"`
+	Keystore             string   `yaml:"keystore" toml:"keystore" usage:"Path to an encrypted keystore JSON file or a keystore directory"`
+	PasswordFile         string   `yaml:"password_file" toml:"password_file" usage:"Path to the file holding the keystore password"`
+	RemoteSigner         string   `yaml:"remote_signer" toml:"remote_signer" secret:"url" usage:"JSON-RPC endpoint of a Web3Signer or Clef remote signer"`
+	RemoteSignerAccounts []string `yaml:"remote_signer_accounts" toml:"remote_signer_accounts" usage:"Account addresses held by the remote signer"`
+	RemoteSignerMethod   string   `yaml:"remote_signer_method" toml:"remote_signer_method" usage:"Signing method of the remote signer, eth_signTransaction for Web3Signer or account_signTransaction for Clef"`
+	Keys                 string   `yaml:"keys" toml:"keys" usage:"Path to a file with one hex private key per line, or a directory with one key file per account, requires dev-raw-keys (for local testing only)"`
+	DevRawKeys           bool     `yaml:"dev_raw_keys" toml:"dev_raw_keys" usage:"Accept the unencrypted keys of privatekey and keys, for local testing only"`
+	AccountSelection     string   `yaml:"account_selection" toml:"account_selection" usage:"How to pick the sending account: round-robin or balance"`
+
+	// Transactions
+	Offset       uint64  `yaml:"offset" toml:"offset" usage:"Number of blocks to delay the transaction"`
+	UsePayload   bool    `yaml:"use_payload" toml:"use_payload" usage:"Send transactions to mev-commit as payload instead of transaction hashes"`
+	MinBlobs     int     `yaml:"min_blobs" toml:"min_blobs" usage:"Minimum number of blobs per transaction"`
+	MaxBlobs     int     `yaml:"max_blobs" toml:"max_blobs" usage:"Maximum number of blobs per transaction"`
+	MaxBlobFee   uint64  `yaml:"max_blob_fee" toml:"max_blob_fee" usage:"Blob base fee in wei above which only min-blobs are sent (0 disables the limit)"`
+	PayloadBytes int     `yaml:"payload_bytes" toml:"payload_bytes" usage:"Size in bytes of the payload to post, split across transactions as needed (0 fills one transaction per block)"`
+	BlobBudget   float64 `yaml:"blob_budget" toml:"blob_budget" usage:"Deprecated, use max_fee_spend. Used as max_fee_spend when that is 0"`
+
+	// Fees
+	FeeStrategy       string  `yaml:"fee_strategy" toml:"fee_strategy" usage:"How gas fees are picked: feehistory, basefee or fixed"`
+	FeeHistoryBlocks  uint64  `yaml:"fee_history_blocks" toml:"fee_history_blocks" usage:"Number of recent blocks sampled by the feehistory strategy"`
+	FeePercentile     float64 `yaml:"fee_percentile" toml:"fee_percentile" usage:"Priority fee percentile used by the feehistory strategy"`
+	FeeHeadroomBlocks int     `yaml:"fee_headroom_blocks" toml:"fee_headroom_blocks" usage:"Number of full blocks the fee cap must survive (feehistory and basefee strategies)"`
+	TipGwei           float64 `yaml:"tip_gwei" toml:"tip_gwei" usage:"Priority fee in gwei for the basefee and fixed strategies (0 asks the node for basefee)"`
+	FeeCapGwei        float64 `yaml:"fee_cap_gwei" toml:"fee_cap_gwei" usage:"Fee cap in gwei for the fixed strategy"`
+	MaxTipGwei        float64 `yaml:"max_tip_gwei" toml:"max_tip_gwei" usage:"Upper bound in gwei on the priority fee of the feehistory strategy (0 disables it)"`
+	BlobFeeBlocks     uint64  `yaml:"blob_fee_blocks" toml:"blob_fee_blocks" usage:"Number of blocks of maximum blob usage a transaction's blob fee cap must survive"`
+	MaxFeeBumps       int     `yaml:"max_fee_bumps" toml:"max_fee_bumps" usage:"Max number of times the fees of an account are doubled to replace a pending transaction (at most 10)"`
+
+	// Bundles and bids
+	BundleBlocks             uint64        `yaml:"bundle_blocks" toml:"bundle_blocks" usage:"Number of consecutive blocks every bundle is submitted for until the transaction is included"`
+	BundleLookahead          uint64        `yaml:"bundle_lookahead" toml:"bundle_lookahead" usage:"Number of upcoming target blocks whose bundles are submitted in advance"`
+	BundleMinTimestampOffset time.Duration `yaml:"bundle_min_timestamp_offset" toml:"bundle_min_timestamp_offset" usage:"Offset from the expected time of a bundle's target block to the earliest block time it is valid for, for example -12s (0 leaves it unset)"`
+	BundleMaxTimestampOffset time.Duration `yaml:"bundle_max_timestamp_offset" toml:"bundle_max_timestamp_offset" usage:"Offset from the expected time of a bundle's target block to the latest block time it is valid for, for example 24s (0 leaves it unset)"`
+	MinCoinbaseDiffGwei      float64       `yaml:"min_coinbase_diff_gwei" toml:"min_coinbase_diff_gwei" usage:"Minimum payment in gwei a simulated bundle must make to the builder, bundles below it are repriced once or refused"`
+	MaxRepricePercent        int64         `yaml:"max_reprice_percent" toml:"max_reprice_percent" usage:"Max scale in percent applied to the fees of a bundle that underpays in simulation"`
+	PollBundleStatus         bool          `yaml:"poll_bundle_status" toml:"poll_bundle_status" usage:"Poll relays with flashbots_getBundleStatsV2 to learn how far each bundle got"`
+	RateLimitBackoffBlocks   uint64        `yaml:"rate_limit_backoff_blocks" toml:"rate_limit_backoff_blocks" usage:"Number of blocks a rate limited endpoint is skipped for"`
+	MaxPreconfAttempts       int           `yaml:"max_preconf_attempts" toml:"max_preconf_attempts" usage:"Number of preconfirmation bids after which a pending transaction is abandoned"`
+
+	// Budget
+	MaxBidSpend       float64       `yaml:"max_bid_spend" toml:"max_bid_spend" usage:"Maximum amount of ETH bid for preconfirmations that received a commitment, the loop stops once it is reached (0 disables the limit)"`
+	MaxFeeSpend       float64       `yaml:"max_fee_spend" toml:"max_fee_spend" usage:"Maximum amount of ETH paid in execution and blob fees by included transactions, plus the worst-case fees of those in flight, the loop stops once it is reached (0 disables the limit)"`
+	MaxTxs            int           `yaml:"max_txs" toml:"max_txs" usage:"Number of blob transactions after which the loop stops (0 disables the limit)"`
+	MaxWindowBidSpend float64       `yaml:"max_window_bid_spend" toml:"max_window_bid_spend" usage:"Maximum amount of ETH bid per bidding window, new transactions and bids pause until the next window once it is reached (0 disables the limit)"`
+	BlocksPerWindow   uint64        `yaml:"blocks_per_window" toml:"blocks_per_window" usage:"Number of L1 blocks per mev-commit bidding window"`
+	RunDuration       time.Duration `yaml:"run_duration" toml:"run_duration" usage:"Time after which the loop stops (0 runs until stopped)"`
+
+	// Chain and RPC health
+	RPCCheckInterval  time.Duration `yaml:"rpc_check_interval" toml:"rpc_check_interval" usage:"Interval between health checks of the rpc endpoints"`
+	RPCMaxLag         uint64        `yaml:"rpc_max_lag" toml:"rpc_max_lag" usage:"Number of blocks an rpc endpoint may trail the most advanced endpoint before it is unhealthy"`
+	RPCMaxLatency     time.Duration `yaml:"rpc_max_latency" toml:"rpc_max_latency" usage:"Slowest health check response accepted from a healthy rpc endpoint"`
+	RPCTimeout        time.Duration `yaml:"rpc_timeout" toml:"rpc_timeout" usage:"Timeout of rpc health checks and bidder node requests"`
+	ReconnectInterval time.Duration `yaml:"reconnect_interval" toml:"reconnect_interval" usage:"Interval to wait before resubscribing to a failed WebSocket endpoint"`
+	HeadPollInterval  time.Duration `yaml:"head_poll_interval" toml:"head_poll_interval" usage:"Interval between polls for new heads while no subscription delivers"`
+	HeadStaleAfter    time.Duration `yaml:"head_stale_after" toml:"head_stale_after" usage:"Time without a new head after which the rpc endpoints are polled"`
+	ReorgWindow       uint64        `yaml:"reorg_window" toml:"reorg_window" usage:"Number of recent blocks watched for reorgs"`
+
+	// Operations
+	ShutdownTimeout  time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" usage:"Time the bids and submissions in flight get to finish after SIGINT or SIGTERM"`
+	JournalFile      string        `yaml:"journal_file" toml:"journal_file" usage:"File the pending transactions are persisted to and resumed from after a restart (empty disables the journal)"`
+	LedgerFile       string        `yaml:"ledger_file" toml:"ledger_file" usage:"File every settled transaction is appended to with its bids, read by the report command (empty disables the ledger)"`
+	MetricsAddr      string        `yaml:"metrics_addr" toml:"metrics_addr" usage:"Address to serve Prometheus metrics on at /metrics and health checks at /healthz and /readyz, for example :9090 (empty disables the server)"`
+	HealthMaxHeadAge time.Duration `yaml:"health_max_head_age" toml:"health_max_head_age" usage:"Time without a new head after which /healthz fails"`
+	HealthMinDeposit float64       `yaml:"health_min_deposit" toml:"health_min_deposit" usage:"Bidder deposit in ETH below which /readyz fails (0 only reports the deposit)"`
+	HealthMaxBidAge  time.Duration `yaml:"health_max_bid_age" toml:"health_max_bid_age" usage:"Time without a bid that received a commitment after which /readyz fails (0 only reports the last bid)"`
+	LogFmt           string        `yaml:"log_fmt" toml:"log_fmt" usage:"Log format: text or json"`
+	LogLevel         string        `yaml:"log_level" toml:"log_level" usage:"Log level: trace, debug, info, warn, error or crit"`
+	OTLPEndpoint     string        `yaml:"otlp_endpoint" toml:"otlp_endpoint" secret:"url" usage:"OTLP/HTTP collector that receives the traces of every slot, as host:port or URL (empty disables tracing)"`
+}
+
+// Default returns the settings used for everything the file, the environment and the flags leave unset.
+func Default() Config {
+	return Config{
+		BidderAddress:          "127.0.0.1:13524",
+		RemoteSignerMethod:     "eth_signTransaction",
+		AccountSelection:       ee.SelectRoundRobin,
+		Offset:                 1,
+		MinBlobs:               1,
+		MaxBlobs:               ee.MaxBlobsPerBlock,
+		FeeStrategy:            ee.FeeStrategyFeeHistory,
+		FeeHistoryBlocks:       10,
+		FeePercentile:          60,
+		FeeHeadroomBlocks:      3,
+		MaxTipGwei:             10,
+		BlobFeeBlocks:          3,
+		MaxFeeBumps:            3,
+		BundleBlocks:           3,
+		BundleLookahead:        1,
+		MaxRepricePercent:      400,
+		PollBundleStatus:       true,
+		RateLimitBackoffBlocks: 5,
+		MaxPreconfAttempts:     50,
+		BlocksPerWindow:        10,
+		RunDuration:            14 * 24 * time.Hour,
+		RPCCheckInterval:       12 * time.Second,
+		RPCMaxLag:              2,
+		RPCMaxLatency:          2 * time.Second,
+		RPCTimeout:             5 * time.Second,
+		ReconnectInterval:      30 * time.Second,
+		HeadPollInterval:       2 * time.Second,
+		HeadStaleAfter:         24 * time.Second,
+		ReorgWindow:            64,
+		ShutdownTimeout:        20 * time.Second,
+		JournalFile:            "data/pending.json",
+		LedgerFile:             "data/ledger.jsonl",
+		HealthMaxHeadAge:       60 * time.Second,
+		LogFmt:                 logging.FormatText,
+		LogLevel:               "info",
+	}
+}
+
+// FeeSpendLimit returns the maximum amount of ETH paid in fees, max_fee_spend or, when that is 0, the
+// deprecated blob_budget.
+func (c *Config) FeeSpendLimit() float64 {
+	if c.MaxFeeSpend > 0 {
+		return c.MaxFeeSpend
+	}
+	return c.BlobBudget
+}
+
+// BidderConfig returns the settings of the connection to the mev-commit bidder node.
+func (c *Config) BidderConfig() bb.BidderConfig {
+	return bb.BidderConfig{ServerAddress: c.BidderAddress, LogFmt: c.LogFmt, LogLevel: c.LogLevel}
+}
+
+// RegisterFlags adds a flag for every setting and the config flag to fs. The flags do not change c when
+// they are parsed, Load applies them after the file and the environment.
+//
+// Parameters:
+// - fs: The flag set to add the flags to.
+//
+// Returns:
+// - The value of the config flag, the path of the config file.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) *string {
+	path := fs.String("config", "", "Path to a YAML (.yaml, .yml) or TOML (.toml) config file. Values from the environment and flags override it")
+	forEachField(c, func(key string, field reflect.Value, tag reflect.StructTag) {
+		fs.Var(&fieldFlag{field: field, def: formatValue(field)}, flagName(key), tag.Get("usage"))
+	})
+	return path
+}
+
+// Load builds the effective settings: the defaults, then the config file, then the environment, then the
+// flags that were set on the command line. The result is validated.
+//
+// Parameters:
+// - fs: The parsed flag set, with the flags added by RegisterFlags.
+// - path: The path of the config file. Empty skips the file.
+// - lookupEnv: Looks up environment variables, usually os.LookupEnv. Nil skips the environment.
+//
+// Returns:
+// - The effective settings, or an error if a source holds an invalid value or validation fails.
+func Load(fs *flag.FlagSet, path string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if lookupEnv != nil {
+		if err := cfg.applyEnv(lookupEnv); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.applyFlags(fs); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// loadFile decodes a YAML or TOML file, picked by its extension, over the current settings.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), c)
+		if err != nil {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("invalid config file %s: unknown keys %v", path, undecoded)
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension %q, use .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	return nil
+}
+
+// applyEnv sets every setting whose environment variable is set. Settings tagged env:"-" fail if their
+// variable is set, so that raw private keys do not leak through the environment of the process.
+func (c *Config) applyEnv(lookupEnv func(string) (string, bool)) error {
+	var errs []error
+	forEachField(c, func(key string, field reflect.Value, tag reflect.StructTag) {
+		name := envName(key)
+		if value, ok := lookupEnv(name); ok {
+			if tag.Get("env") == "-" {
+				errs = append(errs, fmt.Errorf("environment variable %s is not supported, use keystore or remote_signer", name))
+				return
+			}
+			if err := setValue(field, value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid environment variable %s: %w", name, err))
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// applyFlags sets every setting whose flag was set on the command line.
+func (c *Config) applyFlags(fs *flag.FlagSet) error {
+	fields := make(map[string]reflect.Value)
+	forEachField(c, func(key string, field reflect.Value, _ reflect.StructTag) {
+		fields[flagName(key)] = field
+	})
+
+	var errs []error
+	fs.Visit(func(f *flag.Flag) {
+		ff, ok := f.Value.(*fieldFlag)
+		if !ok {
+			return
+		}
+		for _, value := range ff.values {
+			if err := setValue(fields[f.Name], value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid flag -%s: %w", f.Name, err))
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// Require checks that the given settings are set, which the commands that use them call after Load.
+//
+// Parameters:
+// - keys: The yaml keys of the required settings.
+//
+// Returns:
+// - An error naming every required setting that is empty.
+func (c *Config) Require(keys ...string) error {
+	required := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		required[key] = true
+	}
+	var errs []error
+	forEachField(c, func(key string, field reflect.Value, _ reflect.StructTag) {
+		if required[key] && (field.IsZero() || field.Kind() == reflect.Slice && field.Len() == 0) {
+			errs = append(errs, fmt.Errorf("%s is required", key))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// RequireAccounts checks that at least one source of sending accounts is set.
+func (c *Config) RequireAccounts() error {
+	if c.PrivateKey == "" && c.Keys == "" && c.Keystore == "" && c.RemoteSigner == "" {
+		return errors.New("no sending account, set keystore or remote_signer (privatekey and keys require dev_raw_keys and are for local testing only)")
+	}
+	return nil
+}
+
+// Validate checks that the settings are consistent. Whether the settings a command needs are set is
+// checked by Require and RequireAccounts.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.DevRawKeys || c.PrivateKey == "" && c.Keys == "", "privatekey and keys hold unencrypted keys, use keystore or remote_signer, or set dev_raw_keys for local testing")
+	check(c.Keystore == "" || c.PasswordFile != "", "keystore requires password_file")
+	check(c.RemoteSigner == "" || len(c.RemoteSignerAccounts) > 0, "remote_signer requires remote_signer_accounts")
+	for _, address := range c.RemoteSignerAccounts {
+		check(common.IsHexAddress(address), "invalid remote_signer_accounts address %q", address)
+	}
+	check(c.RemoteSignerMethod == "eth_signTransaction" || c.RemoteSignerMethod == "account_signTransaction",
+		"remote_signer_method must be eth_signTransaction or account_signTransaction, got %q", c.RemoteSignerMethod)
+	check(c.AccountSelection == ee.SelectRoundRobin || c.AccountSelection == ee.SelectByBalance,
+		"account_selection must be %s or %s, got %q", ee.SelectRoundRobin, ee.SelectByBalance, c.AccountSelection)
+	check(c.BidderAddress != "", "bidder_address is required")
+
+	check(c.MinBlobs >= 1 && c.MinBlobs <= c.MaxBlobs && c.MaxBlobs <= ee.MaxBlobsPerBlock,
+		"blobs per transaction must satisfy 1 <= min_blobs <= max_blobs <= %d, got %d and %d", ee.MaxBlobsPerBlock, c.MinBlobs, c.MaxBlobs)
+	check(c.PayloadBytes >= 0, "payload_bytes must not be negative")
+	check(c.BlobBudget >= 0, "blob_budget must not be negative")
+	check(c.FeePercentile >= 0 && c.FeePercentile <= 100, "fee_percentile must be between 0 and 100, got %v", c.FeePercentile)
+	check(c.FeeHeadroomBlocks >= 0, "fee_headroom_blocks must not be negative")
+	check(c.TipGwei >= 0 && c.FeeCapGwei >= 0 && c.MaxTipGwei >= 0 && c.MinCoinbaseDiffGwei >= 0, "gwei amounts must not be negative")
+	check(c.MaxFeeBumps >= 0 && c.MaxFeeBumps <= MaxFeeBumps, "max_fee_bumps must be between 0 and %d, got %d", MaxFeeBumps, c.MaxFeeBumps)
+	check(c.BundleBlocks >= 1, "bundle_blocks must be at least 1")
+	check(c.BundleLookahead >= 1, "bundle_lookahead must be at least 1")
+	check(c.BundleMinTimestampOffset == 0 || c.BundleMaxTimestampOffset == 0 || c.BundleMinTimestampOffset <= c.BundleMaxTimestampOffset,
+		"bundle_min_timestamp_offset must not be after bundle_max_timestamp_offset")
+	check(c.MaxRepricePercent >= 100, "max_reprice_percent must be at least 100, got %d", c.MaxRepricePercent)
+	check(c.MaxPreconfAttempts >= 1, "max_preconf_attempts must be at least 1")
+	check(c.MaxBidSpend >= 0 && c.MaxFeeSpend >= 0 && c.MaxWindowBidSpend >= 0, "spend limits must not be negative")
+	check(c.MaxTxs >= 0, "max_txs must not be negative")
+	check(c.BlocksPerWindow >= 1, "blocks_per_window must be at least 1")
+	check(c.RunDuration >= 0, "run_duration must not be negative")
+
+	for key, d := range map[string]time.Duration{
+		"rpc_check_interval":  c.RPCCheckInterval,
+		"rpc_timeout":         c.RPCTimeout,
+		"reconnect_interval":  c.ReconnectInterval,
+		"head_poll_interval":  c.HeadPollInterval,
+		"head_stale_after":    c.HeadStaleAfter,
+		"health_max_head_age": c.HealthMaxHeadAge,
+		"shutdown_timeout":    c.ShutdownTimeout,
+	} {
+		check(d > 0, "%s must be positive", key)
+	}
+	check(c.ReorgWindow >= 1, "reorg_window must be at least 1")
+	check(c.HealthMinDeposit >= 0, "health_min_deposit must not be negative")
+	check(c.LogFmt == logging.FormatText || c.LogFmt == logging.FormatJSON, "log_fmt must be %s or %s, got %q", logging.FormatText, logging.FormatJSON, c.LogFmt)
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy of the settings that is safe to print: secrets are replaced and endpoint
+// URLs lose their passwords and query parameters, which often hold API keys.
+func (c Config) Redacted() Config {
+	forEachField(&c, func(_ string, field reflect.Value, tag reflect.StructTag) {
+		switch tag.Get("secret") {
+		case "true":
+			if !field.IsZero() {
+				field.SetString("REDACTED")
+			}
+		case "url":
+			if field.Kind() == reflect.Slice && field.Len() > 0 {
+				redacted := make([]string, field.Len())
+				for i := range redacted {
+					redacted[i] = RedactURL(field.Index(i).String())
+				}
+				field.Set(reflect.ValueOf(redacted))
+			} else if field.Kind() == reflect.String {
+				field.SetString(RedactURL(field.String()))
+			}
+		}
+	})
+	return c
+}
+
+// Print writes the redacted settings as YAML, in the format of a config file.
+func (c *Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// RedactURL removes the password and the query values of an endpoint URL, keeping the method prefix
+// of submission endpoint specs.
+func RedactURL(spec string) string {
+	prefix, raw := "", spec
+	if name, rest, ok := strings.Cut(spec, "="); ok && !strings.Contains(name, "/") {
+		prefix, raw = name+"=", rest
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return spec
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "REDACTED")
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			query.Set(key, "REDACTED")
+		}
+		u.RawQuery = query.Encode()
+	}
+	return prefix + u.String()
+}
+
+// fieldFlag is the flag of one setting. It records the values it is set to, which applyFlags applies
+// once the file and the environment were loaded.
+type fieldFlag struct {
+	field  reflect.Value
+	def    string
+	values []string
+}
+
+// String implements flag.Value.
+func (f *fieldFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.def
+}
+
+// Set implements flag.Value. The value is checked right away so that invalid flags fail during parsing.
+func (f *fieldFlag) Set(value string) error {
+	if err := setValue(reflect.New(f.field.Type()).Elem(), value); err != nil {
+		return err
+	}
+	f.values = append(f.values, value)
+	return nil
+}
+
+// IsBoolFlag lets boolean settings be set without a value.
+func (f *fieldFlag) IsBoolFlag() bool {
+	return f.field.Kind() == reflect.Bool
+}
+
+// forEachField calls fn for every setting of c with its yaml key, its settable value and its tags.
+func forEachField(c *Config, fn func(key string, field reflect.Value, tag reflect.StructTag)) {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag
+		fn(tag.Get("yaml"), v.Field(i), tag)
+	}
+}
+
+// flagName returns the name of the flag of a setting.
+func flagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+// envName returns the name of the environment variable of a setting. Keys that already start with the
+// prefix are not prefixed twice, which keeps BIDDER_ADDRESS working.
+func envName(key string) string {
+	name := strings.ToUpper(key)
+	if strings.HasPrefix(name, EnvPrefix) {
+		return name
+	}
+	return EnvPrefix + name
+}
+
+// setValue parses a flag or environment value into a setting. Lists are comma-separated.
+func setValue(field reflect.Value, value string) error {
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(value)
+	case []string:
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+	case int, int64:
+		n, err := strconv.ParseInt(value, 0, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case uint64:
+		n, err := strconv.ParseUint(value, 0, 64)
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+// formatValue formats a setting the way setValue parses it.
+func formatValue(field reflect.Value) string {
+	switch v := field.Interface().(type) {
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
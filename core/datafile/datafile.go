@@ -0,0 +1,184 @@
+// Package datafile reads and writes the JSON files of the data folder. Files are replaced atomically, so
+// that a process killed in the middle of a write leaves either the old or the new content behind. Files that
+// only grow hold one JSON value per line and are appended to instead.
+package datafile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// WriteJSON writes v as indented JSON to a temporary file next to path, syncs it and renames it over path.
+// The directory of path is created if it does not exist.
+//
+// Parameters:
+// - path: The file to replace.
+// - v: The value to encode.
+//
+// Returns:
+// - An error if the value cannot be encoded or the file cannot be written.
+func WriteJSON(path string, v interface{}) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once the file was renamed
+
+	encoder := json.NewEncoder(tmp)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// ReadJSON decodes the JSON file at path into v. A missing file leaves v unchanged.
+//
+// Parameters:
+// - path: The file to read.
+// - v: A pointer to the value to decode into.
+//
+// Returns:
+// - Whether the file exists, or an error if it cannot be read or decoded.
+func ReadJSON(path string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return true, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// AppendJSONLine appends v as a single line of JSON to path and syncs the file. The file and its directory
+// are created if they do not exist. The remainder of an interrupted append is removed first, so that the new
+// line does not continue it.
+//
+// Parameters:
+// - path: The file to append to.
+// - v: The value to encode.
+//
+// Returns:
+// - An error if the value cannot be encoded or the file cannot be written.
+func AppendJSONLine(path string, v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	if err := trimPartialLine(file); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to repair %s: %w", path, err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to append to %s: %w", path, err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+// ReadJSONLines calls decode with every line of the file at path. A missing file has no lines. A last line
+// without a line break is the remainder of an interrupted append and is skipped.
+//
+// Parameters:
+// - path: The file to read.
+// - decode: Decodes one line, an error stops the reading.
+//
+// Returns:
+// - An error if the file cannot be read or a line cannot be decoded.
+func ReadJSONLines(path string, decode func(line []byte) error) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, 64<<10)
+	for number := 1; ; number++ {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return nil // Empty, or an interrupted append
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if err := decode(line); err != nil {
+			return fmt.Errorf("failed to decode line %d of %s: %w", number, path, err)
+		}
+	}
+}
+
+// trimPartialLine truncates file after its last line break, dropping a last line without one.
+func trimPartialLine(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	end := info.Size()
+	buf := make([]byte, 4096)
+	for offset := end; offset > 0; {
+		n := int64(len(buf))
+		if offset < n {
+			n = offset
+		}
+		offset -= n
+		if _, err := file.ReadAt(buf[:n], offset); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			if keep := offset + int64(i) + 1; keep < end {
+				return file.Truncate(keep)
+			}
+			return nil
+		}
+	}
+	if end > 0 {
+		return file.Truncate(0)
+	}
+	return nil
+}
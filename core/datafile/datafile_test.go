@@ -0,0 +1,124 @@
+package datafile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type record struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestWriteJSONReplaces(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nested", "state.json")
+
+	var got []record
+	if exists, err := ReadJSON(path, &got); exists || err != nil {
+		t.Fatalf("ReadJSON of a missing file = %v, %v, want false, nil", exists, err)
+	}
+
+	if err := WriteJSON(path, []record{{1, "old"}, {2, "old"}}); err != nil {
+		t.Fatal(err)
+	}
+	want := []record{{3, "new"}}
+	if err := WriteJSON(path, want); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := ReadJSON(path, &got); !exists || err != nil {
+		t.Fatalf("ReadJSON = %v, %v", exists, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("read %v, want %v", got, want)
+	}
+
+	files, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("directory holds %d files, want only the replaced file", len(files))
+	}
+}
+
+func TestReadJSONInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(`[{"id": 1`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var got []record
+	if exists, err := ReadJSON(path, &got); !exists || err == nil {
+		t.Errorf("ReadJSON of a truncated file = %v, %v, want an error", exists, err)
+	}
+}
+
+// readLines decodes every line of the file at path.
+func readLines(t *testing.T, path string) []record {
+	t.Helper()
+	var records []record
+	err := ReadJSONLines(path, func(line []byte) error {
+		var r record
+		if err := json.Unmarshal(line, &r); err != nil {
+			return err
+		}
+		records = append(records, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestAppendJSONLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "ledger.jsonl")
+	if got := readLines(t, path); len(got) != 0 {
+		t.Fatalf("read %v from a missing file, want nothing", got)
+	}
+
+	want := []record{{1, "a"}, {2, "b"}, {3, "c"}}
+	for _, r := range want {
+		if err := AppendJSONLine(path, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := readLines(t, path); !reflect.DeepEqual(got, want) {
+		t.Errorf("read %v, want %v", got, want)
+	}
+}
+
+func TestAppendJSONLineAfterInterruptedAppend(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+		want    []record
+	}{
+		{name: "partial first line", content: `{"id":1,"na`, want: nil},
+		{name: "partial last line", content: "{\"id\":1,\"name\":\"a\"}\n{\"id\":2,\"na", want: []record{{1, "a"}}},
+		{name: "long partial line", content: "{\"id\":1,\"name\":\"a\"}\n{\"id\":2,\"name\":\"" + strings.Repeat("x", 10000), want: []record{{1, "a"}}},
+		{name: "complete lines", content: "{\"id\":1,\"name\":\"a\"}\n", want: []record{{1, "a"}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ledger.jsonl")
+			if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if got := readLines(t, path); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("read %v before appending, want %v", got, tc.want)
+			}
+
+			if err := AppendJSONLine(path, record{9, "z"}); err != nil {
+				t.Fatal(err)
+			}
+			want := append(tc.want, record{9, "z"})
+			if got := readLines(t, path); !reflect.DeepEqual(got, want) {
+				t.Errorf("read %v after appending, want %v", got, want)
+			}
+		})
+	}
+}
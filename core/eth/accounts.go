@@ -0,0 +1,312 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/primev/preconf_blob_bidder/core/logging"
+	bb "github.com/primev/preconf_blob_bidder/core/mevcommit"
+)
+
+// ErrNoFreeAccount is returned by AccountPool.Next when every account has a transaction in flight.
+var ErrNoFreeAccount = errors.New("no account without a pending transaction")
+
+// Account selection strategies supported by AccountPool.
+const (
+	SelectRoundRobin = "round-robin" // Cycle through the free accounts in order.
+	SelectByBalance  = "balance"     // Pick the free account with the highest balance and no unknown mempool transactions.
+)
+
+// PendingTx tracks a blob transaction that was sent and is waiting for inclusion.
+type PendingTx struct {
+	From        common.Address     // The account that sent the transaction.
+	Hash        common.Hash        // The hash of the transaction.
+	Nonce       uint64             // The nonce of the transaction.
+	TargetBlock uint64             // The block the transaction and its first bid targeted.
+	SentAt      time.Time          // The time the transaction was sent.
+	Preconfs    int                // The number of preconfirmation bids sent for the transaction.
+	Bundles     []BundleStatus     // What the relays reported about the bundles of the transaction.
+	Bids        []Bid              // The preconfirmation bids sent for the transaction.
+	Tx          *types.Transaction // The signed transaction with its blob sidecar, used to resubmit it after a restart.
+}
+
+// ConfirmedTx is a transaction that was included in a recent block, kept until the block is deep enough
+// that a reorg is unlikely to drop it.
+type ConfirmedTx struct {
+	PendingTx
+	Block     uint64      // The number of the block the transaction was included in.
+	BlockHash common.Hash // The hash of the block the transaction was included in.
+	Fees      *big.Int    // The execution and blob fees the transaction paid in that block.
+}
+
+// PoolAccount is an account of an AccountPool together with the transaction it has in flight.
+type PoolAccount struct {
+	bb.AuthAcct
+	Pending *PendingTx // The transaction in flight, or nil if the account is free.
+}
+
+// AccountPool holds several sending accounts so that more than one blob transaction can be in flight
+// at a time. Each account has at most one pending transaction, which is tracked separately.
+type AccountPool struct {
+	mu        sync.Mutex
+	accounts  []*PoolAccount
+	confirmed []ConfirmedTx
+	strategy  string
+	next      int
+}
+
+// NewAccountPool creates an account pool from a list of authenticated accounts.
+//
+// Parameters:
+// - accounts: The accounts to send from. Duplicate addresses are ignored.
+// - strategy: The account selection strategy, SelectRoundRobin or SelectByBalance.
+//
+// Returns:
+// - A pointer to an AccountPool, or an error if no account is given or the strategy is unknown.
+func NewAccountPool(accounts []bb.AuthAcct, strategy string) (*AccountPool, error) {
+	if strategy != SelectRoundRobin && strategy != SelectByBalance {
+		return nil, fmt.Errorf("unknown account selection strategy %q", strategy)
+	}
+
+	pool := &AccountPool{strategy: strategy}
+	seen := make(map[common.Address]bool)
+	for _, acct := range accounts {
+		if acct.Signer == nil || seen[acct.Address] {
+			continue
+		}
+		seen[acct.Address] = true
+		pool.accounts = append(pool.accounts, &PoolAccount{AuthAcct: acct})
+	}
+	if len(pool.accounts) == 0 {
+		return nil, errors.New("account pool needs at least one account")
+	}
+
+	return pool, nil
+}
+
+// Len returns the number of accounts in the pool.
+func (p *AccountPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.accounts)
+}
+
+// Next picks a free account according to the pool's selection strategy. The SelectByBalance strategy
+// queries the client for balances and nonces and skips accounts whose pending nonce is ahead of their
+// latest nonce, since those have transactions in the mempool that the pool does not know about.
+//
+// Parameters:
+// - ctx: The context for the client requests.
+// - client: The Ethereum client used to query balances and nonces.
+//
+// Returns:
+// - A copy of the selected account, or ErrNoFreeAccount if all accounts have a pending transaction.
+func (p *AccountPool) Next(ctx context.Context, client *ethclient.Client) (PoolAccount, error) {
+	p.mu.Lock()
+	if p.strategy == SelectRoundRobin {
+		defer p.mu.Unlock()
+		for i := 0; i < len(p.accounts); i++ {
+			idx := (p.next + i) % len(p.accounts)
+			if p.accounts[idx].Pending == nil {
+				p.next = idx + 1
+				return *p.accounts[idx], nil
+			}
+		}
+		return PoolAccount{}, ErrNoFreeAccount
+	}
+
+	// Balances and nonces are fetched without holding the lock, so that the pool stays usable meanwhile
+	var free []common.Address
+	for _, acct := range p.accounts {
+		if acct.Pending == nil {
+			free = append(free, acct.Address)
+		}
+	}
+	p.mu.Unlock()
+
+	type candidate struct {
+		address common.Address
+		balance *big.Int
+	}
+	var candidates []candidate
+	for _, address := range free {
+		pendingNonce, err := client.PendingNonceAt(ctx, address)
+		if err != nil {
+			return PoolAccount{}, fmt.Errorf("failed to get pending nonce of %s: %w", address, err)
+		}
+		latestNonce, err := client.NonceAt(ctx, address, nil)
+		if err != nil {
+			return PoolAccount{}, fmt.Errorf("failed to get nonce of %s: %w", address, err)
+		}
+		if pendingNonce > latestNonce {
+			logging.FromContext(ctx, nil).Warn("skipping account with unknown mempool transactions", logging.KeyAccount, address, "pendingNonce", pendingNonce, "nonce", latestNonce)
+			continue
+		}
+		balance, err := client.BalanceAt(ctx, address, nil)
+		if err != nil {
+			return PoolAccount{}, fmt.Errorf("failed to get balance of %s: %w", address, err)
+		}
+		candidates = append(candidates, candidate{address: address, balance: balance})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].balance.Cmp(candidates[j].balance) > 0 })
+
+	// An account may have received a transaction while the lock was released
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range candidates {
+		if acct := p.find(c.address); acct != nil && acct.Pending == nil {
+			return *acct, nil
+		}
+	}
+	return PoolAccount{}, ErrNoFreeAccount
+}
+
+// Contains reports whether the pool has an account with the given address.
+func (p *AccountPool) Contains(address common.Address) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.find(address) != nil
+}
+
+// MarkPending records tx as the pending transaction of its sending account, replacing any previous record.
+//
+// Parameters:
+// - tx: The pending transaction. tx.From must be an account of the pool.
+func (p *AccountPool) MarkPending(tx PendingTx) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if acct := p.find(tx.From); acct != nil {
+		acct.Pending = &tx
+	}
+}
+
+// ClearPending frees an account once its pending transaction has been included or abandoned.
+//
+// Parameters:
+// - address: The address of the account to free.
+func (p *AccountPool) ClearPending(address common.Address) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if acct := p.find(address); acct != nil {
+		acct.Pending = nil
+	}
+}
+
+// SetBundleStatuses records the relay statuses of the bundles of a pending transaction.
+//
+// Parameters:
+// - txHash: The hash of the pending transaction. Unknown hashes are ignored.
+// - statuses: The statuses of all bundles of the transaction.
+func (p *AccountPool) SetBundleStatuses(txHash common.Hash, statuses []BundleStatus) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, acct := range p.accounts {
+		if acct.Pending != nil && acct.Pending.Hash == txHash {
+			acct.Pending.Bundles = statuses
+		}
+	}
+}
+
+// Confirm frees the account of an included transaction and remembers the transaction until Prune,
+// so that it can be moved back to pending if its block is reorged out.
+//
+// Parameters:
+// - tx: The included transaction.
+// - block: The number of the block the transaction was included in.
+// - blockHash: The hash of the block the transaction was included in.
+// - fees: The fees the transaction paid, returned by Revert so that they can be taken back.
+func (p *AccountPool) Confirm(tx PendingTx, block uint64, blockHash common.Hash, fees *big.Int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if acct := p.find(tx.From); acct != nil && acct.Pending != nil && acct.Pending.Hash == tx.Hash {
+		acct.Pending = nil
+	}
+	p.confirmed = append(p.confirmed, ConfirmedTx{PendingTx: tx, Block: block, BlockHash: blockHash, Fees: fees})
+}
+
+// Revert moves the transactions included in blocks that were reorged out back to pending. A transaction
+// whose account already has a newer transaction in flight is not tracked again, since the newer transaction
+// can only be included after it.
+//
+// Parameters:
+// - dropped: The hashes of the blocks that are no longer canonical.
+//
+// Returns:
+// - The transactions that were moved back to pending, and those that are no longer tracked, with the
+// fees they paid in the dropped blocks.
+func (p *AccountPool) Revert(dropped []common.Hash) (reverted, untracked []ConfirmedTx) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	isDropped := make(map[common.Hash]bool, len(dropped))
+	for _, hash := range dropped {
+		isDropped[hash] = true
+	}
+
+	kept := p.confirmed[:0]
+	for _, confirmed := range p.confirmed {
+		if !isDropped[confirmed.BlockHash] {
+			kept = append(kept, confirmed)
+			continue
+		}
+		tx := confirmed.PendingTx
+		if acct := p.find(tx.From); acct != nil && acct.Pending == nil {
+			acct.Pending = &tx
+			reverted = append(reverted, confirmed)
+		} else {
+			untracked = append(untracked, confirmed)
+		}
+	}
+	p.confirmed = kept
+	return reverted, untracked
+}
+
+// Prune forgets the included transactions of blocks before the given block number.
+func (p *AccountPool) Prune(before uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	kept := p.confirmed[:0]
+	for _, confirmed := range p.confirmed {
+		if confirmed.Block >= before {
+			kept = append(kept, confirmed)
+		}
+	}
+	p.confirmed = kept
+}
+
+// Pending returns a snapshot of all pending transactions in the pool.
+func (p *AccountPool) Pending() []PendingTx {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var pending []PendingTx
+	for _, acct := range p.accounts {
+		if acct.Pending != nil {
+			pending = append(pending, *acct.Pending)
+		}
+	}
+	return pending
+}
+
+// find returns the pool account with the given address. The caller must hold p.mu.
+func (p *AccountPool) find(address common.Address) *PoolAccount {
+	for _, acct := range p.accounts {
+		if acct.Address == address {
+			return acct
+		}
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	bb "github.com/primev/preconf_blob_bidder/core/mevcommit"
+)
+
+// testAccounts returns n accounts with fresh keys.
+func testAccounts(t *testing.T, n int) []bb.AuthAcct {
+	t.Helper()
+	accounts := make([]bb.AuthAcct, n)
+	for i := range accounts {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		accounts[i] = bb.AuthAcct{PrivateKey: key, PublicKey: &key.PublicKey, Address: crypto.PubkeyToAddress(key.PublicKey), Signer: bb.NewKeySigner(key)}
+	}
+	return accounts
+}
+
+func TestAccountPoolReorgFees(t *testing.T) {
+	accounts := testAccounts(t, 1)
+	pool, err := NewAccountPool(accounts, SelectRoundRobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	budget := NewBudget(BudgetLimits{})
+	tx := PendingTx{From: accounts[0].Address, Hash: common.HexToHash("0x01"), Nonce: 3, TargetBlock: 100}
+	pool.MarkPending(tx)
+
+	// confirm is what the loop does for an included transaction
+	confirm := func(block uint64, blockHash common.Hash, fees int64) {
+		pool.Confirm(tx, block, blockHash, big.NewInt(fees))
+		budget.RecordFees(big.NewInt(fees))
+	}
+
+	confirm(100, common.HexToHash("0xb100"), 1000)
+	if pending := pool.Pending(); len(pending) != 0 {
+		t.Fatalf("pending %v after confirmation, want none", pending)
+	}
+
+	reverted, untracked := pool.Revert([]common.Hash{common.HexToHash("0xb100")})
+	if len(reverted) != 1 || len(untracked) != 0 || reverted[0].Hash != tx.Hash || reverted[0].Fees.Int64() != 1000 {
+		t.Fatalf("Revert = %v, %v, want the transaction with its fees", reverted, untracked)
+	}
+	for _, tx := range reverted {
+		budget.RefundFees(tx.Fees)
+	}
+	if pending := pool.Pending(); len(pending) != 1 || pending[0].Hash != tx.Hash {
+		t.Fatalf("pending %v after the reorg, want the reverted transaction", pending)
+	}
+
+	// Included again in the new chain at a different fee
+	confirm(101, common.HexToHash("0xb101"), 1200)
+	if spent := budget.Report(101).FeeSpend; spent.Int64() != 1200 {
+		t.Errorf("fee spend %s after confirm, reorg and confirm, want 1200", spent)
+	}
+
+	// Revert of a block that is not the inclusion block leaves the transaction confirmed
+	if reverted, untracked := pool.Revert([]common.Hash{common.HexToHash("0xb100")}); len(reverted)+len(untracked) != 0 {
+		t.Errorf("Revert of a stale block = %v, %v, want nothing", reverted, untracked)
+	}
+}
+
+func TestNewAccountPool(t *testing.T) {
+	accounts := testAccounts(t, 2)
+	pool, err := NewAccountPool(append(accounts, accounts[0], bb.AuthAcct{Address: common.HexToAddress("0x01")}), SelectRoundRobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pool.Len() != 2 {
+		t.Errorf("pool has %d accounts, want 2 without duplicates and accounts without signer", pool.Len())
+	}
+	if _, err := NewAccountPool(accounts, "random"); err == nil {
+		t.Error("NewAccountPool accepted an unknown strategy")
+	}
+	if _, err := NewAccountPool(nil, SelectRoundRobin); err == nil {
+		t.Error("NewAccountPool accepted no accounts")
+	}
+}
+
+func TestAccountPoolNextRoundRobin(t *testing.T) {
+	accounts := testAccounts(t, 3)
+	pool, err := NewAccountPool(accounts, SelectRoundRobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		pending int // Index of the account to mark pending before Next, -1 for none.
+		want    int // Index of the expected account, -1 for ErrNoFreeAccount.
+	}{
+		{name: "first account", pending: -1, want: 0},
+		{name: "second account", pending: -1, want: 1},
+		{name: "skips pending account", pending: 2, want: 0},
+		{name: "continues after the last pick", pending: -1, want: 1},
+		{name: "wraps around", pending: 1, want: 0},
+		{name: "all accounts pending", pending: 0, want: -1},
+	}
+	for _, tt := range tests {
+		if tt.pending >= 0 {
+			pool.MarkPending(PendingTx{From: accounts[tt.pending].Address, Hash: common.BigToHash(big.NewInt(int64(tt.pending + 1)))})
+		}
+		acct, err := pool.Next(context.Background(), nil)
+		if tt.want < 0 {
+			if !errors.Is(err, ErrNoFreeAccount) {
+				t.Errorf("%s: Next = %s, %v, want ErrNoFreeAccount", tt.name, acct.Address, err)
+			}
+			continue
+		}
+		if err != nil || acct.Address != accounts[tt.want].Address {
+			t.Errorf("%s: Next = %s, %v, want account %d", tt.name, acct.Address, err, tt.want)
+		}
+	}
+
+	pool.ClearPending(accounts[2].Address)
+	if acct, err := pool.Next(context.Background(), nil); err != nil || acct.Address != accounts[2].Address {
+		t.Errorf("Next after ClearPending = %s, %v, want the freed account", acct.Address, err)
+	}
+}
+
+// accountState is what the balance stub reports for an account.
+type accountState struct {
+	balance             int64
+	nonce, pendingNonce uint64
+}
+
+// newAccountStub starts a JSON-RPC endpoint that answers balance and nonce requests from states and
+// returns a client connected to it.
+func newAccountStub(t *testing.T, states map[common.Address]accountState) *ethclient.Client {
+	t.Helper()
+	server := newRPCStub(t, func(method string, params []json.RawMessage) (interface{}, error) {
+		var address common.Address
+		var block string
+		if len(params) != 2 || json.Unmarshal(params[0], &address) != nil || json.Unmarshal(params[1], &block) != nil {
+			return nil, &RPCError{Code: -32602, Message: "invalid params"}
+		}
+		state, ok := states[address]
+		if !ok {
+			return nil, &RPCError{Code: -32000, Message: "unknown account"}
+		}
+		switch method {
+		case "eth_getBalance":
+			return (*hexutil.Big)(big.NewInt(state.balance)), nil
+		case "eth_getTransactionCount":
+			if block == "pending" {
+				return hexutil.Uint64(state.pendingNonce), nil
+			}
+			return hexutil.Uint64(state.nonce), nil
+		}
+		return nil, &RPCError{Code: -32601, Message: "the method " + method + " does not exist"}
+	})
+	client, err := ethclient.Dial(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestAccountPoolNextByBalance(t *testing.T) {
+	accounts := testAccounts(t, 3)
+
+	tests := []struct {
+		name    string
+		states  []accountState
+		pending []int // Indexes of the accounts with a pending transaction.
+		want    int   // Index of the expected account, -1 for ErrNoFreeAccount.
+		wantErr bool
+	}{
+		{
+			name:   "highest balance",
+			states: []accountState{{balance: 10}, {balance: 30}, {balance: 20}},
+			want:   1,
+		},
+		{
+			name:    "skips pending account",
+			states:  []accountState{{balance: 10}, {balance: 30}, {balance: 20}},
+			pending: []int{1},
+			want:    2,
+		},
+		{
+			name:   "skips account with unknown mempool transactions",
+			states: []accountState{{balance: 10}, {balance: 30, nonce: 4, pendingNonce: 5}, {balance: 20}},
+			want:   2,
+		},
+		{
+			name:    "no free account",
+			states:  []accountState{{balance: 10}, {balance: 30}, {balance: 20, nonce: 1, pendingNonce: 2}},
+			pending: []int{0, 1},
+			want:    -1,
+		},
+		{
+			name:    "rpc error",
+			states:  []accountState{{balance: 10}, {balance: 30}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, err := NewAccountPool(accounts, SelectByBalance)
+			if err != nil {
+				t.Fatal(err)
+			}
+			states := make(map[common.Address]accountState)
+			for i, state := range tt.states {
+				states[accounts[i].Address] = state
+			}
+			for _, i := range tt.pending {
+				pool.MarkPending(PendingTx{From: accounts[i].Address})
+			}
+
+			acct, err := pool.Next(context.Background(), newAccountStub(t, states))
+			switch {
+			case tt.wantErr:
+				if err == nil || errors.Is(err, ErrNoFreeAccount) {
+					t.Errorf("Next = %s, %v, want an rpc error", acct.Address, err)
+				}
+			case tt.want < 0:
+				if !errors.Is(err, ErrNoFreeAccount) {
+					t.Errorf("Next = %s, %v, want ErrNoFreeAccount", acct.Address, err)
+				}
+			case err != nil || acct.Address != accounts[tt.want].Address:
+				t.Errorf("Next = %s, %v, want account %d", acct.Address, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccountPoolPendingTracking(t *testing.T) {
+	accounts := testAccounts(t, 2)
+	pool, err := NewAccountPool(accounts, SelectRoundRobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := PendingTx{From: accounts[0].Address, Hash: common.HexToHash("0x01"), Nonce: 1}
+	second := PendingTx{From: accounts[1].Address, Hash: common.HexToHash("0x02"), Nonce: 7}
+	pool.MarkPending(first)
+	pool.MarkPending(second)
+	pool.MarkPending(PendingTx{From: common.HexToAddress("0x03"), Hash: common.HexToHash("0x03")})
+
+	if !pool.Contains(accounts[0].Address) || pool.Contains(common.HexToAddress("0x03")) {
+		t.Error("Contains does not match the accounts of the pool")
+	}
+	if pending := pool.Pending(); len(pending) != 2 {
+		t.Fatalf("pending %v, want the transactions of the two accounts", pending)
+	}
+
+	// A resent bid replaces the record of the account's pending transaction
+	first.Preconfs = 2
+	pool.MarkPending(first)
+	statuses := []BundleStatus{{Endpoint: "relay"}}
+	pool.SetBundleStatuses(first.Hash, statuses)
+	for _, tx := range pool.Pending() {
+		if tx.Hash == first.Hash && (tx.Preconfs != 2 || len(tx.Bundles) != 1) {
+			t.Errorf("pending %+v, want the updated record with its bundle statuses", tx)
+		}
+	}
+
+	// A confirmation of a transaction that is no longer pending leaves the newer transaction in place
+	pool.Confirm(PendingTx{From: accounts[1].Address, Hash: common.HexToHash("0x0f"), Nonce: 6}, 10, common.HexToHash("0xb10"), nil)
+	pool.Confirm(first, 11, common.HexToHash("0xb11"), nil)
+	if pending := pool.Pending(); len(pending) != 1 || pending[0].Hash != second.Hash {
+		t.Fatalf("pending %v after confirmations, want only the second transaction", pending)
+	}
+
+	// The account of the older transaction has a newer transaction in flight, it is no longer tracked
+	reverted, untracked := pool.Revert([]common.Hash{common.HexToHash("0xb10"), common.HexToHash("0xb11")})
+	if len(reverted) != 1 || reverted[0].Hash != first.Hash {
+		t.Errorf("reverted %v, want the first transaction", reverted)
+	}
+	if len(untracked) != 1 || untracked[0].Hash != common.HexToHash("0x0f") {
+		t.Errorf("untracked %v, want the transaction that precedes the pending one", untracked)
+	}
+	if pending := pool.Pending(); len(pending) != 2 {
+		t.Errorf("pending %v after the reorg, want both accounts pending", pending)
+	}
+
+	// Pruned confirmations are no longer reverted
+	pool.ClearPending(accounts[0].Address)
+	pool.Confirm(first, 20, common.HexToHash("0xb20"), nil)
+	pool.Confirm(second, 30, common.HexToHash("0xb30"), nil)
+	pool.Prune(25)
+	reverted, untracked = pool.Revert([]common.Hash{common.HexToHash("0xb20"), common.HexToHash("0xb30")})
+	if len(reverted) != 1 || reverted[0].Hash != second.Hash || len(untracked) != 0 {
+		t.Errorf("Revert after Prune = %v, %v, want only the unpruned transaction", reverted, untracked)
+	}
+}
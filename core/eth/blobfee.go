@@ -0,0 +1,44 @@
+package eth
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// BlobFeeDoublingBlocks is the number of full blob blocks after which the blob base fee has at least doubled.
+// Adding it to the validity window of ProjectBlobBaseFee doubles the blob fee cap, as needed to replace a blob transaction.
+const BlobFeeDoublingBlocks = 6
+
+// NextExcessBlobGas returns the excess blob gas of the block following parent.
+func NextExcessBlobGas(parent *types.Header) uint64 {
+	var parentExcess, parentUsed uint64
+	if parent.ExcessBlobGas != nil {
+		parentExcess = *parent.ExcessBlobGas
+	}
+	if parent.BlobGasUsed != nil {
+		parentUsed = *parent.BlobGasUsed
+	}
+	return eip4844.CalcExcessBlobGas(parentExcess, parentUsed)
+}
+
+// ProjectBlobBaseFee returns the worst-case blob base fee within the next blocks after parent. The first
+// of those blocks has a known blob base fee, every following block is assumed to use the maximum blob gas,
+// which raises the excess blob gas by the maximum allowed amount and the blob base fee by about 12.5% per block.
+// A blob fee cap of at least the returned value keeps a transaction includable for that many blocks.
+//
+// Parameters:
+// - parent: The header of the block the transaction is built on top of.
+// - blocks: The number of blocks the transaction must remain includable for. Zero is treated as one.
+//
+// Returns:
+// - The highest blob base fee in wei that any of the next blocks can have.
+func ProjectBlobBaseFee(parent *types.Header, blocks uint64) *big.Int {
+	excess := NextExcessBlobGas(parent)
+	for i := uint64(1); i < blocks; i++ {
+		excess = eip4844.CalcExcessBlobGas(excess, params.MaxBlobGasPerBlock)
+	}
+	return eip4844.CalcBlobFee(excess)
+}
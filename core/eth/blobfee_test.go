@@ -0,0 +1,81 @@
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestNextExcessBlobGas(t *testing.T) {
+	tests := []struct {
+		name   string
+		parent *types.Header
+		want   uint64
+	}{
+		{name: "pre-cancun header", parent: &types.Header{}, want: 0},
+		{name: "empty parent at minimum", parent: blobHeader(0, 0, 1), want: 0},
+		{name: "target parent", parent: blobHeader(params.BlobTxBlobGasPerBlob, TargetBlobsPerBlock, 1), want: params.BlobTxBlobGasPerBlob},
+		{name: "full parent", parent: blobHeader(0, MaxBlobsPerBlock, 1), want: params.MaxBlobGasPerBlock - params.BlobTxTargetBlobGasPerBlock},
+		{name: "empty parent", parent: blobHeader(10*params.BlobTxBlobGasPerBlob, 0, 1), want: 7 * params.BlobTxBlobGasPerBlob},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NextExcessBlobGas(tt.parent); got != tt.want {
+				t.Fatalf("NextExcessBlobGas() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProjectBlobBaseFee(t *testing.T) {
+	t.Run("from the minimum blob base fee", func(t *testing.T) {
+		parent := blobHeader(0, 0, 1)
+		if got := ProjectBlobBaseFee(parent, 0); got.Cmp(big.NewInt(params.BlobTxMinBlobGasprice)) != 0 {
+			t.Fatalf("ProjectBlobBaseFee(0) = %s, want the minimum %d", got, params.BlobTxMinBlobGasprice)
+		}
+		if got := ProjectBlobBaseFee(parent, 1); got.Cmp(big.NewInt(params.BlobTxMinBlobGasprice)) != 0 {
+			t.Fatalf("ProjectBlobBaseFee(1) = %s, want the minimum %d", got, params.BlobTxMinBlobGasprice)
+		}
+		// Full blocks raise the fee from the minimum, which is 1 wei, to 2 wei after BlobFeeDoublingBlocks.
+		if got := ProjectBlobBaseFee(parent, 1+BlobFeeDoublingBlocks); got.Cmp(big.NewInt(2)) < 0 {
+			t.Fatalf("ProjectBlobBaseFee(%d) = %s, want at least 2", 1+BlobFeeDoublingBlocks, got)
+		}
+	})
+
+	t.Run("non-decreasing", func(t *testing.T) {
+		parent := blobHeader(5*params.BlobTxBlobGaspriceUpdateFraction, MaxBlobsPerBlock, 1)
+		prev := ProjectBlobBaseFee(parent, 1)
+		for blocks := uint64(2); blocks <= 20; blocks++ {
+			fee := ProjectBlobBaseFee(parent, blocks)
+			if fee.Cmp(prev) < 0 {
+				t.Fatalf("ProjectBlobBaseFee(%d) = %s is below ProjectBlobBaseFee(%d) = %s", blocks, fee, blocks-1, prev)
+			}
+			prev = fee
+		}
+	})
+
+	t.Run("doubles over BlobFeeDoublingBlocks", func(t *testing.T) {
+		excesses := []uint64{
+			0,
+			params.BlobTxBlobGasPerBlob,
+			params.BlobTxBlobGaspriceUpdateFraction,
+			10 * params.BlobTxBlobGaspriceUpdateFraction,
+			20*params.BlobTxBlobGaspriceUpdateFraction + 12345,
+		}
+		for _, excess := range excesses {
+			for _, used := range []uint64{0, TargetBlobsPerBlock, MaxBlobsPerBlock} {
+				parent := blobHeader(excess, used, 1)
+				for blocks := uint64(1); blocks <= 4; blocks++ {
+					fee := ProjectBlobBaseFee(parent, blocks)
+					doubled := ProjectBlobBaseFee(parent, blocks+BlobFeeDoublingBlocks)
+					if doubled.Cmp(new(big.Int).Lsh(fee, 1)) < 0 {
+						t.Errorf("excess %d, %d blobs: ProjectBlobBaseFee(%d) = %s is not double ProjectBlobBaseFee(%d) = %s",
+							excess, used, blocks+BlobFeeDoublingBlocks, doubled, blocks, fee)
+					}
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package eth
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	// MaxBlobsPerBlock is the maximum number of blobs a single block can hold.
+	MaxBlobsPerBlock = params.MaxBlobGasPerBlock / params.BlobTxBlobGasPerBlob
+
+	// TargetBlobsPerBlock is the number of blobs per block at which the blob base fee stays constant.
+	TargetBlobsPerBlock = params.BlobTxTargetBlobGasPerBlock / params.BlobTxBlobGasPerBlob
+
+	// BlobUsableBytes is the number of payload bytes that fit in one blob. Every field element
+	// keeps its top byte zero so that it stays below the BLS modulus, leaving 31 usable bytes.
+	BlobUsableBytes = (params.BlobTxBytesPerFieldElement - 1) * params.BlobTxFieldElementsPerBlob
+)
+
+// BlobPolicy decides how many blobs each blob transaction carries, based on the current blob
+// base fee, how full the parent block was, the payload waiting to be posted and the remaining budget.
+type BlobPolicy struct {
+	MinBlobs     int      // Smallest number of blobs a transaction may carry.
+	MaxBlobs     int      // Largest number of blobs a transaction may carry.
+	MaxBlobFee   *big.Int // Blob base fee (wei) above which transactions are shrunk to MinBlobs. Nil disables the limit.
+	ExecutionGas uint64   // Execution gas paid by every transaction, used to price splitting a payload.
+}
+
+// BlobPlan is the outcome of a BlobPolicy decision.
+type BlobPlan struct {
+	BlobBaseFee *big.Int   // The blob base fee of the next block.
+	Txs         []int      // The number of blobs carried by each transaction, in sending order.
+	TxCosts     []*big.Int // The estimated cost in wei of each transaction.
+	Cost        *big.Int   // The estimated cost of the whole plan in wei.
+}
+
+// DefaultBlobPolicy returns a policy that sends between 1 and MaxBlobsPerBlock blobs per
+// transaction with no blob fee limit.
+func DefaultBlobPolicy() BlobPolicy {
+	return BlobPolicy{
+		MinBlobs:     1,
+		MaxBlobs:     MaxBlobsPerBlock,
+		ExecutionGas: params.TxGas,
+	}
+}
+
+// Plan picks the blob count of each transaction needed to post payloadBytes on top of the parent block.
+// A non-positive payloadBytes fills a single transaction with MaxBlobs blobs. Large payloads are split
+// across several transactions when the expected cost of the split, including the fee increase caused by
+// a transaction not fitting into the next block, is lower than sending fewer, larger transactions.
+//
+// Parameters:
+// - parent: The header of the block the transactions are built on top of.
+// - payloadBytes: The number of payload bytes waiting to be posted.
+// - remainingBudget: The amount of wei still available for fees. Nil means unlimited.
+//
+// Returns:
+// - A BlobPlan describing the transactions to send. The plan holds no transactions if nothing is affordable.
+func (p BlobPolicy) Plan(parent *types.Header, payloadBytes int, remainingBudget *big.Int) BlobPlan {
+	minBlobs, maxBlobs := p.bounds()
+
+	blobBaseFee := ProjectBlobBaseFee(parent, 1)
+
+	// Blob fee one block later if the next block is completely full, which is what a transaction
+	// that does not fit into the next block risks paying.
+	delayedBlobFee := ProjectBlobBaseFee(parent, 2)
+
+	needed := maxBlobs
+	if payloadBytes > 0 {
+		needed = (payloadBytes + BlobUsableBytes - 1) / BlobUsableBytes
+	}
+
+	// While blobs are more expensive than the configured limit only post the minimum.
+	if p.MaxBlobFee != nil && blobBaseFee.Cmp(p.MaxBlobFee) > 0 {
+		maxBlobs = minBlobs
+		needed = min(needed, minBlobs)
+	}
+
+	// When the parent block was above target, only the remaining space is likely to be free in the next block.
+	freeBlobs := MaxBlobsPerBlock
+	var parentBlobs int
+	if parent.BlobGasUsed != nil {
+		parentBlobs = int(*parent.BlobGasUsed / params.BlobTxBlobGasPerBlob)
+	}
+	if parentBlobs > TargetBlobsPerBlock {
+		freeBlobs = max(MaxBlobsPerBlock-parentBlobs, minBlobs)
+	}
+
+	execCost := new(big.Int)
+	if parent.BaseFee != nil {
+		execCost.Mul(parent.BaseFee, new(big.Int).SetUint64(p.ExecutionGas))
+	}
+
+	txCost := func(n int) *big.Int {
+		fee := blobBaseFee
+		if n > freeBlobs {
+			fee = delayedBlobFee
+		}
+		cost := new(big.Int).Mul(fee, big.NewInt(int64(n)*params.BlobTxBlobGasPerBlob))
+		return cost.Add(cost, execCost)
+	}
+
+	// Try every transaction size and keep the cheapest split, preferring fewer transactions on ties.
+	var (
+		bestTxs  []int
+		bestCost *big.Int
+	)
+	for size := maxBlobs; size >= minBlobs; size-- {
+		txs := splitBlobs(needed, size, minBlobs)
+		cost := new(big.Int)
+		for _, n := range txs {
+			cost.Add(cost, txCost(n))
+		}
+		if bestCost == nil || cost.Cmp(bestCost) < 0 {
+			bestTxs, bestCost = txs, cost
+		}
+	}
+
+	// Drop or shrink transactions that do not fit into the remaining budget.
+	plan := BlobPlan{BlobBaseFee: blobBaseFee, Cost: new(big.Int)}
+	for _, n := range bestTxs {
+		for n >= minBlobs {
+			cost := txCost(n)
+			if remainingBudget == nil || new(big.Int).Add(plan.Cost, cost).Cmp(remainingBudget) <= 0 {
+				plan.Txs = append(plan.Txs, n)
+				plan.TxCosts = append(plan.TxCosts, cost)
+				plan.Cost.Add(plan.Cost, cost)
+				break
+			}
+			n--
+		}
+		if n < minBlobs {
+			break
+		}
+	}
+
+	return plan
+}
+
+// bounds returns the per-transaction blob limits of the policy clamped to the protocol limits.
+func (p BlobPolicy) bounds() (int, int) {
+	minBlobs := max(p.MinBlobs, 1)
+	maxBlobs := p.MaxBlobs
+	if maxBlobs <= 0 || maxBlobs > MaxBlobsPerBlock {
+		maxBlobs = MaxBlobsPerBlock
+	}
+	return min(minBlobs, maxBlobs), maxBlobs
+}
+
+// splitBlobs splits a number of blobs into transactions of at most size blobs each.
+// The last transaction is padded up to minBlobs if it would otherwise be smaller.
+func splitBlobs(total, size, minBlobs int) []int {
+	var txs []int
+	for total > 0 {
+		n := min(total, size)
+		txs = append(txs, max(n, minBlobs))
+		total -= n
+	}
+	return txs
+}
@@ -0,0 +1,159 @@
+package eth
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// blobHeader returns a parent header with the given excess blob gas, number of blobs and base fee.
+func blobHeader(excessBlobGas uint64, blobs uint64, baseFee int64) *types.Header {
+	used := blobs * params.BlobTxBlobGasPerBlob
+	return &types.Header{
+		Number:        big.NewInt(100),
+		GasLimit:      30_000_000,
+		GasUsed:       15_000_000,
+		BaseFee:       big.NewInt(baseFee),
+		ExcessBlobGas: &excessBlobGas,
+		BlobGasUsed:   &used,
+	}
+}
+
+func TestBlobPolicyPlan(t *testing.T) {
+	// About e^20 wei per blob gas, so that blob fees dominate the execution cost of a 1 wei base fee.
+	const expensiveExcess = 20 * params.BlobTxBlobGaspriceUpdateFraction
+
+	cheap := blobHeader(0, 0, params.GWei)
+	expensive := blobHeader(expensiveExcess, 0, 1)
+	full := blobHeader(expensiveExcess, MaxBlobsPerBlock, 1)
+	blobCost := func(parent *types.Header, blobs int64) *big.Int {
+		cost := new(big.Int).Mul(ProjectBlobBaseFee(parent, 1), big.NewInt(blobs*params.BlobTxBlobGasPerBlob))
+		return cost.Add(cost, new(big.Int).Mul(parent.BaseFee, big.NewInt(int64(params.TxGas))))
+	}
+
+	tests := []struct {
+		name    string
+		policy  BlobPolicy
+		parent  *types.Header
+		payload int
+		budget  *big.Int
+		want    []int
+	}{
+		{
+			name:   "no payload fills max blobs",
+			policy: DefaultBlobPolicy(),
+			parent: cheap,
+			want:   []int{MaxBlobsPerBlock},
+		},
+		{
+			name:    "payload rounds up to whole blobs",
+			policy:  DefaultBlobPolicy(),
+			parent:  cheap,
+			payload: BlobUsableBytes + 1,
+			want:    []int{2},
+		},
+		{
+			name:    "last transaction padded to min blobs",
+			policy:  BlobPolicy{MinBlobs: 3, MaxBlobs: 6, ExecutionGas: params.TxGas},
+			parent:  cheap,
+			payload: 1,
+			want:    []int{3},
+		},
+		{
+			name:   "blob counts clamped to protocol limits",
+			policy: BlobPolicy{MinBlobs: 0, MaxBlobs: 10, ExecutionGas: params.TxGas},
+			parent: cheap,
+			want:   []int{MaxBlobsPerBlock},
+		},
+		{
+			name:   "min blobs above max blobs",
+			policy: BlobPolicy{MinBlobs: 5, MaxBlobs: 2, ExecutionGas: params.TxGas},
+			parent: cheap,
+			want:   []int{2},
+		},
+		{
+			name:    "blob fee above threshold posts min blobs",
+			policy:  BlobPolicy{MinBlobs: 2, MaxBlobs: 6, MaxBlobFee: big.NewInt(1), ExecutionGas: params.TxGas},
+			parent:  expensive,
+			payload: 20 * BlobUsableBytes,
+			want:    []int{2},
+		},
+		{
+			name:   "blob fee at threshold is not limited",
+			policy: BlobPolicy{MinBlobs: 2, MaxBlobs: 6, MaxBlobFee: ProjectBlobBaseFee(expensive, 1), ExecutionGas: params.TxGas},
+			parent: expensive,
+			want:   []int{6},
+		},
+		{
+			name:    "payload split at max blobs",
+			policy:  BlobPolicy{MinBlobs: 1, MaxBlobs: 4, ExecutionGas: params.TxGas},
+			parent:  cheap,
+			payload: 10 * BlobUsableBytes,
+			want:    []int{4, 4, 2},
+		},
+		{
+			name:    "full parent splits into the free space",
+			policy:  DefaultBlobPolicy(),
+			parent:  full,
+			payload: 3 * BlobUsableBytes,
+			want:    []int{1, 1, 1},
+		},
+		{
+			name:   "budget shrinks the transaction",
+			policy: DefaultBlobPolicy(),
+			parent: expensive,
+			budget: blobCost(expensive, 3),
+			want:   []int{3},
+		},
+		{
+			name:   "budget drops unaffordable transactions",
+			policy: DefaultBlobPolicy(),
+			parent: expensive,
+			budget: big.NewInt(1),
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := tt.policy.Plan(tt.parent, tt.payload, tt.budget)
+			if !reflect.DeepEqual(plan.Txs, tt.want) {
+				t.Fatalf("Plan().Txs = %v, want %v", plan.Txs, tt.want)
+			}
+			if plan.BlobBaseFee.Cmp(ProjectBlobBaseFee(tt.parent, 1)) != 0 {
+				t.Errorf("BlobBaseFee = %s, want the next block's blob base fee", plan.BlobBaseFee)
+			}
+			total := new(big.Int)
+			for _, cost := range plan.TxCosts {
+				total.Add(total, cost)
+			}
+			if len(plan.TxCosts) != len(plan.Txs) || total.Cmp(plan.Cost) != 0 {
+				t.Errorf("TxCosts = %v do not add up to Cost = %s", plan.TxCosts, plan.Cost)
+			}
+			if tt.budget != nil && plan.Cost.Cmp(tt.budget) > 0 {
+				t.Errorf("Cost = %s exceeds budget %s", plan.Cost, tt.budget)
+			}
+		})
+	}
+}
+
+func TestSplitBlobs(t *testing.T) {
+	tests := []struct {
+		total, size, minBlobs int
+		want                  []int
+	}{
+		{total: 0, size: 6, minBlobs: 1, want: nil},
+		{total: 6, size: 6, minBlobs: 1, want: []int{6}},
+		{total: 7, size: 6, minBlobs: 1, want: []int{6, 1}},
+		{total: 10, size: 4, minBlobs: 1, want: []int{4, 4, 2}},
+		{total: 9, size: 4, minBlobs: 3, want: []int{4, 4, 3}},
+		{total: 12, size: 4, minBlobs: 2, want: []int{4, 4, 4}},
+	}
+	for _, tt := range tests {
+		if got := splitBlobs(tt.total, tt.size, tt.minBlobs); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitBlobs(%d, %d, %d) = %v, want %v", tt.total, tt.size, tt.minBlobs, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package eth
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	// ErrBudgetExhausted is returned by Budget.Check once a limit of the whole run is reached.
+	ErrBudgetExhausted = errors.New("budget exhausted")
+
+	// ErrWindowBudgetExhausted is returned by Budget.Check while the bid spend of the current bidding window is reached.
+	ErrWindowBudgetExhausted = errors.New("bidding window budget exhausted")
+)
+
+// BudgetLimits are the spending and run limits of the bidder loop. Nil and zero values disable a limit.
+type BudgetLimits struct {
+	MaxBidSpend     *big.Int      // Total wei of the bids that received a commitment.
+	MaxFeeSpend     *big.Int      // Total wei of execution and blob fees paid by included and reserved by in-flight transactions.
+	MaxTxs          int           // Number of blob transactions sent.
+	MaxWindowSpend  *big.Int      // Wei of the bids that received a commitment per bidding window.
+	BlocksPerWindow uint64        // Number of L1 blocks per bidding window, used to assign bids to windows.
+	MaxDuration     time.Duration // Time the loop runs for.
+}
+
+// BudgetReport is a snapshot of what a Budget spent and what is left. Remaining amounts are nil for
+// disabled limits.
+type BudgetReport struct {
+	BidSpend             *big.Int
+	FeeSpend             *big.Int // Fees paid by included transactions.
+	ReservedFeeSpend     *big.Int // Worst-case fees of the transactions in flight.
+	Txs                  int
+	Window               uint64
+	WindowSpend          *big.Int
+	RemainingBidSpend    *big.Int
+	RemainingFeeSpend    *big.Int
+	RemainingTxs         *int
+	RemainingWindowSpend *big.Int
+	RemainingTime        time.Duration // Zero if the run duration is unlimited.
+}
+
+// Budget tracks the spending of the bidder loop against its limits. Bids are charged to the bidding
+// window of the block they target. Limits are checked before every transaction and bid, so the last
+// transaction or bid before a limit is reached may exceed it. The worst-case fees of transactions in flight
+// are reserved against the fee limit until they are included, replaced or abandoned.
+type Budget struct {
+	limits  BudgetLimits
+	started time.Time
+
+	mu          sync.Mutex
+	bidSpend    *big.Int
+	feeSpend    *big.Int
+	reserved    map[common.Hash]*big.Int // Worst-case fees of the transactions in flight.
+	reservedSum *big.Int
+	txs         int
+	window      uint64
+	windowSpend *big.Int
+}
+
+// NewBudget creates a budget whose run starts now.
+//
+// Parameters:
+// - limits: The limits of the run.
+//
+// Returns:
+// - A pointer to a Budget.
+func NewBudget(limits BudgetLimits) *Budget {
+	limits.BlocksPerWindow = max(limits.BlocksPerWindow, 1)
+	return &Budget{
+		limits:      limits,
+		started:     time.Now(),
+		bidSpend:    new(big.Int),
+		feeSpend:    new(big.Int),
+		reserved:    make(map[common.Hash]*big.Int),
+		reservedSum: new(big.Int),
+		windowSpend: new(big.Int),
+	}
+}
+
+// Deadline returns the time the run ends, and false if its duration is unlimited.
+func (b *Budget) Deadline() (time.Time, bool) {
+	if b.limits.MaxDuration <= 0 {
+		return time.Time{}, false
+	}
+	return b.started.Add(b.limits.MaxDuration), true
+}
+
+// WindowOf returns the bidding window of an L1 block, following the mev-commit block tracker.
+func (b *Budget) WindowOf(block uint64) uint64 {
+	if block == 0 {
+		return 0
+	}
+	return (block-1)/b.limits.BlocksPerWindow + 1
+}
+
+// RecordTx charges a sent blob transaction.
+func (b *Budget) RecordTx() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.txs++
+}
+
+// RecordBid charges a bid that received a commitment to the bidding window of the block it targeted.
+//
+// Parameters:
+// - block: The block the bid targeted.
+// - amount: The bid amount in wei.
+func (b *Budget) RecordBid(block uint64, amount *big.Int) {
+	if amount == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bidSpend.Add(b.bidSpend, amount)
+	if window := b.WindowOf(block); window != b.window {
+		if window < b.window {
+			return // The bid belongs to a window that is already over.
+		}
+		b.window = window
+		b.windowSpend.SetInt64(0)
+	}
+	b.windowSpend.Add(b.windowSpend, amount)
+}
+
+// RecordFees charges the fees paid by an included transaction.
+func (b *Budget) RecordFees(fees *big.Int) {
+	if fees == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.feeSpend.Add(b.feeSpend, fees)
+}
+
+// Reserve charges the worst-case fees of a sent transaction until it is settled or released. Reserving a
+// transaction again replaces its reservation.
+//
+// Parameters:
+// - hash: The hash of the transaction.
+// - fees: The most the transaction can pay in fees, see MaxTxFees.
+func (b *Budget) Reserve(hash common.Hash, fees *big.Int) {
+	if fees == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.release(hash)
+	b.reserved[hash] = new(big.Int).Set(fees)
+	b.reservedSum.Add(b.reservedSum, fees)
+}
+
+// Settle replaces the reservation of an included transaction with the fees it paid.
+//
+// Parameters:
+// - hash: The hash of the transaction.
+// - fees: The fees paid by the transaction.
+func (b *Budget) Settle(hash common.Hash, fees *big.Int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.release(hash)
+	if fees != nil {
+		b.feeSpend.Add(b.feeSpend, fees)
+	}
+}
+
+// Release drops the reservation of a transaction that was replaced or abandoned.
+func (b *Budget) Release(hash common.Hash) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.release(hash)
+}
+
+// release drops the reservation of a transaction, if any. The caller must hold b.mu.
+func (b *Budget) release(hash common.Hash) {
+	if fees, ok := b.reserved[hash]; ok {
+		b.reservedSum.Sub(b.reservedSum, fees)
+		delete(b.reserved, hash)
+	}
+}
+
+// RefundFees takes back the fees of a transaction whose block was reorged out, so that they are not
+// charged twice when it is included again. Fees charged in an earlier run are not taken below zero.
+func (b *Budget) RefundFees(fees *big.Int) {
+	if fees == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.feeSpend.Sub(b.feeSpend, fees)
+	if b.feeSpend.Sign() < 0 {
+		b.feeSpend.SetInt64(0)
+	}
+}
+
+// Check reports whether the loop may send another transaction or bid for the given block.
+//
+// Parameters:
+// - block: The block the next transaction or bid targets.
+//
+// Returns:
+// - An error wrapping ErrBudgetExhausted or, for the bidding window of block, ErrWindowBudgetExhausted, or nil.
+func (b *Budget) Check(block uint64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if deadline, ok := b.Deadline(); ok && !time.Now().Before(deadline) {
+		return fmt.Errorf("%w: run duration of %s reached", ErrBudgetExhausted, b.limits.MaxDuration)
+	}
+	if b.limits.MaxTxs > 0 && b.txs >= b.limits.MaxTxs {
+		return fmt.Errorf("%w: %d transactions sent", ErrBudgetExhausted, b.txs)
+	}
+	if b.limits.MaxBidSpend != nil && b.bidSpend.Cmp(b.limits.MaxBidSpend) >= 0 {
+		return fmt.Errorf("%w: %s wei bid", ErrBudgetExhausted, b.bidSpend)
+	}
+	if spent := new(big.Int).Add(b.feeSpend, b.reservedSum); b.limits.MaxFeeSpend != nil && spent.Cmp(b.limits.MaxFeeSpend) >= 0 {
+		return fmt.Errorf("%w: %s wei paid and %s wei reserved in fees", ErrBudgetExhausted, b.feeSpend, b.reservedSum)
+	}
+	window := b.WindowOf(block)
+	if b.limits.MaxWindowSpend != nil && window == b.window && b.windowSpend.Cmp(b.limits.MaxWindowSpend) >= 0 {
+		return fmt.Errorf("%w: %s wei bid in window %d", ErrWindowBudgetExhausted, b.windowSpend, window)
+	}
+	return nil
+}
+
+// Report returns what was spent and what is left of every limit.
+//
+// Parameters:
+// - block: The latest block, which selects the current bidding window.
+//
+// Returns:
+// - A BudgetReport.
+func (b *Budget) Report(block uint64) BudgetReport {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	report := BudgetReport{
+		BidSpend:         new(big.Int).Set(b.bidSpend),
+		FeeSpend:         new(big.Int).Set(b.feeSpend),
+		ReservedFeeSpend: new(big.Int).Set(b.reservedSum),
+		Txs:              b.txs,
+		Window:           b.WindowOf(block),
+		WindowSpend:      new(big.Int),
+	}
+	if report.Window == b.window {
+		report.WindowSpend.Set(b.windowSpend)
+	}
+	report.RemainingBidSpend = remaining(b.limits.MaxBidSpend, report.BidSpend)
+	report.RemainingFeeSpend = remaining(b.limits.MaxFeeSpend, new(big.Int).Add(report.FeeSpend, report.ReservedFeeSpend))
+	report.RemainingWindowSpend = remaining(b.limits.MaxWindowSpend, report.WindowSpend)
+	if b.limits.MaxTxs > 0 {
+		txs := max(b.limits.MaxTxs-b.txs, 0)
+		report.RemainingTxs = &txs
+	}
+	if deadline, ok := b.Deadline(); ok {
+		report.RemainingTime = max(time.Until(deadline), 0)
+	}
+	return report
+}
+
+// MaxTxFees returns the most a transaction can pay in execution and blob fees, its gas limit at its fee cap
+// plus its blob gas at its blob fee cap.
+func MaxTxFees(tx *types.Transaction) *big.Int {
+	fees := new(big.Int).Mul(tx.GasFeeCap(), new(big.Int).SetUint64(tx.Gas()))
+	if blobFeeCap := tx.BlobGasFeeCap(); blobFeeCap != nil {
+		fees.Add(fees, new(big.Int).Mul(blobFeeCap, new(big.Int).SetUint64(tx.BlobGas())))
+	}
+	return fees
+}
+
+// remaining returns what is left of limit after spent, never below zero, or nil if limit is nil.
+func remaining(limit, spent *big.Int) *big.Int {
+	if limit == nil {
+		return nil
+	}
+	left := new(big.Int).Sub(limit, spent)
+	if left.Sign() < 0 {
+		left.SetInt64(0)
+	}
+	return left
+}
@@ -0,0 +1,238 @@
+package eth
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/holiman/uint256"
+)
+
+func TestBudgetWindowOf(t *testing.T) {
+	budget := NewBudget(BudgetLimits{BlocksPerWindow: 10})
+	for _, tc := range []struct {
+		block, window uint64
+	}{
+		{0, 0},
+		{1, 1},
+		{10, 1},
+		{11, 2},
+		{20, 2},
+		{21, 3},
+	} {
+		if got := budget.WindowOf(tc.block); got != tc.window {
+			t.Errorf("WindowOf(%d) = %d, want %d", tc.block, got, tc.window)
+		}
+	}
+
+	// Zero blocks per window falls back to one window per block
+	if got := NewBudget(BudgetLimits{}).WindowOf(7); got != 7 {
+		t.Errorf("WindowOf(7) without blocks per window = %d, want 7", got)
+	}
+}
+
+func TestBudgetWindowSpend(t *testing.T) {
+	budget := NewBudget(BudgetLimits{BlocksPerWindow: 10})
+
+	budget.RecordBid(11, big.NewInt(100))
+	budget.RecordBid(15, big.NewInt(50))
+	if report := budget.Report(15); report.Window != 2 || report.WindowSpend.Int64() != 150 {
+		t.Errorf("window %d spend %s, want window 2 spend 150", report.Window, report.WindowSpend)
+	}
+
+	// A bid for a later window resets the window spend
+	budget.RecordBid(21, big.NewInt(30))
+	if report := budget.Report(21); report.Window != 3 || report.WindowSpend.Int64() != 30 {
+		t.Errorf("window %d spend %s, want window 3 spend 30", report.Window, report.WindowSpend)
+	}
+
+	// A bid for a window that is over counts only toward the total
+	budget.RecordBid(12, big.NewInt(5))
+	report := budget.Report(21)
+	if report.WindowSpend.Int64() != 30 {
+		t.Errorf("window spend %s after a bid for a closed window, want 30", report.WindowSpend)
+	}
+	if report.BidSpend.Int64() != 185 {
+		t.Errorf("bid spend %s, want 185", report.BidSpend)
+	}
+
+	// The spend of a window that is not the current one is not reported for a later head
+	if report := budget.Report(31); report.Window != 4 || report.WindowSpend.Sign() != 0 {
+		t.Errorf("window %d spend %s, want window 4 spend 0", report.Window, report.WindowSpend)
+	}
+
+	budget.RecordBid(31, nil)
+	if report := budget.Report(31); report.BidSpend.Int64() != 185 {
+		t.Errorf("bid spend %s after a nil bid, want 185", report.BidSpend)
+	}
+}
+
+func TestBudgetCheck(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		limits BudgetLimits
+		record func(b *Budget)
+		block  uint64
+		want   error
+	}{
+		{
+			name:   "no limits",
+			record: func(b *Budget) { b.RecordTx(); b.RecordBid(1, big.NewInt(1e18)); b.RecordFees(big.NewInt(1e18)) },
+			block:  2,
+		},
+		{
+			name:   "transactions below limit",
+			limits: BudgetLimits{MaxTxs: 2},
+			record: func(b *Budget) { b.RecordTx() },
+		},
+		{
+			name:   "transactions reached",
+			limits: BudgetLimits{MaxTxs: 2},
+			record: func(b *Budget) { b.RecordTx(); b.RecordTx() },
+			want:   ErrBudgetExhausted,
+		},
+		{
+			name:   "bid spend reached",
+			limits: BudgetLimits{MaxBidSpend: big.NewInt(100)},
+			record: func(b *Budget) { b.RecordBid(1, big.NewInt(60)); b.RecordBid(1, big.NewInt(40)) },
+			want:   ErrBudgetExhausted,
+		},
+		{
+			name:   "fee spend below limit",
+			limits: BudgetLimits{MaxFeeSpend: big.NewInt(100)},
+			record: func(b *Budget) { b.RecordFees(big.NewInt(99)) },
+		},
+		{
+			name:   "fee spend reached",
+			limits: BudgetLimits{MaxFeeSpend: big.NewInt(100)},
+			record: func(b *Budget) { b.RecordFees(big.NewInt(120)) },
+			want:   ErrBudgetExhausted,
+		},
+		{
+			name:   "fee spend reached with reservations",
+			limits: BudgetLimits{MaxFeeSpend: big.NewInt(100)},
+			record: func(b *Budget) { b.RecordFees(big.NewInt(40)); b.Reserve(common.HexToHash("0x01"), big.NewInt(60)) },
+			want:   ErrBudgetExhausted,
+		},
+		{
+			name:   "released reservation",
+			limits: BudgetLimits{MaxFeeSpend: big.NewInt(100)},
+			record: func(b *Budget) {
+				b.Reserve(common.HexToHash("0x01"), big.NewInt(100))
+				b.Release(common.HexToHash("0x01"))
+			},
+		},
+		{
+			name:   "run duration reached",
+			limits: BudgetLimits{MaxDuration: time.Nanosecond},
+			record: func(*Budget) { time.Sleep(time.Millisecond) },
+			want:   ErrBudgetExhausted,
+		},
+		{
+			name:   "window spend reached",
+			limits: BudgetLimits{MaxWindowSpend: big.NewInt(100), BlocksPerWindow: 10},
+			record: func(b *Budget) { b.RecordBid(11, big.NewInt(100)) },
+			block:  12,
+			want:   ErrWindowBudgetExhausted,
+		},
+		{
+			name:   "window spend of the previous window",
+			limits: BudgetLimits{MaxWindowSpend: big.NewInt(100), BlocksPerWindow: 10},
+			record: func(b *Budget) { b.RecordBid(11, big.NewInt(100)) },
+			block:  21,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			budget := NewBudget(tc.limits)
+			tc.record(budget)
+			err := budget.Check(tc.block)
+			if tc.want == nil && err != nil {
+				t.Errorf("Check = %v, want nil", err)
+			}
+			if tc.want != nil && !errors.Is(err, tc.want) {
+				t.Errorf("Check = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestBudgetReport(t *testing.T) {
+	budget := NewBudget(BudgetLimits{
+		MaxBidSpend:     big.NewInt(100),
+		MaxFeeSpend:     big.NewInt(1000),
+		MaxTxs:          1,
+		MaxWindowSpend:  big.NewInt(50),
+		BlocksPerWindow: 10,
+		MaxDuration:     time.Hour,
+	})
+	budget.RecordBid(1, big.NewInt(80))
+	budget.RecordFees(big.NewInt(400))
+	budget.RecordTx()
+	budget.RecordTx()
+
+	report := budget.Report(1)
+	if report.RemainingBidSpend.Int64() != 20 || report.RemainingFeeSpend.Int64() != 600 {
+		t.Errorf("remaining bid spend %s, fee spend %s, want 20 and 600", report.RemainingBidSpend, report.RemainingFeeSpend)
+	}
+	if report.RemainingWindowSpend.Sign() != 0 {
+		t.Errorf("remaining window spend %s, want it clamped at 0", report.RemainingWindowSpend)
+	}
+	if report.RemainingTxs == nil || *report.RemainingTxs != 0 {
+		t.Errorf("remaining transactions %v, want it clamped at 0", report.RemainingTxs)
+	}
+	if report.RemainingTime <= 0 || report.RemainingTime > time.Hour {
+		t.Errorf("remaining time %s, want within the hour", report.RemainingTime)
+	}
+
+	unlimited := NewBudget(BudgetLimits{}).Report(1)
+	if unlimited.RemainingBidSpend != nil || unlimited.RemainingFeeSpend != nil || unlimited.RemainingWindowSpend != nil ||
+		unlimited.RemainingTxs != nil || unlimited.RemainingTime != 0 {
+		t.Errorf("unlimited budget reports remaining amounts %+v", unlimited)
+	}
+}
+
+func TestBudgetReservations(t *testing.T) {
+	budget := NewBudget(BudgetLimits{MaxFeeSpend: big.NewInt(1000)})
+	first, second, third := common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x03")
+
+	for _, step := range []struct {
+		name                    string
+		apply                   func()
+		paid, reserved, remains int64
+	}{
+		{"reserve", func() { budget.Reserve(first, big.NewInt(300)); budget.Reserve(second, big.NewInt(200)) }, 0, 500, 500},
+		{"reserve again", func() { budget.Reserve(second, big.NewInt(250)) }, 0, 550, 450},
+		{"settle", func() { budget.Settle(first, big.NewInt(120)) }, 120, 250, 630},
+		{"settle twice", func() { budget.Settle(first, big.NewInt(0)) }, 120, 250, 630},
+		{"release", func() { budget.Release(second) }, 120, 0, 880},
+		{"release unknown", func() { budget.Release(third) }, 120, 0, 880},
+		{"settle unreserved", func() { budget.Settle(third, big.NewInt(80)) }, 200, 0, 800},
+	} {
+		step.apply()
+		report := budget.Report(1)
+		if report.FeeSpend.Int64() != step.paid || report.ReservedFeeSpend.Int64() != step.reserved || report.RemainingFeeSpend.Int64() != step.remains {
+			t.Errorf("%s: paid %s, reserved %s, remaining %s, want %d, %d, %d", step.name,
+				report.FeeSpend, report.ReservedFeeSpend, report.RemainingFeeSpend, step.paid, step.reserved, step.remains)
+		}
+	}
+}
+
+func TestMaxTxFees(t *testing.T) {
+	blobTx := types.NewTx(&types.BlobTx{
+		GasFeeCap:  uint256.NewInt(100),
+		Gas:        21000,
+		BlobFeeCap: uint256.NewInt(10),
+		BlobHashes: make([]common.Hash, 2),
+		Value:      uint256.NewInt(1e9),
+	})
+	if got, want := MaxTxFees(blobTx), int64(21000*100+2*131072*10); got.Int64() != want {
+		t.Errorf("MaxTxFees of a blob transaction = %s, want %d", got, want)
+	}
+	dynamicTx := types.NewTx(&types.DynamicFeeTx{GasFeeCap: big.NewInt(100), Gas: 21000})
+	if got := MaxTxFees(dynamicTx); got.Int64() != 21000*100 {
+		t.Errorf("MaxTxFees of a dynamic fee transaction = %s, want %d", got, 21000*100)
+	}
+}
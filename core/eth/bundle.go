@@ -1,80 +1,157 @@
 package eth
 
 import (
-	"bytes"
-	"encoding/json"
-	"io/ioutil"
-	"net/http"
+	"context"
+	"crypto/ecdsa"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/log"
 )
 
-type FlashbotsPayload struct {
-	Jsonrpc string                   `json:"jsonrpc"`
-	Method  string                   `json:"method"`
-	Params  []map[string]interface{} `json:"params"`
-	ID      int                      `json:"id"`
+// SecondsPerSlot is the time between two L1 blocks, used to predict the timestamp of upcoming blocks.
+const SecondsPerSlot = 12
+
+// BundleParams holds the optional eth_sendBundle parameters.
+type BundleParams struct {
+	MinTimestamp      uint64        // Earliest block timestamp the bundle is valid for. Zero uses the submitter's option.
+	MaxTimestamp      uint64        // Latest block timestamp the bundle is valid for. Zero uses the submitter's option.
+	RevertingTxHashes []common.Hash // Transactions of the bundle that are allowed to revert.
+	ReplacementUUID   string        // Identifier that lets a later bundle replace or cancel this one.
+	TargetTime        uint64        // Expected timestamp of the target block, which the submitter's options are relative to. Zero ignores the options.
 }
 
-var httpClient = &http.Client{
-	Timeout: 12 * time.Second,
-	Transport: &http.Transport{
-		DisableKeepAlives:   false,
-		MaxIdleConnsPerHost: 1,
-		IdleConnTimeout:     12 * time.Second,
-		TLSHandshakeTimeout: 10 * time.Second,
-	},
+// BundleOptions configure every bundle sent by a BundleSubmitter. The validity window is relative to the
+// expected timestamp of the target block, so that it moves along with the blocks the bundles target.
+type BundleOptions struct {
+	SigningKey         *ecdsa.PrivateKey // The searcher reputation key used to sign requests. It must not hold funds. Nil disables signing.
+	MinTimestampOffset time.Duration     // Offset of the earliest block timestamp the bundles are valid for. Zero leaves it unset.
+	MaxTimestampOffset time.Duration     // Offset of the latest block timestamp the bundles are valid for. Zero leaves it unset.
 }
 
-func SendBundle(RPCURL string, signedTx *types.Transaction, blkNum uint64) (string, error) {
-	binary, err := signedTx.MarshalBinary()
-	if err != nil {
-		log.Error("Error marshal transaction", "err", err)
-		return "", err
-	}
+// bundleArgs is the eth_sendBundle request object.
+type bundleArgs struct {
+	Txs               []string      `json:"txs"`
+	BlockNumber       string        `json:"blockNumber"`
+	MinTimestamp      uint64        `json:"minTimestamp,omitempty"`
+	MaxTimestamp      uint64        `json:"maxTimestamp,omitempty"`
+	RevertingTxHashes []common.Hash `json:"revertingTxHashes,omitempty"`
+	ReplacementUUID   string        `json:"replacementUuid,omitempty"`
+}
 
-	blockNum := hexutil.EncodeUint64(blkNum)
-
-	payload := FlashbotsPayload{
-		Jsonrpc: "2.0",
-		Method:  "eth_sendBundle",
-		Params: []map[string]interface{}{
-			{
-				"txs": []string{
-					hexutil.Encode(binary),
-				},
-				"blockNumber": blockNum,
-			},
-		},
-		ID: 1,
+// ExpectedTimestamp predicts the timestamp of an upcoming block from the latest head, assuming no
+// slot is missed until then.
+//
+// Parameters:
+// - head: The latest block header.
+// - block: The number of the upcoming block.
+//
+// Returns:
+// - The expected timestamp of block, or the timestamp of head if block is not after it.
+func ExpectedTimestamp(head *types.Header, block uint64) uint64 {
+	number := head.Number.Uint64()
+	if block <= number {
+		return head.Time
 	}
+	return head.Time + SecondsPerSlot*(block-number)
+}
 
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return "", err
-	}
+// offsetTimestamp applies an offset to a timestamp, never going below 1 so that the result stays set.
+func offsetTimestamp(timestamp uint64, offset time.Duration) uint64 {
+	return uint64(max(int64(timestamp)+int64(offset/time.Second), 1))
+}
 
-	req, err := http.NewRequest("POST", RPCURL, bytes.NewBuffer(payloadBytes))
-	if err != nil {
-		log.Error("an error occurred creating request", "err", err)
+// BundleSubmitter sends transactions as Flashbots-style bundles through eth_sendBundle. Requests are signed
+// with the searcher reputation key in the X-Flashbots-Signature header when a signing key is set.
+type BundleSubmitter struct {
+	url     string
+	options BundleOptions
+}
+
+// NewBundleSubmitter creates a submitter that sends bundles to a relay or builder.
+//
+// Parameters:
+// - url: The relay or builder endpoint.
+// - options: The signing key and validity window of the bundles.
+//
+// Returns:
+// - A pointer to a BundleSubmitter.
+func NewBundleSubmitter(url string, options BundleOptions) *BundleSubmitter {
+	return &BundleSubmitter{url: url, options: options}
+}
+
+// Endpoint implements Submitter.
+func (s *BundleSubmitter) Endpoint() string { return s.url }
+
+// Method implements Submitter.
+func (s *BundleSubmitter) Method() string { return MethodBundle }
+
+// Submit sends signedTx as a single transaction bundle targeting blockNumber. The target block time is
+// unknown here, so the validity window of the options is not applied.
+func (s *BundleSubmitter) Submit(ctx context.Context, signedTx *types.Transaction, blockNumber uint64) (string, error) {
+	return s.SendBundle(ctx, []*types.Transaction{signedTx}, blockNumber, BundleParams{})
+}
+
+// SendBundle sends a bundle of signed transactions targeting blockNumber.
+//
+// Parameters:
+// - ctx: The context for the request.
+// - txs: The signed transactions of the bundle, in execution order.
+// - blockNumber: The block number the bundle targets.
+// - params: The optional bundle parameters. Unset timestamps default to the options of the submitter,
+// applied to params.TargetTime.
+//
+// Returns:
+// - The raw JSON-RPC result, or an error if the request fails or the bundle is rejected.
+func (s *BundleSubmitter) SendBundle(ctx context.Context, txs []*types.Transaction, blockNumber uint64, params BundleParams) (string, error) {
+	args := bundleArgs{
+		BlockNumber:       hexutil.EncodeUint64(blockNumber),
+		MinTimestamp:      params.MinTimestamp,
+		MaxTimestamp:      params.MaxTimestamp,
+		RevertingTxHashes: params.RevertingTxHashes,
+		ReplacementUUID:   params.ReplacementUUID,
+	}
+	if params.TargetTime != 0 {
+		if args.MinTimestamp == 0 && s.options.MinTimestampOffset != 0 {
+			args.MinTimestamp = offsetTimestamp(params.TargetTime, s.options.MinTimestampOffset)
+		}
+		if args.MaxTimestamp == 0 && s.options.MaxTimestampOffset != 0 {
+			args.MaxTimestamp = offsetTimestamp(params.TargetTime, s.options.MaxTimestampOffset)
+		}
+	}
+	for _, tx := range txs {
+		binary, err := tx.MarshalBinary()
+		if err != nil {
+			return "", err
+		}
+		args.Txs = append(args.Txs, hexutil.Encode(binary))
 	}
-	req.Header.Add("Content-Type", "application/json")
 
-	resp, err := httpClient.Do(req)
+	result, err := callJSONRPC(ctx, s.url, "eth_sendBundle", []bundleArgs{args}, s.options.SigningKey)
 	if err != nil {
-		log.Error("an error occurred", "err", err)
 		return "", err
 	}
-	defer resp.Body.Close()
+	return string(result), nil
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+// cancelBundleArgs is the eth_cancelBundle request object.
+type cancelBundleArgs struct {
+	ReplacementUUID string `json:"replacementUuid"`
+}
+
+// CancelBundle cancels the bundle that was sent with the given replacement UUID.
+//
+// Parameters:
+// - ctx: The context for the request.
+// - replacementUUID: The replacement UUID the bundle was sent with.
+//
+// Returns:
+// - The raw JSON-RPC result, or an error if the request fails.
+func (s *BundleSubmitter) CancelBundle(ctx context.Context, replacementUUID string) (string, error) {
+	result, err := callJSONRPC(ctx, s.url, "eth_cancelBundle", []cancelBundleArgs{{ReplacementUUID: replacementUUID}}, s.options.SigningKey)
 	if err != nil {
-		log.Error("an error occurred", "err", err)
 		return "", err
 	}
-
-	return string(body), nil
+	return string(result), nil
 }
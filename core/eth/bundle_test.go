@@ -0,0 +1,92 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestBundleSubmitterParams(t *testing.T) {
+	tx := types.NewTx(&types.DynamicFeeTx{Nonce: 1, Gas: 21000, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(10)})
+	options := BundleOptions{MinTimestampOffset: -12 * time.Second, MaxTimestampOffset: 24 * time.Second}
+	reverting := []common.Hash{common.HexToHash("0x01")}
+
+	tests := []struct {
+		name   string
+		params BundleParams
+		want   bundleArgs
+	}{
+		{
+			name:   "no target time leaves the window unset",
+			params: BundleParams{},
+			want:   bundleArgs{},
+		},
+		{
+			name:   "options relative to the target time",
+			params: BundleParams{ReplacementUUID: "id", TargetTime: 1000},
+			want:   bundleArgs{MinTimestamp: 988, MaxTimestamp: 1024, ReplacementUUID: "id"},
+		},
+		{
+			name:   "params override the options",
+			params: BundleParams{MinTimestamp: 1500, MaxTimestamp: 1600, TargetTime: 1000},
+			want:   bundleArgs{MinTimestamp: 1500, MaxTimestamp: 1600},
+		},
+		{
+			name:   "reverting transactions",
+			params: BundleParams{RevertingTxHashes: reverting},
+			want:   bundleArgs{RevertingTxHashes: reverting},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bundleArgs
+			server := newRPCStub(t, func(method string, params []json.RawMessage) (interface{}, error) {
+				if method != "eth_sendBundle" {
+					return nil, fmt.Errorf("unexpected method %s", method)
+				}
+				if err := json.Unmarshal(params[0], &got); err != nil {
+					return nil, err
+				}
+				return map[string]string{"bundleHash": "0x01"}, nil
+			})
+
+			if _, err := NewBundleSubmitter(server.URL, options).SendBundle(context.Background(), []*types.Transaction{tx}, 42, tt.params); err != nil {
+				t.Fatalf("SendBundle: %v", err)
+			}
+			if got.BlockNumber != hexutil.EncodeUint64(42) || len(got.Txs) != 1 {
+				t.Errorf("bundle = %+v, want one transaction for block 42", got)
+			}
+			if got.MinTimestamp != tt.want.MinTimestamp || got.MaxTimestamp != tt.want.MaxTimestamp || got.ReplacementUUID != tt.want.ReplacementUUID {
+				t.Errorf("bundle params = %d, %d, %q, want %d, %d, %q", got.MinTimestamp, got.MaxTimestamp, got.ReplacementUUID,
+					tt.want.MinTimestamp, tt.want.MaxTimestamp, tt.want.ReplacementUUID)
+			}
+			if !reflect.DeepEqual(got.RevertingTxHashes, tt.want.RevertingTxHashes) {
+				t.Errorf("reverting tx hashes = %v, want %v", got.RevertingTxHashes, tt.want.RevertingTxHashes)
+			}
+		})
+	}
+}
+
+func TestExpectedTimestamp(t *testing.T) {
+	head := &types.Header{Number: big.NewInt(100), Time: 1200}
+	for _, tc := range []struct {
+		block, want uint64
+	}{
+		{99, 1200},
+		{100, 1200},
+		{101, 1212},
+		{103, 1236},
+	} {
+		if got := ExpectedTimestamp(head, tc.block); got != tc.want {
+			t.Errorf("ExpectedTimestamp(%d) = %d, want %d", tc.block, got, tc.want)
+		}
+	}
+}
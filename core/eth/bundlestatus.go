@@ -0,0 +1,278 @@
+package eth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/primev/preconf_blob_bidder/core/logging"
+)
+
+// Stages a submitted bundle goes through at a relay, from least to most advanced.
+const (
+	BundleStageSent       = "sent"       // The relay accepted the request but reported nothing yet.
+	BundleStageReceived   = "received"   // The relay recorded the bundle.
+	BundleStageSimulated  = "simulated"  // The relay simulated the bundle.
+	BundleStageConsidered = "considered" // At least one builder considered the bundle for a block.
+	BundleStageSealed     = "sealed"     // At least one builder sealed a block with the bundle.
+)
+
+// BundleStatus is what a relay reported about one submitted bundle.
+type BundleStatus struct {
+	Endpoint     string      // The relay the bundle was sent to.
+	BundleHash   common.Hash // The bundle hash returned by eth_sendBundle.
+	Block        uint64      // The block the bundle targeted.
+	ReceivedAt   time.Time   // When the relay received the bundle. Zero if unknown.
+	SimulatedAt  time.Time   // When the relay simulated the bundle. Zero if not simulated.
+	ConsideredAt time.Time   // When the first builder considered the bundle. Zero if no builder did.
+	Builders     int         // The number of builders that considered the bundle.
+	SealedAt     time.Time   // When the first builder sealed a block with the bundle. Zero if none did.
+}
+
+// Stage returns the most advanced stage the bundle reached.
+func (s BundleStatus) Stage() string {
+	switch {
+	case !s.SealedAt.IsZero():
+		return BundleStageSealed
+	case !s.ConsideredAt.IsZero():
+		return BundleStageConsidered
+	case !s.SimulatedAt.IsZero():
+		return BundleStageSimulated
+	case !s.ReceivedAt.IsZero():
+		return BundleStageReceived
+	default:
+		return BundleStageSent
+	}
+}
+
+// FurthestStage returns the most advanced stage reached by any of the bundles of a transaction,
+// which tells where in the pipeline a transaction that was not included got lost.
+func FurthestStage(statuses []BundleStatus) string {
+	stages := []string{BundleStageSent, BundleStageReceived, BundleStageSimulated, BundleStageConsidered, BundleStageSealed}
+	furthest := 0
+	for _, status := range statuses {
+		stage := status.Stage()
+		for i := furthest + 1; i < len(stages); i++ {
+			if stages[i] == stage {
+				furthest = i
+			}
+		}
+	}
+	return stages[furthest]
+}
+
+// BundleHash extracts the bundle hash from an eth_sendBundle result.
+//
+// Parameters:
+// - response: The raw JSON-RPC result of eth_sendBundle.
+//
+// Returns:
+// - The bundle hash and true, or false if the result holds no bundle hash.
+func BundleHash(response string) (common.Hash, bool) {
+	var result struct {
+		BundleHash common.Hash `json:"bundleHash"`
+	}
+	if err := json.Unmarshal([]byte(response), &result); err != nil || result.BundleHash == (common.Hash{}) {
+		return common.Hash{}, false
+	}
+	return result.BundleHash, true
+}
+
+// bundleStatsArgs is the flashbots_getBundleStatsV2 request object.
+type bundleStatsArgs struct {
+	BundleHash  common.Hash `json:"bundleHash"`
+	BlockNumber string      `json:"blockNumber"`
+}
+
+// builderTimestamp is a builder event reported by flashbots_getBundleStatsV2.
+type builderTimestamp struct {
+	Pubkey    string `json:"pubkey"`
+	Timestamp string `json:"timestamp"`
+}
+
+// bundleStatsResult is the flashbots_getBundleStatsV2 response object.
+type bundleStatsResult struct {
+	IsSimulated            bool               `json:"isSimulated"`
+	ReceivedAt             string             `json:"receivedAt"`
+	SimulatedAt            string             `json:"simulatedAt"`
+	ConsideredByBuildersAt []builderTimestamp `json:"consideredByBuildersAt"`
+	SealedByBuildersAt     []builderTimestamp `json:"sealedByBuildersAt"`
+}
+
+// bundleStatsTimeout bounds every flashbots_getBundleStatsV2 request, well below the 12 second slot time.
+const bundleStatsTimeout = 3 * time.Second
+
+// BundleStatusPoller asks relays that support flashbots_getBundleStatsV2 what happened to the bundles
+// that were submitted to them. Relays that do not support the method are no longer polled.
+type BundleStatusPoller struct {
+	mu          sync.Mutex
+	signingKey  *ecdsa.PrivateKey
+	tracked     map[common.Hash][]*BundleStatus // Bundle statuses by transaction hash.
+	unsupported map[string]bool
+}
+
+// NewBundleStatusPoller creates a bundle status poller.
+//
+// Parameters:
+// - signingKey: The searcher reputation key the bundles were signed with. Relays only report stats of
+// bundles to the searcher that sent them. Nil disables signing.
+//
+// Returns:
+// - A pointer to a BundleStatusPoller.
+func NewBundleStatusPoller(signingKey *ecdsa.PrivateKey) *BundleStatusPoller {
+	return &BundleStatusPoller{
+		signingKey:  signingKey,
+		tracked:     make(map[common.Hash][]*BundleStatus),
+		unsupported: make(map[string]bool),
+	}
+}
+
+// Track starts following the bundles of successful bundle submissions. Other results are ignored.
+//
+// Parameters:
+// - results: The submission results returned by Broadcast or a BundleScheduler.
+func (p *BundleStatusPoller) Track(results []SubmitResult) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, result := range results {
+		if result.Err != nil || result.Method != MethodBundle || p.unsupported[result.Endpoint] {
+			continue
+		}
+		bundleHash, ok := BundleHash(result.Response)
+		if !ok {
+			continue
+		}
+		p.tracked[result.TxHash] = append(p.tracked[result.TxHash], &BundleStatus{Endpoint: result.Endpoint, BundleHash: bundleHash, Block: result.Block})
+	}
+}
+
+// Poll refreshes the status of every tracked bundle whose target block is at most one block behind head.
+// Stats of older bundles are final and are not requested again. The bundles are requested concurrently,
+// each within bundleStatsTimeout, and without holding the lock, so a slow relay delays the caller by at
+// most bundleStatsTimeout.
+//
+// Parameters:
+// - ctx: The context for the requests.
+// - head: The number of the latest block.
+//
+// Returns:
+// - The statuses of all tracked bundles by transaction hash, for the transactions that had a bundle refreshed.
+func (p *BundleStatusPoller) Poll(ctx context.Context, head uint64) map[common.Hash][]BundleStatus {
+	logger := logging.FromContext(ctx, nil)
+
+	// Pick the bundles to refresh under the lock, their stats are requested on copies.
+	type request struct {
+		txHash    common.Hash
+		status    *BundleStatus
+		refreshed BundleStatus
+		err       error
+	}
+	var requests []*request
+	p.mu.Lock()
+	for txHash, statuses := range p.tracked {
+		for _, status := range statuses {
+			if status.Block+1 < head || p.unsupported[status.Endpoint] {
+				continue
+			}
+			requests = append(requests, &request{txHash: txHash, status: status, refreshed: *status})
+		}
+	}
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, req := range requests {
+		wg.Add(1)
+		go func(req *request) {
+			defer wg.Done()
+			callCtx, cancel := context.WithTimeout(ctx, bundleStatsTimeout)
+			defer cancel()
+			req.err = p.refresh(callCtx, &req.refreshed)
+		}(req)
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	refreshed := make(map[common.Hash]bool)
+	for _, req := range requests {
+		if errors.Is(req.err, ErrUnknownMethod) {
+			if !p.unsupported[req.status.Endpoint] {
+				logger.Warn("relay does not report bundle stats, no longer polling it", logging.KeyEndpoint, req.status.Endpoint)
+			}
+			p.unsupported[req.status.Endpoint] = true
+			continue
+		}
+		if req.err != nil {
+			logger.Debug("failed to get bundle stats", logging.KeyEndpoint, req.status.Endpoint, logging.KeyTxHash, req.txHash, "bundleHash", req.status.BundleHash, logging.KeyErr, req.err)
+			continue
+		}
+		*req.status = req.refreshed
+		refreshed[req.txHash] = true
+	}
+
+	// Transactions that were forgotten while their bundles were requested are not reported.
+	updated := make(map[common.Hash][]BundleStatus)
+	for txHash := range refreshed {
+		for _, status := range p.tracked[txHash] {
+			updated[txHash] = append(updated[txHash], *status)
+		}
+	}
+	return updated
+}
+
+// Forget stops tracking the bundles of a transaction once it was included or abandoned.
+func (p *BundleStatusPoller) Forget(txHash common.Hash) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.tracked, txHash)
+}
+
+// refresh requests the stats of one bundle and updates its status.
+func (p *BundleStatusPoller) refresh(ctx context.Context, status *BundleStatus) error {
+	args := bundleStatsArgs{BundleHash: status.BundleHash, BlockNumber: hexutil.EncodeUint64(status.Block)}
+	raw, err := callJSONRPC(ctx, status.Endpoint, "flashbots_getBundleStatsV2", []bundleStatsArgs{args}, p.signingKey)
+	if err != nil {
+		return err
+	}
+	var result bundleStatsResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformedJSONRPCPayload, err)
+	}
+
+	status.ReceivedAt = parseTimestamp(result.ReceivedAt)
+	if result.IsSimulated {
+		status.SimulatedAt = parseTimestamp(result.SimulatedAt)
+	}
+	status.Builders = len(result.ConsideredByBuildersAt)
+	status.ConsideredAt = earliestTimestamp(result.ConsideredByBuildersAt)
+	status.SealedAt = earliestTimestamp(result.SealedByBuildersAt)
+	return nil
+}
+
+// earliestTimestamp returns the earliest of the builder timestamps, or the zero time if there are none.
+func earliestTimestamp(events []builderTimestamp) time.Time {
+	var earliest time.Time
+	for _, event := range events {
+		if t := parseTimestamp(event.Timestamp); !t.IsZero() && (earliest.IsZero() || t.Before(earliest)) {
+			earliest = t
+		}
+	}
+	return earliest
+}
+
+// parseTimestamp parses an RFC 3339 timestamp, returning the zero time if it is missing or invalid.
+func parseTimestamp(value string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
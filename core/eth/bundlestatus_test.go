@@ -0,0 +1,64 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// bundleResponse returns the eth_sendBundle result holding the given bundle hash.
+func bundleResponse(hash string) string {
+	return `{"bundleHash":"` + common.HexToHash(hash).Hex() + `"}`
+}
+
+func TestBundleStatusPollerPoll(t *testing.T) {
+	var statsCalls, unsupportedCalls atomic.Int32
+	stats := newRPCStub(t, func(method string, params []json.RawMessage) (interface{}, error) {
+		statsCalls.Add(1)
+		return json.RawMessage(`{"isSimulated":true,"receivedAt":"2024-08-01T10:00:00.1Z","simulatedAt":"2024-08-01T10:00:00.2Z",
+			"consideredByBuildersAt":[{"pubkey":"0x01","timestamp":"2024-08-01T10:00:00.5Z"},{"pubkey":"0x02","timestamp":"2024-08-01T10:00:00.3Z"}]}`), nil
+	})
+	unsupported := newRPCStub(t, func(string, []json.RawMessage) (interface{}, error) {
+		unsupportedCalls.Add(1)
+		return nil, &RPCError{Code: -32601, Message: "method not found"}
+	})
+
+	txHash := common.HexToHash("0x01")
+	oldTxHash := common.HexToHash("0x02")
+	poller := NewBundleStatusPoller(nil)
+	poller.Track([]SubmitResult{
+		{Endpoint: stats.URL, Method: MethodBundle, TxHash: txHash, Block: 100, Response: bundleResponse("0x0a")},
+		{Endpoint: unsupported.URL, Method: MethodBundle, TxHash: txHash, Block: 100, Response: bundleResponse("0x0b")},
+		{Endpoint: stats.URL, Method: MethodBundle, TxHash: oldTxHash, Block: 90, Response: bundleResponse("0x0c")},
+		{Endpoint: stats.URL, Method: MethodRawTx, TxHash: txHash, Block: 100, Response: `"0x01"`},
+	})
+
+	updated := poller.Poll(context.Background(), 100)
+	if len(updated) != 1 || len(updated[txHash]) != 2 {
+		t.Fatalf("Poll() = %+v, want both bundles of %s", updated, txHash)
+	}
+	if got := FurthestStage(updated[txHash]); got != BundleStageConsidered {
+		t.Errorf("FurthestStage() = %s, want %s", got, BundleStageConsidered)
+	}
+	for _, status := range updated[txHash] {
+		if status.Endpoint == stats.URL && (status.Builders != 2 || status.ConsideredAt.Nanosecond() != 300_000_000) {
+			t.Errorf("status = %+v, want 2 builders first considering at .3s", status)
+		}
+	}
+	if statsCalls.Load() != 1 || unsupportedCalls.Load() != 1 {
+		t.Fatalf("relays polled %d and %d times, want once each", statsCalls.Load(), unsupportedCalls.Load())
+	}
+
+	// The relay without the method is no longer polled and forgotten transactions are not reported.
+	poller.Poll(context.Background(), 101)
+	if unsupportedCalls.Load() != 1 {
+		t.Errorf("relay without bundle stats polled again")
+	}
+	poller.Forget(txHash)
+	if updated := poller.Poll(context.Background(), 101); len(updated) != 0 {
+		t.Errorf("Poll() after Forget = %+v, want nothing", updated)
+	}
+}
@@ -0,0 +1,222 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Fee strategy names accepted by NewFeeStrategy.
+const (
+	FeeStrategyFeeHistory = "feehistory" // Tip from eth_feeHistory reward percentiles.
+	FeeStrategyBaseFee    = "basefee"    // Fee cap from the base fee with headroom for several blocks.
+	FeeStrategyFixed      = "fixed"      // Fixed tip and fee cap.
+)
+
+// FeeStrategy picks the execution gas fees of a transaction. Whatever the strategy returns, GasFees
+// raises the fee cap so that it always covers the next block's base fee plus the tip.
+type FeeStrategy interface {
+	// SuggestFees returns the gas tip cap and gas fee cap for a transaction built on top of parent.
+	SuggestFees(ctx context.Context, client *ethclient.Client, parent *types.Header) (tipCap, feeCap *big.Int, err error)
+}
+
+// FeeConfig holds the settings of all fee strategies.
+type FeeConfig struct {
+	Strategy       string   // One of FeeStrategyFeeHistory, FeeStrategyBaseFee or FeeStrategyFixed.
+	HistoryBlocks  uint64   // Number of blocks sampled by the fee history strategy.
+	Percentile     float64  // Reward percentile used by the fee history strategy.
+	HeadroomBlocks int      // Number of full blocks the fee cap must survive.
+	TipCap         *big.Int // Tip for the base fee and fixed strategies. Nil asks the node.
+	FeeCap         *big.Int // Fee cap for the fixed strategy.
+	MaxTipCap      *big.Int // Upper bound on the tip of the fee history strategy. Nil disables it.
+}
+
+// NewFeeStrategy creates the fee strategy selected by cfg.Strategy.
+//
+// Parameters:
+// - cfg: The fee configuration.
+//
+// Returns:
+// - The FeeStrategy, or an error if the strategy is unknown or misconfigured.
+func NewFeeStrategy(cfg FeeConfig) (FeeStrategy, error) {
+	switch cfg.Strategy {
+	case FeeStrategyFeeHistory:
+		if cfg.Percentile < 0 || cfg.Percentile > 100 {
+			return nil, fmt.Errorf("fee history percentile %v out of range", cfg.Percentile)
+		}
+		return &FeeHistoryStrategy{Blocks: max(cfg.HistoryBlocks, 1), Percentile: cfg.Percentile, HeadroomBlocks: cfg.HeadroomBlocks, MaxTipCap: cfg.MaxTipCap}, nil
+	case FeeStrategyBaseFee:
+		return &BaseFeeStrategy{HeadroomBlocks: cfg.HeadroomBlocks, TipCap: cfg.TipCap}, nil
+	case FeeStrategyFixed:
+		if cfg.TipCap == nil || cfg.FeeCap == nil {
+			return nil, errors.New("fixed fee strategy needs a tip cap and a fee cap")
+		}
+		return &FixedFeeStrategy{TipCap: cfg.TipCap, FeeCap: cfg.FeeCap}, nil
+	default:
+		return nil, fmt.Errorf("unknown fee strategy %q", cfg.Strategy)
+	}
+}
+
+// FeeHistoryStrategy sets the tip to the median of a reward percentile over recent blocks, as reported by
+// eth_feeHistory, and the fee cap to the next block's base fee with headroom plus the tip.
+type FeeHistoryStrategy struct {
+	Blocks         uint64   // Number of recent blocks to sample.
+	Percentile     float64  // Reward percentile of each block, between 0 and 100.
+	HeadroomBlocks int      // Number of full blocks the fee cap must survive.
+	MaxTipCap      *big.Int // Upper bound on the tip. Nil disables it.
+}
+
+// SuggestFees implements FeeStrategy.
+func (s *FeeHistoryStrategy) SuggestFees(ctx context.Context, client *ethclient.Client, parent *types.Header) (*big.Int, *big.Int, error) {
+	history, err := client.FeeHistory(ctx, s.Blocks, parent.Number, []float64{s.Percentile})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get fee history: %w", err)
+	}
+
+	var rewards []*big.Int
+	for _, reward := range history.Reward {
+		if len(reward) > 0 && reward[0] != nil {
+			rewards = append(rewards, reward[0])
+		}
+	}
+	tipCap := new(big.Int)
+	if len(rewards) > 0 {
+		sort.Slice(rewards, func(i, j int) bool { return rewards[i].Cmp(rewards[j]) < 0 })
+		tipCap.Set(rewards[len(rewards)/2])
+	}
+	if s.MaxTipCap != nil && tipCap.Cmp(s.MaxTipCap) > 0 {
+		tipCap.Set(s.MaxTipCap)
+	}
+
+	feeCap := baseFeeWithHeadroom(parent, s.HeadroomBlocks)
+	return tipCap, feeCap.Add(feeCap, tipCap), nil
+}
+
+// BaseFeeStrategy sets the fee cap to the highest base fee reachable after HeadroomBlocks full blocks plus the tip.
+type BaseFeeStrategy struct {
+	HeadroomBlocks int      // Number of full blocks the fee cap must survive.
+	TipCap         *big.Int // The tip to pay. Nil uses the node's eth_maxPriorityFeePerGas suggestion.
+}
+
+// SuggestFees implements FeeStrategy.
+func (s *BaseFeeStrategy) SuggestFees(ctx context.Context, client *ethclient.Client, parent *types.Header) (*big.Int, *big.Int, error) {
+	tipCap := s.TipCap
+	if tipCap == nil {
+		suggested, err := client.SuggestGasTipCap(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to suggest gas tip cap: %w", err)
+		}
+		tipCap = suggested
+	}
+
+	feeCap := baseFeeWithHeadroom(parent, s.HeadroomBlocks)
+	return new(big.Int).Set(tipCap), feeCap.Add(feeCap, tipCap), nil
+}
+
+// FixedFeeStrategy always uses the same tip and fee cap.
+type FixedFeeStrategy struct {
+	TipCap *big.Int // The tip to pay.
+	FeeCap *big.Int // The fee cap to pay.
+}
+
+// SuggestFees implements FeeStrategy.
+func (s *FixedFeeStrategy) SuggestFees(context.Context, *ethclient.Client, *types.Header) (*big.Int, *big.Int, error) {
+	return new(big.Int).Set(s.TipCap), new(big.Int).Set(s.FeeCap), nil
+}
+
+// ScaledFeeStrategy scales the fees of another strategy, for example to replace a transaction that is stuck
+// in an endpoint's pool. Blob transactions need their fees doubled to be replaced, which is a Percent of 200.
+type ScaledFeeStrategy struct {
+	Base    FeeStrategy // The strategy whose fees are scaled.
+	Percent int64       // The scale in percent, 100 leaves the fees unchanged.
+}
+
+// SuggestFees implements FeeStrategy.
+func (s *ScaledFeeStrategy) SuggestFees(ctx context.Context, client *ethclient.Client, parent *types.Header) (*big.Int, *big.Int, error) {
+	tipCap, feeCap, err := s.Base.SuggestFees(ctx, client, parent)
+	if err != nil {
+		return nil, nil, err
+	}
+	percent := big.NewInt(s.Percent)
+	tipCap = new(big.Int).Div(new(big.Int).Mul(tipCap, percent), big.NewInt(100))
+	feeCap = new(big.Int).Div(new(big.Int).Mul(feeCap, percent), big.NewInt(100))
+	return tipCap, feeCap, nil
+}
+
+// GasFees asks the strategy for fees and makes sure the fee cap covers the next block's base fee plus the tip.
+//
+// Parameters:
+// - ctx: The context for the client requests.
+// - strategy: The fee strategy to use.
+// - client: The Ethereum client instance.
+// - parent: The header of the block the transaction is built on top of.
+//
+// Returns:
+// - The gas tip cap and gas fee cap, or an error if the strategy fails.
+func GasFees(ctx context.Context, strategy FeeStrategy, client *ethclient.Client, parent *types.Header) (*big.Int, *big.Int, error) {
+	tipCap, feeCap, err := strategy.SuggestFees(ctx, client, parent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	minFeeCap := new(big.Int).Add(NextBaseFee(parent), tipCap)
+	if feeCap.Cmp(minFeeCap) < 0 {
+		feeCap = minFeeCap
+	}
+	return tipCap, feeCap, nil
+}
+
+// NextBaseFee returns the base fee of the block following parent, using the EIP-1559 update rule.
+func NextBaseFee(parent *types.Header) *big.Int {
+	if parent.BaseFee == nil {
+		return new(big.Int).SetUint64(params.InitialBaseFee)
+	}
+
+	gasTarget := parent.GasLimit / params.DefaultElasticityMultiplier
+	if gasTarget == 0 || parent.GasUsed == gasTarget {
+		return new(big.Int).Set(parent.BaseFee)
+	}
+
+	var delta uint64
+	if parent.GasUsed > gasTarget {
+		delta = parent.GasUsed - gasTarget
+	} else {
+		delta = gasTarget - parent.GasUsed
+	}
+	change := new(big.Int).Mul(parent.BaseFee, new(big.Int).SetUint64(delta))
+	change.Div(change, new(big.Int).SetUint64(gasTarget))
+	change.Div(change, big.NewInt(params.DefaultBaseFeeChangeDenominator))
+
+	if parent.GasUsed > gasTarget {
+		if change.Sign() == 0 {
+			change.SetInt64(1)
+		}
+		return change.Add(parent.BaseFee, change)
+	}
+	baseFee := change.Sub(parent.BaseFee, change)
+	if baseFee.Sign() < 0 {
+		baseFee.SetInt64(0)
+	}
+	return baseFee
+}
+
+// baseFeeWithHeadroom returns the next block's base fee raised by the maximum increase of 12.5% for every
+// one of the following blocks, which is the highest base fee the transaction can face within that window.
+func baseFeeWithHeadroom(parent *types.Header, blocks int) *big.Int {
+	baseFee := NextBaseFee(parent)
+	denominator := big.NewInt(int64(params.DefaultBaseFeeChangeDenominator))
+	for i := 0; i < blocks; i++ {
+		increase := new(big.Int).Div(baseFee, denominator)
+		if increase.Sign() == 0 {
+			increase.SetInt64(1)
+		}
+		baseFee.Add(baseFee, increase)
+	}
+	return baseFee
+}
@@ -0,0 +1,145 @@
+package eth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// feeHeader returns a parent header with a 30M gas limit, the given gas used and base fee. A negative base
+// fee leaves it nil, as in a pre-London header.
+func feeHeader(gasUsed uint64, baseFee int64) *types.Header {
+	header := &types.Header{Number: big.NewInt(100), GasLimit: 30_000_000, GasUsed: gasUsed}
+	if baseFee >= 0 {
+		header.BaseFee = big.NewInt(baseFee)
+	}
+	return header
+}
+
+func TestNextBaseFee(t *testing.T) {
+	tests := []struct {
+		name   string
+		parent *types.Header
+		want   int64
+	}{
+		{name: "nil base fee", parent: feeHeader(15_000_000, -1), want: params.InitialBaseFee},
+		{name: "at target", parent: feeHeader(15_000_000, 1000), want: 1000},
+		{name: "full", parent: feeHeader(30_000_000, 1000), want: 1125},
+		{name: "empty", parent: feeHeader(0, 1000), want: 875},
+		{name: "half above target", parent: feeHeader(22_500_000, 1600), want: 1700},
+		{name: "full with tiny base fee rises by one", parent: feeHeader(30_000_000, 1), want: 2},
+		{name: "empty with tiny base fee", parent: feeHeader(0, 1), want: 1},
+		{name: "zero gas target", parent: &types.Header{GasLimit: 1, GasUsed: 1, BaseFee: big.NewInt(1000)}, want: 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NextBaseFee(tt.parent); got.Cmp(big.NewInt(tt.want)) != 0 {
+				t.Fatalf("NextBaseFee() = %s, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBaseFeeWithHeadroom(t *testing.T) {
+	tests := []struct {
+		name   string
+		parent *types.Header
+		blocks int
+		want   int64
+	}{
+		{name: "no headroom", parent: feeHeader(15_000_000, 1000), blocks: 0, want: 1000},
+		{name: "two full blocks", parent: feeHeader(15_000_000, 1000), blocks: 2, want: 1265},
+		{name: "from a full parent", parent: feeHeader(30_000_000, 1000), blocks: 1, want: 1265},
+		{name: "tiny base fee rises by one per block", parent: feeHeader(15_000_000, 7), blocks: 2, want: 9},
+		{name: "nil base fee", parent: feeHeader(0, -1), blocks: 1, want: params.InitialBaseFee * 9 / 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := baseFeeWithHeadroom(tt.parent, tt.blocks); got.Cmp(big.NewInt(tt.want)) != 0 {
+				t.Fatalf("baseFeeWithHeadroom() = %s, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGasFees(t *testing.T) {
+	tests := []struct {
+		name       string
+		strategy   FeeStrategy
+		parent     *types.Header
+		wantTipCap int64
+		wantFeeCap int64
+	}{
+		{
+			name:       "fee cap above the floor",
+			strategy:   &FixedFeeStrategy{TipCap: big.NewInt(10), FeeCap: big.NewInt(5000)},
+			parent:     feeHeader(30_000_000, 1000),
+			wantTipCap: 10,
+			wantFeeCap: 5000,
+		},
+		{
+			name:       "fee cap raised to the floor",
+			strategy:   &FixedFeeStrategy{TipCap: big.NewInt(10), FeeCap: big.NewInt(500)},
+			parent:     feeHeader(30_000_000, 1000),
+			wantTipCap: 10,
+			wantFeeCap: 1135,
+		},
+		{
+			name:       "base fee strategy adds headroom and tip",
+			strategy:   &BaseFeeStrategy{HeadroomBlocks: 2, TipCap: big.NewInt(3)},
+			parent:     feeHeader(15_000_000, 1000),
+			wantTipCap: 3,
+			wantFeeCap: 1268,
+		},
+		{
+			name:       "scaled fees",
+			strategy:   &ScaledFeeStrategy{Base: &FixedFeeStrategy{TipCap: big.NewInt(10), FeeCap: big.NewInt(3000)}, Percent: 200},
+			parent:     feeHeader(15_000_000, 1000),
+			wantTipCap: 20,
+			wantFeeCap: 6000,
+		},
+		{
+			name:       "unscaled fees",
+			strategy:   &ScaledFeeStrategy{Base: &FixedFeeStrategy{TipCap: big.NewInt(10), FeeCap: big.NewInt(3000)}, Percent: 100},
+			parent:     feeHeader(15_000_000, 1000),
+			wantTipCap: 10,
+			wantFeeCap: 3000,
+		},
+		{
+			name:       "scaled fees raised to the floor",
+			strategy:   &ScaledFeeStrategy{Base: &FixedFeeStrategy{TipCap: big.NewInt(10), FeeCap: big.NewInt(400)}, Percent: 150},
+			parent:     feeHeader(15_000_000, 1000),
+			wantTipCap: 15,
+			wantFeeCap: 1015,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tipCap, feeCap, err := GasFees(context.Background(), tt.strategy, nil, tt.parent)
+			if err != nil {
+				t.Fatalf("GasFees: %v", err)
+			}
+			if tipCap.Cmp(big.NewInt(tt.wantTipCap)) != 0 || feeCap.Cmp(big.NewInt(tt.wantFeeCap)) != 0 {
+				t.Fatalf("GasFees() = %s, %s, want %d, %d", tipCap, feeCap, tt.wantTipCap, tt.wantFeeCap)
+			}
+		})
+	}
+}
+
+func TestScaledFeeStrategyLeavesBaseUnchanged(t *testing.T) {
+	base := &FixedFeeStrategy{TipCap: big.NewInt(10), FeeCap: big.NewInt(100)}
+	strategy := &ScaledFeeStrategy{Base: base, Percent: 800}
+	tipCap, feeCap, err := strategy.SuggestFees(context.Background(), nil, feeHeader(15_000_000, 1))
+	if err != nil {
+		t.Fatalf("SuggestFees: %v", err)
+	}
+	if tipCap.Int64() != 80 || feeCap.Int64() != 800 {
+		t.Fatalf("SuggestFees() = %s, %s, want 80, 800", tipCap, feeCap)
+	}
+	if base.TipCap.Int64() != 10 || base.FeeCap.Int64() != 100 {
+		t.Fatalf("base fees modified to %s, %s", base.TipCap, base.FeeCap)
+	}
+}
@@ -0,0 +1,227 @@
+package eth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/primev/preconf_blob_bidder/core/logging"
+)
+
+// seenHeaders is the number of recent header hashes a HeadSource remembers to drop duplicates.
+const seenHeaders = 256
+
+// Reorg describes a new head that does not extend the previous head. Ancestor and Dropped are only
+// known to a ChainWindow, a HeadSource leaves them empty.
+type Reorg struct {
+	OldHead  *types.Header // The previous head.
+	NewHead  *types.Header // The head that replaced it.
+	Ancestor uint64        // The number of the last block both chains share.
+	Dropped  []common.Hash // The hashes of the blocks of the old chain that are no longer canonical.
+}
+
+// HeadEvent is a new chain head delivered by a HeadSource.
+type HeadEvent struct {
+	Header *types.Header // The new head.
+	Reorg  *Reorg        // Set when the new head does not extend the previous head.
+}
+
+// HeadSourceConfig holds the endpoints and timings of a HeadSource.
+type HeadSourceConfig struct {
+	WSEndpoints       []string      // WebSocket endpoints that are all subscribed to at once.
+	Poll              *RPCPool      // Pool whose healthiest endpoint is polled while no subscription delivers heads. Nil disables polling.
+	PollInterval      time.Duration // Time between two polls.
+	StaleAfter        time.Duration // Time without a new head after which polling starts.
+	ReconnectInterval time.Duration // Time to wait before resubscribing to a failed WebSocket endpoint.
+}
+
+// HeadSource delivers new chain heads from several WebSocket subscriptions at once, dropping the duplicates
+// by hash, and falls back to polling HTTP endpoints while no subscription delivers. Failing endpoints are
+// resubscribed in the background, so that downtime of one node does not stop the loop.
+type HeadSource struct {
+	cfg    HeadSourceConfig
+	in     chan *types.Header
+	events chan HeadEvent
+
+	mu       sync.Mutex
+	head     *types.Header
+	headAt   time.Time
+	seen     map[common.Hash]bool
+	seenList []common.Hash
+}
+
+// NewHeadSource creates a head source. Call Start to begin delivering heads.
+//
+// Parameters:
+// - cfg: The endpoints and timings of the head source.
+//
+// Returns:
+// - A pointer to a HeadSource.
+func NewHeadSource(cfg HeadSourceConfig) *HeadSource {
+	return &HeadSource{
+		cfg:    cfg,
+		in:     make(chan *types.Header, 16),
+		events: make(chan HeadEvent, 16),
+		seen:   make(map[common.Hash]bool),
+	}
+}
+
+// Heads returns the channel new heads are delivered on.
+func (s *HeadSource) Heads() <-chan HeadEvent {
+	return s.events
+}
+
+// Latest returns the latest delivered head and the time it was received, or nil if none was delivered yet.
+func (s *HeadSource) Latest() (*types.Header, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.head, s.headAt
+}
+
+// Start subscribes to every WebSocket endpoint and starts the polling fallback until ctx is cancelled.
+func (s *HeadSource) Start(ctx context.Context) {
+	for _, endpoint := range s.cfg.WSEndpoints {
+		go s.subscribe(ctx, endpoint)
+	}
+	if s.cfg.Poll != nil {
+		go s.poll(ctx)
+	}
+	go s.dispatch(ctx)
+}
+
+// subscribe keeps a new head subscription to a WebSocket endpoint alive, resubscribing after failures.
+func (s *HeadSource) subscribe(ctx context.Context, endpoint string) {
+	logger := logging.FromContext(ctx, nil).With(logging.KeyEndpoint, endpoint)
+	for {
+		err := s.subscribeOnce(ctx, endpoint)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Warn("head subscription failed, resubscribing", "in", s.cfg.ReconnectInterval, logging.KeyErr, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.cfg.ReconnectInterval):
+		}
+	}
+}
+
+// subscribeOnce forwards the heads of one subscription until it fails.
+func (s *HeadSource) subscribeOnce(ctx context.Context, endpoint string) error {
+	client, err := ethclient.DialContext(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	headers := make(chan *types.Header)
+	sub, err := client.SubscribeNewHead(ctx, headers)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+	logging.FromContext(ctx, nil).Info("(ws) subscribed to new heads", logging.KeyEndpoint, endpoint)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-sub.Err():
+			return err
+		case header := <-headers:
+			select {
+			case s.in <- header:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// poll asks the healthiest endpoint of the pool for the latest head while the subscriptions are stale.
+func (s *HeadSource) poll(ctx context.Context) {
+	logger := logging.FromContext(ctx, nil)
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, headAt := s.Latest(); time.Since(headAt) < s.cfg.StaleAfter {
+			continue
+		}
+		client, err := s.cfg.Poll.Best()
+		if err != nil {
+			logger.Warn("cannot poll for new heads", logging.KeyErr, err)
+			continue
+		}
+		pollCtx, cancel := context.WithTimeout(ctx, s.cfg.PollInterval)
+		header, err := client.HeaderByNumber(pollCtx, nil)
+		cancel()
+		if err != nil {
+			logger.Warn("failed to poll for new head", logging.KeyErr, err)
+			continue
+		}
+		select {
+		case s.in <- header:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// dispatch drops duplicate heads, detects reorgs and delivers the remaining heads in arrival order.
+func (s *HeadSource) dispatch(ctx context.Context) {
+	for {
+		var header *types.Header
+		select {
+		case <-ctx.Done():
+			return
+		case header = <-s.in:
+		}
+
+		event, ok := s.accept(header)
+		if !ok {
+			continue
+		}
+		select {
+		case s.events <- event:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// accept records a head and reports whether it is new. A head is a reorg when it does not build on the
+// previous head although it is not ahead of it, or when it builds on a known block other than the previous head.
+func (s *HeadSource) accept(header *types.Header) (HeadEvent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := header.Hash()
+	if s.seen[hash] {
+		return HeadEvent{}, false
+	}
+	s.seen[hash] = true
+	s.seenList = append(s.seenList, hash)
+	if len(s.seenList) > seenHeaders {
+		delete(s.seen, s.seenList[0])
+		s.seenList = s.seenList[1:]
+	}
+
+	event := HeadEvent{Header: header}
+	if prev := s.head; prev != nil && header.ParentHash != prev.Hash() {
+		if header.Number.Cmp(prev.Number) <= 0 || s.seen[header.ParentHash] {
+			event.Reorg = &Reorg{OldHead: prev, NewHead: header}
+		}
+	}
+	s.head, s.headAt = header, time.Now()
+	return event, true
+}
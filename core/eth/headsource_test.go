@@ -0,0 +1,124 @@
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Outcomes of HeadSource.accept for a delivered head.
+const (
+	headNew       = "new"
+	headDuplicate = "duplicate"
+	headReorg     = "reorg"
+)
+
+// chainHeader returns a header of block number on top of parent. Fork tells apart siblings of the same parent.
+func chainHeader(number uint64, parent *types.Header, fork byte) *types.Header {
+	header := &types.Header{Number: new(big.Int).SetUint64(number), Extra: []byte{fork}}
+	if parent != nil {
+		header.ParentHash = parent.Hash()
+	}
+	return header
+}
+
+// fillerHeaders returns n unrelated headers, each on an unknown parent and ahead of the previous one.
+func fillerHeaders(n int) []*types.Header {
+	headers := make([]*types.Header, n)
+	for i := range headers {
+		headers[i] = &types.Header{Number: big.NewInt(int64(1000 + i)), ParentHash: common.BigToHash(big.NewInt(int64(i + 1)))}
+	}
+	return headers
+}
+
+func TestHeadSourceAccept(t *testing.T) {
+	var (
+		h99   = chainHeader(99, nil, 0)
+		h100  = chainHeader(100, h99, 0)
+		h101  = chainHeader(101, h100, 0)
+		h101b = chainHeader(101, h100, 1)
+		h102  = chainHeader(102, h101, 0)
+		h105  = chainHeader(105, &types.Header{Number: big.NewInt(104)}, 0)
+	)
+
+	// repeat returns the outcome want n times.
+	repeat := func(want string, n int) []string {
+		outcomes := make([]string, n)
+		for i := range outcomes {
+			outcomes[i] = want
+		}
+		return outcomes
+	}
+
+	tests := []struct {
+		name    string
+		headers []*types.Header
+		want    []string
+	}{
+		{
+			name:    "duplicate delivery from two endpoints",
+			headers: []*types.Header{h100, h101, h101},
+			want:    []string{headNew, headNew, headDuplicate},
+		},
+		{
+			name:    "sibling at the same height",
+			headers: []*types.Header{h100, h101, h101b},
+			want:    []string{headNew, headNew, headReorg},
+		},
+		{
+			name:    "lower head whose parent is known",
+			headers: []*types.Header{h99, h100, h101, h102, h101b},
+			want:    []string{headNew, headNew, headNew, headNew, headReorg},
+		},
+		{
+			name:    "head on a known block other than the previous head",
+			headers: []*types.Header{h100, h101, h101b, h102},
+			want:    []string{headNew, headNew, headReorg, headReorg},
+		},
+		{
+			name:    "missed heads with an unknown parent",
+			headers: []*types.Header{h100, h105},
+			want:    []string{headNew, headNew},
+		},
+		{
+			name:    "duplicate within the remembered headers",
+			headers: append(append([]*types.Header{h100}, fillerHeaders(seenHeaders-1)...), h100),
+			want:    append(repeat(headNew, seenHeaders), headDuplicate),
+		},
+		{
+			name:    "evicted header is accepted again",
+			headers: append(append([]*types.Header{h100}, fillerHeaders(seenHeaders)...), h100),
+			want:    append(repeat(headNew, seenHeaders+1), headReorg),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := NewHeadSource(HeadSourceConfig{})
+			var prev *types.Header
+			for i, header := range tt.headers {
+				event, ok := source.accept(header)
+				got := headNew
+				switch {
+				case !ok:
+					got = headDuplicate
+				case event.Reorg != nil:
+					got = headReorg
+					if event.Reorg.OldHead != prev || event.Reorg.NewHead != header {
+						t.Errorf("head %d: reorg from %v to %v, want from the previous head", i, event.Reorg.OldHead.Number, event.Reorg.NewHead.Number)
+					}
+				}
+				if got != tt.want[i] {
+					t.Errorf("head %d (block %d): %s, want %s", i, header.Number, got, tt.want[i])
+				}
+				if ok {
+					prev = header
+				}
+			}
+			if head, _ := source.Latest(); head != prev {
+				t.Errorf("latest head %v, want the last accepted head %v", head.Number, prev.Number)
+			}
+		})
+	}
+}
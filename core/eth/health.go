@@ -0,0 +1,190 @@
+package eth
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/primev/preconf_blob_bidder/core/logging"
+	bb "github.com/primev/preconf_blob_bidder/core/mevcommit"
+	"google.golang.org/grpc/connectivity"
+)
+
+// HealthConfig holds the sources and thresholds of a Health.
+type HealthConfig struct {
+	Heads      *HeadSource   // Source of new heads whose freshness is checked.
+	Pool       *RPCPool      // RPC pool that must have a healthy endpoint.
+	Bidder     *bb.Bidder    // Bidder node whose gRPC connection is checked.
+	MaxHeadAge time.Duration // Time without a new head after which the bidder is unhealthy.
+	MinDeposit *big.Int      // Deposit below which the bidder is not ready. Nil only reports the deposit.
+	MaxBidAge  time.Duration // Time without a successful bid after which the bidder is not ready. Zero only reports the last bid.
+}
+
+// HealthCheck is the outcome of one check of a HealthReport.
+type HealthCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail"`
+}
+
+// HealthReport is the JSON body served by the health endpoints.
+type HealthReport struct {
+	OK     bool          `json:"ok"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+// Health answers the liveness and readiness probes of an orchestrator. Liveness only fails on conditions
+// a restart can fix, a head subscription that stopped delivering or a broken gRPC connection. Readiness
+// also requires a healthy RPC endpoint, a sufficient deposit and, when configured, a recent successful bid.
+type Health struct {
+	cfg     HealthConfig
+	started time.Time
+
+	mu        sync.Mutex
+	deposit   *big.Int
+	depositAt time.Time
+	lastBid   time.Time
+}
+
+// NewHealth creates the health checks of the bidder loop.
+//
+// Parameters:
+// - cfg: The sources and thresholds of the checks.
+//
+// Returns:
+// - A pointer to a Health.
+func NewHealth(cfg HealthConfig) *Health {
+	return &Health{cfg: cfg, started: time.Now()}
+}
+
+// RecordDeposit records the deposit last reported by the bidder node.
+func (h *Health) RecordDeposit(amount *big.Int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.deposit, h.depositAt = amount, time.Now()
+}
+
+// RecordBid records the time of a bid that received at least one commitment.
+func (h *Health) RecordBid(at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastBid = at
+}
+
+// Liveness runs the checks whose failure calls for a restart.
+func (h *Health) Liveness() HealthReport {
+	return newHealthReport(h.checkHead(), h.checkBidder())
+}
+
+// Readiness runs all checks.
+func (h *Health) Readiness() HealthReport {
+	return newHealthReport(h.checkHead(), h.checkBidder(), h.checkPool(), h.checkDeposit(), h.checkBid())
+}
+
+// Handler returns the HTTP handler that serves Liveness on /healthz and Readiness on /readyz. Failing
+// reports are served with status 503.
+func (h *Health) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) { serveHealthReport(w, r, h.Liveness()) })
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) { serveHealthReport(w, r, h.Readiness()) })
+	return mux
+}
+
+// checkHead fails when no head was received for MaxHeadAge, counting from the start before the first head.
+func (h *Health) checkHead() HealthCheck {
+	check := HealthCheck{Name: "head"}
+	header, receivedAt := h.cfg.Heads.Latest()
+	if header == nil {
+		check.OK = time.Since(h.started) < h.cfg.MaxHeadAge
+		check.Detail = fmt.Sprintf("no head received since start %s ago", time.Since(h.started).Truncate(time.Second))
+		return check
+	}
+	age := time.Since(receivedAt)
+	check.OK = age < h.cfg.MaxHeadAge
+	check.Detail = fmt.Sprintf("block %d received %s ago", header.Number, age.Truncate(time.Millisecond))
+	return check
+}
+
+// checkBidder fails when the gRPC connection to the bidder node is broken.
+func (h *Health) checkBidder() HealthCheck {
+	state := h.cfg.Bidder.ConnState()
+	return HealthCheck{
+		Name:   "bidder",
+		OK:     state != connectivity.TransientFailure && state != connectivity.Shutdown,
+		Detail: "grpc connection " + state.String(),
+	}
+}
+
+// checkPool fails when no RPC endpoint passed its last health check.
+func (h *Health) checkPool() HealthCheck {
+	states := h.cfg.Pool.State()
+	healthy := 0
+	for _, state := range states {
+		if state.Healthy && !state.Evicted {
+			healthy++
+		}
+	}
+	return HealthCheck{Name: "rpc", OK: healthy > 0, Detail: fmt.Sprintf("%d of %d endpoints healthy", healthy, len(states))}
+}
+
+// checkDeposit fails when the deposit is unknown or below MinDeposit.
+func (h *Health) checkDeposit() HealthCheck {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	check := HealthCheck{Name: "deposit"}
+	if h.deposit == nil {
+		check.OK = h.cfg.MinDeposit == nil
+		check.Detail = "deposit not reported yet"
+		return check
+	}
+	check.OK = h.cfg.MinDeposit == nil || h.deposit.Cmp(h.cfg.MinDeposit) >= 0
+	check.Detail = fmt.Sprintf("%s wei reported %s ago", h.deposit, time.Since(h.depositAt).Truncate(time.Second))
+	if h.cfg.MinDeposit != nil {
+		check.Detail += fmt.Sprintf(", minimum %s wei", h.cfg.MinDeposit)
+	}
+	return check
+}
+
+// checkBid fails when no bid succeeded for MaxBidAge, counting from the start before the first bid.
+func (h *Health) checkBid() HealthCheck {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	check := HealthCheck{Name: "bid", OK: true}
+	since := h.lastBid
+	if since.IsZero() {
+		since = h.started
+		check.Detail = "no successful bid yet"
+	} else {
+		check.Detail = fmt.Sprintf("last successful bid %s ago", time.Since(h.lastBid).Truncate(time.Second))
+	}
+	if h.cfg.MaxBidAge > 0 {
+		check.OK = time.Since(since) < h.cfg.MaxBidAge
+	}
+	return check
+}
+
+// newHealthReport combines checks into a report that is OK when all of them are.
+func newHealthReport(checks ...HealthCheck) HealthReport {
+	report := HealthReport{OK: true, Checks: checks}
+	for _, check := range checks {
+		report.OK = report.OK && check.OK
+	}
+	return report
+}
+
+// serveHealthReport writes a report as JSON, with status 503 when it failed. Write errors are logged with the
+// logger of the request context.
+func serveHealthReport(w http.ResponseWriter, r *http.Request, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	if !report.OK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logging.FromContext(r.Context(), nil).Debug("failed to write health report", logging.KeyErr, err)
+	}
+}
@@ -0,0 +1,172 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/primev/preconf_blob_bidder/core/datafile"
+)
+
+// States of a journaled transaction after reconciling it with the chain.
+const (
+	TxStillPending = "pending"  // The transaction is not included and its nonce is unused.
+	TxIncluded     = "included" // The transaction was included.
+	TxReplaced     = "replaced" // Another transaction with the same nonce was included.
+)
+
+// Bid records a preconfirmation bid sent for a pending transaction.
+type Bid struct {
+	Block       uint64          `json:"block"`               // The block the bid targeted.
+	Amount      *big.Int        `json:"amount"`              // The bid amount in wei.
+	SentAt      time.Time       `json:"sentAt"`              // When the bid was sent.
+	Commitments int             `json:"commitments"`         // The number of commitments received for the bid.
+	Committed   []BidCommitment `json:"committed,omitempty"` // The commitments received for the bid.
+	Err         string          `json:"err,omitempty"`       // Why the bid failed, empty if it was sent.
+}
+
+// BidCommitment records a commitment a provider made for a bid. Its digest identifies the settlement of the
+// bid in the BidderRegistry contract.
+type BidCommitment struct {
+	Digest   common.Hash    `json:"digest"`
+	Provider common.Address `json:"provider"`
+}
+
+// Journal persists the pending transactions of an AccountPool to a file, so that a restarted bidder resumes
+// bidding for and resubmitting them instead of building new transactions over them.
+type Journal struct {
+	path string
+}
+
+// journalEntry is the file format of a pending transaction. The signed transaction is stored in its binary
+// network encoding, which includes the blob sidecar.
+type journalEntry struct {
+	From        common.Address `json:"from"`
+	Hash        common.Hash    `json:"hash"`
+	Nonce       uint64         `json:"nonce"`
+	TargetBlock uint64         `json:"targetBlock"`
+	SentAt      time.Time      `json:"sentAt"`
+	Preconfs    int            `json:"preconfs"`
+	Bids        []Bid          `json:"bids"`
+	RawTx       hexutil.Bytes  `json:"rawTx,omitempty"`
+}
+
+// ReconciledTx is a journaled transaction together with its state on chain.
+type ReconciledTx struct {
+	PendingTx
+	State   string         // TxStillPending, TxIncluded or TxReplaced.
+	Receipt *types.Receipt // The receipt of an included transaction.
+}
+
+// NewJournal creates a journal that is stored at path.
+func NewJournal(path string) *Journal {
+	return &Journal{path: path}
+}
+
+// Save replaces the journal with the given pending transactions.
+//
+// Parameters:
+// - pending: All pending transactions, as returned by AccountPool.Pending.
+//
+// Returns:
+// - An error if a transaction cannot be encoded or the file cannot be written.
+func (j *Journal) Save(pending []PendingTx) error {
+	entries := make([]journalEntry, 0, len(pending))
+	for _, tx := range pending {
+		entry := journalEntry{
+			From:        tx.From,
+			Hash:        tx.Hash,
+			Nonce:       tx.Nonce,
+			TargetBlock: tx.TargetBlock,
+			SentAt:      tx.SentAt,
+			Preconfs:    tx.Preconfs,
+			Bids:        tx.Bids,
+		}
+		if tx.Tx != nil {
+			raw, err := tx.Tx.MarshalBinary()
+			if err != nil {
+				return fmt.Errorf("failed to encode transaction %s: %w", tx.Hash, err)
+			}
+			entry.RawTx = raw
+		}
+		entries = append(entries, entry)
+	}
+	return datafile.WriteJSON(j.path, entries)
+}
+
+// Load reads the pending transactions of the journal. A missing journal holds no transactions.
+//
+// Returns:
+// - The pending transactions, or an error if the journal cannot be read or a transaction cannot be decoded.
+func (j *Journal) Load() ([]PendingTx, error) {
+	var entries []journalEntry
+	if _, err := datafile.ReadJSON(j.path, &entries); err != nil {
+		return nil, err
+	}
+
+	pending := make([]PendingTx, 0, len(entries))
+	for _, entry := range entries {
+		tx := PendingTx{
+			From:        entry.From,
+			Hash:        entry.Hash,
+			Nonce:       entry.Nonce,
+			TargetBlock: entry.TargetBlock,
+			SentAt:      entry.SentAt,
+			Preconfs:    entry.Preconfs,
+			Bids:        entry.Bids,
+		}
+		if len(entry.RawTx) > 0 {
+			tx.Tx = new(types.Transaction)
+			if err := tx.Tx.UnmarshalBinary(entry.RawTx); err != nil {
+				return nil, fmt.Errorf("failed to decode transaction %s: %w", entry.Hash, err)
+			}
+			if tx.Tx.Hash() != entry.Hash {
+				return nil, fmt.Errorf("journaled transaction %s has hash %s", entry.Hash, tx.Tx.Hash())
+			}
+		}
+		pending = append(pending, tx)
+	}
+	return pending, nil
+}
+
+// Reconcile checks journaled transactions against the chain: transactions that were included or whose
+// nonce was consumed while the bidder was stopped are settled, the others are still pending.
+//
+// Parameters:
+// - ctx: The context for the client requests.
+// - client: The Ethereum client used to query receipts and nonces.
+// - pending: The journaled transactions.
+//
+// Returns:
+// - The transactions with their state, or an error if the state of a transaction cannot be determined.
+func Reconcile(ctx context.Context, client *ethclient.Client, pending []PendingTx) ([]ReconciledTx, error) {
+	reconciled := make([]ReconciledTx, 0, len(pending))
+	for _, tx := range pending {
+		receipt, err := client.TransactionReceipt(ctx, tx.Hash)
+		if err == nil {
+			reconciled = append(reconciled, ReconciledTx{PendingTx: tx, State: TxIncluded, Receipt: receipt})
+			continue
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return nil, fmt.Errorf("failed to get receipt of %s: %w", tx.Hash, err)
+		}
+
+		nonce, err := client.NonceAt(ctx, tx.From, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get nonce of %s: %w", tx.From, err)
+		}
+		state := TxStillPending
+		if nonce > tx.Nonce {
+			state = TxReplaced
+		}
+		reconciled = append(reconciled, ReconciledTx{PendingTx: tx, State: state})
+	}
+	return reconciled, nil
+}
@@ -0,0 +1,200 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/holiman/uint256"
+)
+
+// signedBlobTx returns a signed blob transaction with a one blob sidecar.
+func signedBlobTx(t *testing.T, nonce uint64) *types.Transaction {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sidecar := makeSidecar(randBlobs(1))
+	tx, err := types.SignNewTx(key, types.NewCancunSigner(big.NewInt(1)), &types.BlobTx{
+		ChainID:    uint256.NewInt(1),
+		Nonce:      nonce,
+		GasTipCap:  uint256.NewInt(1),
+		GasFeeCap:  uint256.NewInt(100),
+		Gas:        21000,
+		BlobFeeCap: uint256.NewInt(10),
+		BlobHashes: sidecar.BlobHashes(),
+		Sidecar:    sidecar,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestJournalRoundTrip(t *testing.T) {
+	tx := signedBlobTx(t, 7)
+	pending := []PendingTx{
+		{
+			From:        common.HexToAddress("0x01"),
+			Hash:        tx.Hash(),
+			Nonce:       7,
+			TargetBlock: 100,
+			SentAt:      time.Unix(1700000000, 0).UTC(),
+			Preconfs:    2,
+			Bids: []Bid{{
+				Block:       101,
+				Amount:      big.NewInt(1e9),
+				SentAt:      time.Unix(1700000012, 0).UTC(),
+				Commitments: 1,
+				Committed:   []BidCommitment{{Digest: common.HexToHash("0xd1"), Provider: common.HexToAddress("0x02")}},
+			}},
+			Tx: tx,
+		},
+		{From: common.HexToAddress("0x03"), Hash: common.HexToHash("0xaa"), Nonce: 1, TargetBlock: 90},
+	}
+
+	journal := NewJournal(filepath.Join(t.TempDir(), "pending.json"))
+	if err := journal.Save(pending); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := journal.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != len(pending) {
+		t.Fatalf("loaded %d transactions, want %d", len(loaded), len(pending))
+	}
+
+	got := loaded[0]
+	if got.Tx == nil || got.Tx.Hash() != tx.Hash() {
+		t.Fatalf("loaded transaction %v, want %s", got.Tx, tx.Hash())
+	}
+	sidecar := got.Tx.BlobTxSidecar()
+	if sidecar == nil {
+		t.Fatal("the blob sidecar was not journaled")
+	}
+	if !reflect.DeepEqual(sidecar, tx.BlobTxSidecar()) {
+		t.Error("the loaded blob sidecar differs from the saved one")
+	}
+	got.Tx, pending[0].Tx = nil, nil
+	if !reflect.DeepEqual(got, pending[0]) {
+		t.Errorf("loaded %+v, want %+v", got, pending[0])
+	}
+	if !reflect.DeepEqual(loaded[1], pending[1]) {
+		t.Errorf("loaded %+v, want %+v", loaded[1], pending[1])
+	}
+}
+
+func TestJournalLoadMissing(t *testing.T) {
+	loaded, err := NewJournal(filepath.Join(t.TempDir(), "pending.json")).Load()
+	if err != nil || len(loaded) != 0 {
+		t.Errorf("Load of a missing journal = %v, %v, want no transactions", loaded, err)
+	}
+}
+
+func TestJournalLoadHashMismatch(t *testing.T) {
+	raw, err := signedBlobTx(t, 0).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []journalEntry{{From: common.HexToAddress("0x01"), Hash: common.HexToHash("0xbad"), RawTx: raw}}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "pending.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewJournal(path).Load()
+	if err == nil || !strings.Contains(err.Error(), "has hash") {
+		t.Errorf("Load = %v, want a hash mismatch error", err)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	included := common.HexToHash("0x01")
+	account := common.HexToAddress("0xa1")
+	server := newRPCStub(t, func(method string, params []json.RawMessage) (interface{}, error) {
+		switch method {
+		case "eth_getTransactionReceipt":
+			var hash common.Hash
+			if err := json.Unmarshal(params[0], &hash); err != nil {
+				return nil, err
+			}
+			if hash != included {
+				return nil, nil
+			}
+			return &types.Receipt{
+				Status:            types.ReceiptStatusSuccessful,
+				CumulativeGasUsed: 21000,
+				Logs:              []*types.Log{},
+				TxHash:            included,
+				BlockHash:         common.HexToHash("0xb1"),
+				BlockNumber:       big.NewInt(120),
+			}, nil
+		case "eth_getTransactionCount":
+			return hexutil.Uint64(5), nil
+		}
+		return nil, &RPCError{Code: -32601, Message: "the method " + method + " does not exist"}
+	})
+	client, err := ethclient.Dial(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	pending := []PendingTx{
+		{From: account, Hash: included, Nonce: 3},
+		{From: account, Hash: common.HexToHash("0x02"), Nonce: 4},
+		{From: account, Hash: common.HexToHash("0x03"), Nonce: 5},
+	}
+	reconciled, err := Reconcile(context.Background(), client, pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{TxIncluded, TxReplaced, TxStillPending}
+	if len(reconciled) != len(want) {
+		t.Fatalf("reconciled %d transactions, want %d", len(reconciled), len(want))
+	}
+	for i, tx := range reconciled {
+		if tx.Hash != pending[i].Hash || tx.State != want[i] {
+			t.Errorf("transaction %s is %s, want %s", tx.Hash, tx.State, want[i])
+		}
+		if (tx.Receipt != nil) != (want[i] == TxIncluded) {
+			t.Errorf("transaction %s has receipt %v", tx.Hash, tx.Receipt)
+		}
+	}
+	if block := reconciled[0].Receipt.BlockNumber.Uint64(); block != 120 {
+		t.Errorf("included in block %d, want 120", block)
+	}
+}
+
+func TestReconcileError(t *testing.T) {
+	server := newRPCStub(t, func(method string, params []json.RawMessage) (interface{}, error) {
+		return nil, &RPCError{Code: -32000, Message: "internal error"}
+	})
+	client, err := ethclient.Dial(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if _, err := Reconcile(context.Background(), client, []PendingTx{{Hash: common.HexToHash("0x01")}}); err == nil {
+		t.Error("Reconcile succeeded although the receipt could not be fetched")
+	}
+}
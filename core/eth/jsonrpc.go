@@ -0,0 +1,190 @@
+package eth
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/primev/preconf_blob_bidder/core/tracing"
+)
+
+// flashbotsSignatureHeader authenticates a request with the searcher reputation key.
+const flashbotsSignatureHeader = "X-Flashbots-Signature"
+
+// httpClient is shared by all JSON-RPC calls to relays and builders.
+var httpClient = &http.Client{
+	Timeout: 12 * time.Second,
+	Transport: &http.Transport{
+		DisableKeepAlives:   false,
+		MaxIdleConnsPerHost: 1,
+		IdleConnTimeout:     12 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+// Errors returned by relays, builders and nodes, classified from JSON-RPC error responses.
+// RPCError values wrap one of them when the error is recognized, so callers can use errors.Is.
+var (
+	ErrNonceTooLow             = errors.New("nonce too low")
+	ErrReplacementUnderpriced  = errors.New("replacement transaction underpriced")
+	ErrBlobFeeTooLow           = errors.New("blob fee too low")
+	ErrRateLimited             = errors.New("rate limited")
+	ErrUnknownMethod           = errors.New("method not supported")
+	ErrUnexpectedHTTPStatus    = errors.New("unexpected http status")
+	ErrMalformedJSONRPCPayload = errors.New("malformed json-rpc response")
+)
+
+// RPCError is a JSON-RPC error object returned by an endpoint.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+
+	kind error // The classified error, nil if the error is not recognized.
+}
+
+// Error implements the error interface.
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("json-rpc error %d: %s", e.Code, e.Message)
+}
+
+// Unwrap returns the classified error so that errors.Is matches ErrNonceTooLow and friends.
+func (e *RPCError) Unwrap() error {
+	return e.kind
+}
+
+// jsonRPCResponse is a JSON-RPC 2.0 response.
+type jsonRPCResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+}
+
+// classifyRPCError maps the error codes and messages used by geth, Flashbots, Titan and other builders
+// to the error values above.
+func classifyRPCError(code int, message string) error {
+	msg := strings.ToLower(message)
+	switch {
+	case code == -32601 || strings.Contains(msg, "method not found") ||
+		strings.Contains(msg, "method") && (strings.Contains(msg, "does not exist") || strings.Contains(msg, "not supported") || strings.Contains(msg, "not available")):
+		return ErrUnknownMethod
+	case code == -32005 || strings.Contains(msg, "rate limit") || strings.Contains(msg, "too many requests"):
+		return ErrRateLimited
+	case strings.Contains(msg, "nonce too low"):
+		return ErrNonceTooLow
+	case strings.Contains(msg, "replacement transaction underpriced") || strings.Contains(msg, "replacement underpriced"):
+		return ErrReplacementUnderpriced
+	case strings.Contains(msg, "less than block blob gas fee") ||
+		strings.Contains(msg, "blob") && (strings.Contains(msg, "fee cap") || strings.Contains(msg, "underpriced") || strings.Contains(msg, "too low")):
+		return ErrBlobFeeTooLow
+	default:
+		return nil
+	}
+}
+
+// decodeJSONRPCResponse decodes the body of a JSON-RPC response.
+//
+// Parameters:
+// - status: The HTTP status code of the response.
+// - body: The raw response body.
+//
+// Returns:
+// - The raw result, or an error. JSON-RPC errors are returned as *RPCError.
+func decodeJSONRPCResponse(status int, body []byte) (json.RawMessage, error) {
+	if status == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("%w: http status %d", ErrRateLimited, status)
+	}
+
+	var resp jsonRPCResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		// Some relays answer non-2xx statuses with a plain text body.
+		if status/100 != 2 {
+			return nil, fmt.Errorf("%w %d: %s", ErrUnexpectedHTTPStatus, status, strings.TrimSpace(string(body)))
+		}
+		return nil, fmt.Errorf("%w: %v", ErrMalformedJSONRPCPayload, err)
+	}
+	if resp.Error != nil {
+		resp.Error.kind = classifyRPCError(resp.Error.Code, resp.Error.Message)
+		return nil, resp.Error
+	}
+	if status/100 != 2 {
+		return nil, fmt.Errorf("%w %d: %s", ErrUnexpectedHTTPStatus, status, strings.TrimSpace(string(body)))
+	}
+
+	return resp.Result, nil
+}
+
+// jsonRPCRequest is a JSON-RPC 2.0 request.
+type jsonRPCRequest struct {
+	Jsonrpc string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+	ID      int         `json:"id"`
+}
+
+// callJSONRPC posts a JSON-RPC request to url and decodes the response.
+//
+// Parameters:
+// - ctx: The context for the HTTP request.
+// - url: The endpoint to post to.
+// - method: The JSON-RPC method name.
+// - params: The JSON-RPC params, marshalled as is.
+// - signingKey: The searcher reputation key that signs the request body. Nil sends the request unsigned.
+//
+// Returns:
+// - The raw JSON-RPC result, or an error if the request fails or the endpoint returns an error.
+func callJSONRPC(ctx context.Context, url, method string, params interface{}, signingKey *ecdsa.PrivateKey) (_ json.RawMessage, err error) {
+	ctx, span := tracing.Start(ctx, method, tracing.Endpoint(url))
+	defer func() { tracing.End(span, err) }()
+
+	payloadBytes, err := json.Marshal(jsonRPCRequest{Jsonrpc: "2.0", Method: method, Params: params, ID: 1})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(payloadBytes))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	if signingKey != nil {
+		signature, err := flashbotsSignature(payloadBytes, signingKey)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Add(flashbotsSignatureHeader, signature)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	return decodeJSONRPCResponse(resp.StatusCode, body)
+}
+
+// flashbotsSignature returns the X-Flashbots-Signature header value for a request body, which is the signer
+// address and the EIP-191 signature of the hex encoded keccak256 hash of the body.
+func flashbotsSignature(body []byte, key *ecdsa.PrivateKey) (string, error) {
+	hashedBody := crypto.Keccak256Hash(body).Hex()
+	signature, err := crypto.Sign(accounts.TextHash([]byte(hashedBody)), key)
+	if err != nil {
+		return "", fmt.Errorf("error signing request body: %w", err)
+	}
+	return crypto.PubkeyToAddress(key.PublicKey).Hex() + ":" + hexutil.Encode(signature), nil
+}
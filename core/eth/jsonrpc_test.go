@@ -0,0 +1,138 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestClassifyRPCError(t *testing.T) {
+	tests := []struct {
+		code    int
+		message string
+		want    error
+	}{
+		{code: -32601, message: "", want: ErrUnknownMethod},
+		{code: -32000, message: "the method eth_sendBundle does not exist/is not available", want: ErrUnknownMethod},
+		{code: -32000, message: "Method not found", want: ErrUnknownMethod},
+		{code: -32000, message: "method flashbots_getBundleStatsV2 not supported", want: ErrUnknownMethod},
+		{code: -32005, message: "", want: ErrRateLimited},
+		{code: -32000, message: "Too Many Requests", want: ErrRateLimited},
+		{code: -32000, message: "nonce too low: next nonce 5, tx nonce 4", want: ErrNonceTooLow},
+		{code: -32000, message: "replacement transaction underpriced", want: ErrReplacementUnderpriced},
+		{code: -32000, message: "max fee per blob gas less than block blob gas fee", want: ErrBlobFeeTooLow},
+		{code: -32000, message: "max fee per blob gas less than block blob gas fee: address 0x71562b71999873DB5b286dF957af199Ec94617F7 blobGasFeeCap: 1, blobBaseFee: 2", want: ErrBlobFeeTooLow},
+		{code: -32000, message: "transaction underpriced: blob fee cap 1, minimum needed 2", want: ErrBlobFeeTooLow},
+		{code: -32000, message: "blob transaction underpriced", want: ErrBlobFeeTooLow},
+		{code: -32000, message: "insufficient funds for gas * price + value", want: nil},
+	}
+	for _, tt := range tests {
+		if got := classifyRPCError(tt.code, tt.message); got != tt.want {
+			t.Errorf("classifyRPCError(%d, %q) = %v, want %v", tt.code, tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestCallJSONRPC(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantResult string
+		wantErr    error
+		wantRPCErr bool
+	}{
+		{name: "result", status: http.StatusOK, body: `{"jsonrpc":"2.0","id":1,"result":{"bundleHash":"0x01"}}`, wantResult: `{"bundleHash":"0x01"}`},
+		{name: "unknown method", status: http.StatusOK, body: `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`, wantErr: ErrUnknownMethod, wantRPCErr: true},
+		{name: "unclassified error", status: http.StatusOK, body: `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"insufficient funds"}}`, wantRPCErr: true},
+		{name: "error object with http error", status: http.StatusBadRequest, body: `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"nonce too low"}}`, wantErr: ErrNonceTooLow, wantRPCErr: true},
+		{name: "malformed payload", status: http.StatusOK, body: `{"jsonrpc":"2.0","id":1,"result":`, wantErr: ErrMalformedJSONRPCPayload},
+		{name: "rate limited status", status: http.StatusTooManyRequests, body: `slow down`, wantErr: ErrRateLimited},
+		{name: "plain text http error", status: http.StatusBadGateway, body: `bad gateway`, wantErr: ErrUnexpectedHTTPStatus},
+		{name: "json http error without error object", status: http.StatusInternalServerError, body: `{"jsonrpc":"2.0","id":1}`, wantErr: ErrUnexpectedHTTPStatus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newRawStub(t, tt.status, tt.body)
+			result, err := callJSONRPC(context.Background(), server.URL, "eth_sendBundle", []string{}, nil)
+
+			var rpcErr *RPCError
+			if got := errors.As(err, &rpcErr); got != tt.wantRPCErr {
+				t.Errorf("error %v is an *RPCError: %v, want %v", err, got, tt.wantRPCErr)
+			}
+			if tt.wantErr == nil && !tt.wantRPCErr {
+				if err != nil {
+					t.Fatalf("callJSONRPC: %v", err)
+				}
+				if string(result) != tt.wantResult {
+					t.Fatalf("result = %s, want %s", result, tt.wantResult)
+				}
+				return
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("callJSONRPC() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCallJSONRPCSignature(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+
+	for _, signed := range []bool{false, true} {
+		var header string
+		var body []byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header.Get(flashbotsSignatureHeader)
+			body, _ = io.ReadAll(r.Body)
+			io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":true}`)
+		}))
+
+		signingKey := key
+		if !signed {
+			signingKey = nil
+		}
+		_, err := callJSONRPC(context.Background(), server.URL, "eth_sendBundle", []string{"0x01"}, signingKey)
+		server.Close()
+		if err != nil {
+			t.Fatalf("callJSONRPC: %v", err)
+		}
+
+		var req jsonRPCRequest
+		if err := json.Unmarshal(body, &req); err != nil || req.Method != "eth_sendBundle" || req.Jsonrpc != "2.0" {
+			t.Fatalf("request = %s, %v", body, err)
+		}
+		if !signed {
+			if header != "" {
+				t.Fatalf("unsigned request has %s header %q", flashbotsSignatureHeader, header)
+			}
+			continue
+		}
+
+		address, signatureHex, ok := strings.Cut(header, ":")
+		if !ok {
+			t.Fatalf("%s header %q is not address:signature", flashbotsSignatureHeader, header)
+		}
+		signature, err := hexutil.Decode(signatureHex)
+		if err != nil {
+			t.Fatalf("invalid signature %q: %v", signatureHex, err)
+		}
+		pubkey, err := crypto.SigToPub(accounts.TextHash([]byte(crypto.Keccak256Hash(body).Hex())), signature)
+		if err != nil {
+			t.Fatalf("failed to recover signer: %v", err)
+		}
+		if want := crypto.PubkeyToAddress(key.PublicKey); common.HexToAddress(address) != want || crypto.PubkeyToAddress(*pubkey) != want {
+			t.Fatalf("header %q recovers %s, want %s", header, crypto.PubkeyToAddress(*pubkey), want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package eth
+
+import (
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/metrics/prometheus"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// metricsPrefix is prepended to the name of every metric.
+const metricsPrefix = "sendblob/"
+
+// Metrics holds the counters and histograms of the bidder loop and serves them in the Prometheus text format.
+// Amounts of ETH are recorded in gwei so that they fit the integer metrics.
+type Metrics struct {
+	registry metrics.Registry
+
+	BlobTxsBuilt      metrics.Counter   // Blob transactions built and signed.
+	BlobsBuilt        metrics.Counter   // Blobs carried by the built transactions.
+	BidsSent          metrics.Counter   // Preconfirmation bids sent to the bidder node.
+	BidsFailed        metrics.Counter   // Preconfirmation bids that failed.
+	BidsPaidGwei      metrics.Counter   // Sum of the amounts of the bids that received a commitment.
+	FeesPaidGwei      metrics.Counter   // Execution and blob fees paid by included transactions.
+	Inclusions        metrics.Counter   // Transactions included on chain.
+	TimeToCommitment  metrics.Histogram // Milliseconds from sending a bid to receiving a commitment.
+	InclusionDelay    metrics.Histogram // Blocks from the first target block to the inclusion block.
+	DepositGwei       metrics.Gauge     // Bidder deposit in the current window.
+	BlobBaseFeeWei    metrics.Gauge     // Blob base fee of the next block.
+	PendingTxs        metrics.Gauge     // Transactions waiting for inclusion.
+	HealthyRPCClients metrics.Gauge     // RPC endpoints that passed their last health check.
+}
+
+// NewMetrics creates the metrics of the bidder loop in a registry of their own. It enables metrics
+// collection in go-ethereum, whose constructors return no-op metrics otherwise.
+func NewMetrics() *Metrics {
+	metrics.Enabled = true
+
+	r := metrics.NewRegistry()
+	sample := func() metrics.Sample { return metrics.NewExpDecaySample(1028, 0.015) }
+	return &Metrics{
+		registry:          r,
+		BlobTxsBuilt:      metrics.NewRegisteredCounter(metricsPrefix+"blobtxs/built", r),
+		BlobsBuilt:        metrics.NewRegisteredCounter(metricsPrefix+"blobs/built", r),
+		BidsSent:          metrics.NewRegisteredCounter(metricsPrefix+"bids/sent", r),
+		BidsFailed:        metrics.NewRegisteredCounter(metricsPrefix+"bids/failed", r),
+		BidsPaidGwei:      metrics.NewRegisteredCounter(metricsPrefix+"bids/committed_gwei", r),
+		FeesPaidGwei:      metrics.NewRegisteredCounter(metricsPrefix+"fees/paid_gwei", r),
+		Inclusions:        metrics.NewRegisteredCounter(metricsPrefix+"blobtxs/included", r),
+		TimeToCommitment:  metrics.NewRegisteredHistogram(metricsPrefix+"bids/time_to_commitment_ms", r, sample()),
+		InclusionDelay:    metrics.NewRegisteredHistogram(metricsPrefix+"blobtxs/inclusion_delay_blocks", r, sample()),
+		DepositGwei:       metrics.NewRegisteredGauge(metricsPrefix+"deposit/balance_gwei", r),
+		BlobBaseFeeWei:    metrics.NewRegisteredGauge(metricsPrefix+"blobs/base_fee_wei", r),
+		PendingTxs:        metrics.NewRegisteredGauge(metricsPrefix+"blobtxs/pending", r),
+		HealthyRPCClients: metrics.NewRegisteredGauge(metricsPrefix+"rpc/healthy", r),
+	}
+}
+
+// Handler returns the HTTP handler that serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return prometheus.Handler(m.registry)
+}
+
+// RecordSubmissions counts the submissions and failures of every endpoint, one counter pair per endpoint and method.
+func (m *Metrics) RecordSubmissions(results []SubmitResult) {
+	for _, result := range results {
+		name := metricsPrefix + "submissions/" + result.Method + "_" + metricName(result.Endpoint)
+		metrics.GetOrRegisterCounter(name, m.registry).Inc(1)
+		if result.Err != nil {
+			metrics.GetOrRegisterCounter(name+"_failed", m.registry).Inc(1)
+		}
+	}
+}
+
+// RecordCommitment counts a commitment received from a provider, the time it took since the bid was sent
+// and the amount of the committed bid.
+func (m *Metrics) RecordCommitment(provider string, latency time.Duration, amount *big.Int) {
+	metrics.GetOrRegisterCounter(metricsPrefix+"commitments/"+metricName(provider), m.registry).Inc(1)
+	m.TimeToCommitment.Update(latency.Milliseconds())
+	if amount != nil {
+		m.BidsPaidGwei.Inc(toGwei(amount))
+	}
+}
+
+// RecordInclusion records an included transaction, its delay in blocks and the fees it paid.
+func (m *Metrics) RecordInclusion(targetBlock, inclusionBlock uint64, fees *big.Int) {
+	m.Inclusions.Inc(1)
+	if inclusionBlock >= targetBlock {
+		m.InclusionDelay.Update(int64(inclusionBlock - targetBlock))
+	}
+	if fees != nil {
+		m.FeesPaidGwei.Inc(toGwei(fees))
+	}
+}
+
+// RevertInclusion takes back an inclusion whose block was reorged out and the fees it paid.
+func (m *Metrics) RevertInclusion(fees *big.Int) {
+	m.Inclusions.Dec(1)
+	if fees != nil {
+		m.FeesPaidGwei.Dec(toGwei(fees))
+	}
+}
+
+// toGwei converts an amount of wei to whole gwei.
+func toGwei(wei *big.Int) int64 {
+	return new(big.Int).Div(wei, big.NewInt(params.GWei)).Int64()
+}
+
+// metricName turns an endpoint URL or an address into a string usable in a Prometheus metric name.
+func metricName(value string) string {
+	if u, err := url.Parse(value); err == nil && u.Host != "" {
+		value = u.Host
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(value))
+}
@@ -0,0 +1,98 @@
+package eth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// ChainWindow remembers the headers of the most recent blocks so that reorgs can be detected and
+// the blocks they dropped can be rolled back.
+type ChainWindow struct {
+	size    uint64
+	head    uint64
+	headers map[uint64]*types.Header
+}
+
+// NewChainWindow creates a window over the given number of most recent blocks.
+func NewChainWindow(size uint64) *ChainWindow {
+	return &ChainWindow{size: max(size, 1), headers: make(map[uint64]*types.Header)}
+}
+
+// Contains reports whether the block with the given number and hash is part of the chain the window follows.
+func (w *ChainWindow) Contains(number uint64, hash common.Hash) bool {
+	header, ok := w.headers[number]
+	return ok && header.Hash() == hash
+}
+
+// Update adds a new head to the window. When the head does not extend the previous head, its ancestors
+// are fetched until the block both chains share is found, and the blocks of the old chain after it are dropped.
+// A gap between the previous head and the new head is filled the same way.
+//
+// Parameters:
+// - ctx: The context for the client requests.
+// - client: The client used to fetch the ancestors of the new head.
+// - header: The new head.
+//
+// Returns:
+// - The reorg, or nil if the new head extends the previous head. An error if an ancestor cannot be fetched.
+func (w *ChainWindow) Update(ctx context.Context, client *ethclient.Client, header *types.Header) (*Reorg, error) {
+	number := header.Number.Uint64()
+	if w.Contains(number, header.Hash()) {
+		return nil, nil
+	}
+
+	// Start over when the window is empty or the new head is too far ahead to connect to it.
+	if len(w.headers) == 0 || number > w.head+w.size {
+		clear(w.headers)
+		w.headers[number], w.head = header, number
+		return nil, nil
+	}
+
+	added := []*types.Header{header}
+	ancestor := header
+	for ancestor.Number.Uint64() > 0 {
+		parentNumber := ancestor.Number.Uint64() - 1
+		if w.Contains(parentNumber, ancestor.ParentHash) {
+			break
+		}
+		if _, ok := w.headers[parentNumber]; !ok && parentNumber <= w.head {
+			break // The reorg is deeper than the window.
+		}
+		parent, err := client.HeaderByHash(ctx, ancestor.ParentHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get header %s: %w", ancestor.ParentHash, err)
+		}
+		added = append(added, parent)
+		ancestor = parent
+	}
+
+	// The new chain replaces the old one from its oldest fetched block on. When that is a new genesis
+	// block, no block is shared and the reorg reports block 0 as its ancestor.
+	firstAdded := ancestor.Number.Uint64()
+
+	var reorg *Reorg
+	if firstAdded <= w.head {
+		reorg = &Reorg{OldHead: w.headers[w.head], NewHead: header, Ancestor: max(firstAdded, 1) - 1}
+		for n := firstAdded; n <= w.head; n++ {
+			if dropped, ok := w.headers[n]; ok {
+				reorg.Dropped = append(reorg.Dropped, dropped.Hash())
+				delete(w.headers, n)
+			}
+		}
+	}
+
+	for _, h := range added {
+		w.headers[h.Number.Uint64()] = h
+	}
+	w.head = number
+	for n := range w.headers {
+		if n+w.size <= number {
+			delete(w.headers, n)
+		}
+	}
+	return reorg, nil
+}
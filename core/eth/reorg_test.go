@@ -0,0 +1,159 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// testChain serves the headers of one or more forks to a ChainWindow through eth_getBlockByHash.
+type testChain map[common.Hash]*types.Header
+
+// extend appends n blocks to parent, or starts a chain at genesis if parent is nil. fork tells the blocks
+// of different forks apart.
+func (c testChain) extend(parent *types.Header, n int, fork byte) []*types.Header {
+	var headers []*types.Header
+	for i := 0; i < n; i++ {
+		header := &types.Header{Number: new(big.Int), Difficulty: new(big.Int), Extra: []byte{fork}}
+		if parent != nil {
+			header.Number.Add(parent.Number, common.Big1)
+			header.ParentHash = parent.Hash()
+		}
+		c[header.Hash()] = header
+		headers = append(headers, header)
+		parent = header
+	}
+	return headers
+}
+
+// client returns a client whose eth_getBlockByHash answers with the headers of the chain.
+func (c testChain) client(t *testing.T) *ethclient.Client {
+	server := newRPCStub(t, func(method string, params []json.RawMessage) (interface{}, error) {
+		var hash common.Hash
+		if err := json.Unmarshal(params[0], &hash); err != nil {
+			return nil, err
+		}
+		if header, ok := c[hash]; ok {
+			return header, nil
+		}
+		return nil, nil
+	})
+	client, err := ethclient.Dial(server.URL)
+	if err != nil {
+		t.Fatalf("failed to dial stub: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+// hashes returns the hashes of headers.
+func hashes(headers ...*types.Header) []common.Hash {
+	var out []common.Hash
+	for _, header := range headers {
+		out = append(out, header.Hash())
+	}
+	return out
+}
+
+func TestChainWindowUpdate(t *testing.T) {
+	chain := testChain{}
+	a := chain.extend(nil, 11, 'a')   // Blocks 0 to 10.
+	b := chain.extend(a[4], 2, 'b')   // Blocks 5 and 6, forking off block 4.
+	c := chain.extend(a[5], 6, 'c')   // Blocks 6 to 11, forking off block 5.
+	g := chain.extend(nil, 2, 'g')    // A different genesis and its child.
+	one := chain.extend(a[9], 1, 'o') // Block 10, replacing a[10].
+	client := chain.client(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		size  uint64
+		heads []*types.Header
+		want  *Reorg // The reorg of the last head.
+	}{
+		{
+			name:  "linear extension",
+			size:  4,
+			heads: a[:6],
+		},
+		{
+			name:  "gap filled from the new head",
+			size:  4,
+			heads: []*types.Header{a[2], a[3], a[6]},
+		},
+		{
+			name:  "one block reorg",
+			size:  4,
+			heads: append(a[:11:11], one[0]),
+			want:  &Reorg{OldHead: a[10], NewHead: one[0], Ancestor: 9, Dropped: hashes(a[10])},
+		},
+		{
+			name:  "two block reorg to a shorter chain",
+			size:  4,
+			heads: append(a[3:7:7], b[0]),
+			want:  &Reorg{OldHead: a[6], NewHead: b[0], Ancestor: 4, Dropped: hashes(a[5], a[6])},
+		},
+		{
+			name:  "reorg deeper than the window",
+			size:  3,
+			heads: append(a[:11:11], c[5]),
+			want:  &Reorg{OldHead: a[10], NewHead: c[5], Ancestor: 7, Dropped: hashes(a[8], a[9], a[10])},
+		},
+		{
+			name:  "genesis replaced",
+			size:  4,
+			heads: []*types.Header{a[0], g[0]},
+			want:  &Reorg{OldHead: a[0], NewHead: g[0], Ancestor: 0, Dropped: hashes(a[0])},
+		},
+		{
+			name:  "fork from a different genesis",
+			size:  4,
+			heads: []*types.Header{a[0], a[1], g[1]},
+			want:  &Reorg{OldHead: a[1], NewHead: g[1], Ancestor: 0, Dropped: hashes(a[0], a[1])},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window := NewChainWindow(tt.size)
+			var reorg *Reorg
+			for i, head := range tt.heads {
+				var err error
+				reorg, err = window.Update(ctx, client, head)
+				if err != nil {
+					t.Fatalf("Update(%d): %v", head.Number, err)
+				}
+				if i < len(tt.heads)-1 && reorg != nil {
+					t.Fatalf("Update(%d) = %+v, want no reorg", head.Number, reorg)
+				}
+			}
+
+			if !reflect.DeepEqual(reorg, tt.want) {
+				t.Fatalf("Update() = %+v, want %+v", reorg, tt.want)
+			}
+			// The window holds the new chain back to its size, but never older blocks than the first head.
+			head, first := tt.heads[len(tt.heads)-1], tt.heads[0].Number.Uint64()
+			for n, number := uint64(0), head.Number.Uint64(); n < tt.size && n <= number && number-n >= first; n++ {
+				ancestor := head
+				for ancestor.Number.Uint64() > number-n {
+					ancestor = chain[ancestor.ParentHash]
+				}
+				if !window.Contains(ancestor.Number.Uint64(), ancestor.Hash()) {
+					t.Errorf("window does not contain block %d of the new chain", ancestor.Number)
+				}
+			}
+			if tt.want != nil {
+				for _, dropped := range tt.want.Dropped {
+					if window.Contains(chain[dropped].Number.Uint64(), dropped) {
+						t.Errorf("window still contains dropped block %d", chain[dropped].Number)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/primev/preconf_blob_bidder/core/logging"
+)
+
+// ErrNoHealthyEndpoint is returned by RPCPool.Best when no endpoint passed its last health check.
+var ErrNoHealthyEndpoint = errors.New("no healthy rpc endpoint")
+
+// RPCPoolConfig holds the health check settings of an RPCPool.
+type RPCPoolConfig struct {
+	CheckInterval time.Duration // Time between two health checks of every endpoint.
+	Timeout       time.Duration // Timeout of a single health check request.
+	MaxBlockLag   uint64        // Number of blocks an endpoint may trail the most advanced endpoint.
+	MaxLatency    time.Duration // Slowest health check response accepted from a healthy endpoint.
+	EvictAfter    int           // Number of consecutive failed checks after which an endpoint is evicted.
+	ReadmitAfter  int           // Number of consecutive passed checks after which an evicted endpoint is readmitted.
+}
+
+// DefaultRPCPoolConfig returns health check settings suited to a 12 second slot time.
+func DefaultRPCPoolConfig() RPCPoolConfig {
+	return RPCPoolConfig{
+		CheckInterval: 12 * time.Second,
+		Timeout:       5 * time.Second,
+		MaxBlockLag:   2,
+		MaxLatency:    2 * time.Second,
+		EvictAfter:    3,
+		ReadmitAfter:  2,
+	}
+}
+
+// EndpointState is the health of one endpoint of an RPCPool as of its last check.
+type EndpointState struct {
+	URL         string        // The endpoint URL.
+	Healthy     bool          // Whether the endpoint passed its last check.
+	Evicted     bool          // Whether the endpoint is evicted and not used for reads.
+	BlockNumber uint64        // The latest block number reported by the endpoint.
+	Lag         uint64        // The number of blocks the endpoint trails the most advanced endpoint.
+	Latency     time.Duration // The response time of the last check.
+	Failures    int           // The number of consecutive failed checks.
+	Successes   int           // The number of consecutive passed checks.
+	LastChecked time.Time     // The time of the last check.
+	LastError   string        // The reason the last check failed, empty if it passed.
+}
+
+// poolEndpoint is an endpoint of an RPCPool with its client.
+type poolEndpoint struct {
+	client *ethclient.Client // Nil until the endpoint was dialed successfully.
+	state  EndpointState
+}
+
+// RPCPool keeps a set of RPC endpoints health-checked and hands out the healthiest one for reads, so
+// that a slow or stale node does not slow down the whole loop. Endpoints that fail several checks in a
+// row are evicted and readmitted once they pass several checks again.
+type RPCPool struct {
+	mu        sync.RWMutex
+	cfg       RPCPoolConfig
+	endpoints []*poolEndpoint
+}
+
+// NewRPCPool creates a pool for the given endpoints and runs a first health check. Endpoints that cannot
+// be dialed yet are kept in the pool and dialed again on every check.
+//
+// Parameters:
+// - ctx: The context for the first health check.
+// - urls: The RPC endpoints.
+// - cfg: The health check settings.
+//
+// Returns:
+// - A pointer to an RPCPool, or an error if no endpoint is given.
+func NewRPCPool(ctx context.Context, urls []string, cfg RPCPoolConfig) (*RPCPool, error) {
+	pool := &RPCPool{cfg: cfg}
+	for _, url := range urls {
+		if url == "" {
+			continue
+		}
+		pool.endpoints = append(pool.endpoints, &poolEndpoint{state: EndpointState{URL: url}})
+	}
+	if len(pool.endpoints) == 0 {
+		return nil, errors.New("rpc pool needs at least one endpoint")
+	}
+
+	pool.Check(ctx)
+	return pool, nil
+}
+
+// Start health-checks the pool every CheckInterval until ctx is cancelled.
+func (p *RPCPool) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(p.cfg.CheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.Check(ctx)
+			}
+		}
+	}()
+}
+
+// checkResult is the outcome of a single endpoint health check.
+type checkResult struct {
+	client      *ethclient.Client
+	blockNumber uint64
+	latency     time.Duration
+	err         error
+}
+
+// Check health-checks every endpoint in parallel and updates the pool state.
+func (p *RPCPool) Check(ctx context.Context) {
+	p.mu.RLock()
+	endpoints := make([]*poolEndpoint, len(p.endpoints))
+	copy(endpoints, p.endpoints)
+	clients := make([]*ethclient.Client, len(endpoints))
+	for i, endpoint := range endpoints {
+		clients[i] = endpoint.client
+	}
+	p.mu.RUnlock()
+
+	results := make([]checkResult, len(endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range endpoints {
+		wg.Add(1)
+		go func(i int, url string, client *ethclient.Client) {
+			defer wg.Done()
+			results[i] = p.check(ctx, url, client)
+		}(i, endpoint.state.URL, clients[i])
+	}
+	wg.Wait()
+
+	var head uint64
+	for _, result := range results {
+		if result.err == nil {
+			head = max(head, result.blockNumber)
+		}
+	}
+
+	logger := logging.FromContext(ctx, nil)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for i, endpoint := range endpoints {
+		result, state := results[i], &endpoint.state
+		if result.client != nil {
+			endpoint.client = result.client
+		}
+		state.LastChecked = now
+		state.Latency = result.latency
+
+		err := result.err
+		if err == nil {
+			state.BlockNumber = result.blockNumber
+			state.Lag = head - result.blockNumber
+			switch {
+			case state.Lag > p.cfg.MaxBlockLag:
+				err = errors.New("endpoint is behind")
+			case p.cfg.MaxLatency > 0 && result.latency > p.cfg.MaxLatency:
+				err = errors.New("endpoint is too slow")
+			}
+		}
+
+		if err != nil {
+			state.Healthy, state.LastError = false, err.Error()
+			state.Failures++
+			state.Successes = 0
+			if !state.Evicted && state.Failures >= p.cfg.EvictAfter {
+				state.Evicted = true
+				logger.Warn("evicting rpc endpoint", logging.KeyEndpoint, state.URL, "failures", state.Failures, "lag", state.Lag, "latency", state.Latency, logging.KeyErr, err)
+			}
+			continue
+		}
+
+		state.Healthy, state.LastError = true, ""
+		state.Successes++
+		state.Failures = 0
+		if state.Evicted && state.Successes >= p.cfg.ReadmitAfter {
+			state.Evicted = false
+			logger.Info("readmitting rpc endpoint", logging.KeyEndpoint, state.URL, logging.KeyBlock, state.BlockNumber, "latency", state.Latency)
+		}
+	}
+}
+
+// check dials the endpoint if needed and measures how long it takes to return its latest block number.
+func (p *RPCPool) check(ctx context.Context, url string, client *ethclient.Client) checkResult {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	if client == nil {
+		dialed, err := ethclient.DialContext(ctx, url)
+		if err != nil {
+			return checkResult{latency: time.Since(start), err: err}
+		}
+		client = dialed
+	}
+	blockNumber, err := client.BlockNumber(ctx)
+	return checkResult{client: client, blockNumber: blockNumber, latency: time.Since(start), err: err}
+}
+
+// Best returns the client of the healthiest endpoint, the one with the lowest lag and then the lowest latency.
+//
+// Returns:
+// - The client, or ErrNoHealthyEndpoint if no endpoint passed its last check.
+func (p *RPCPool) Best() (*ethclient.Client, error) {
+	clients := p.Healthy()
+	if len(clients) == 0 {
+		return nil, ErrNoHealthyEndpoint
+	}
+	return clients[0], nil
+}
+
+// Healthy returns the clients of all healthy endpoints that are not evicted, healthiest first.
+func (p *RPCPool) Healthy() []*ethclient.Client {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var healthy []*poolEndpoint
+	for _, endpoint := range p.endpoints {
+		if endpoint.client != nil && endpoint.state.Healthy && !endpoint.state.Evicted {
+			healthy = append(healthy, endpoint)
+		}
+	}
+	sort.SliceStable(healthy, func(i, j int) bool {
+		if healthy[i].state.Lag != healthy[j].state.Lag {
+			return healthy[i].state.Lag < healthy[j].state.Lag
+		}
+		return healthy[i].state.Latency < healthy[j].state.Latency
+	})
+
+	clients := make([]*ethclient.Client, len(healthy))
+	for i, endpoint := range healthy {
+		clients[i] = endpoint.client
+	}
+	return clients
+}
+
+// State returns a snapshot of the state of every endpoint, in configuration order.
+func (p *RPCPool) State() []EndpointState {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	states := make([]EndpointState, len(p.endpoints))
+	for i, endpoint := range p.endpoints {
+		states[i] = endpoint.state
+	}
+	return states
+}
+
+// Close closes the clients of all endpoints. The pool must not be used afterwards.
+func (p *RPCPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, endpoint := range p.endpoints {
+		if endpoint.client != nil {
+			endpoint.client.Close()
+			endpoint.client = nil
+		}
+	}
+}
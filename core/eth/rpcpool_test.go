@@ -0,0 +1,154 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// poolNode is a stub endpoint of an RPCPool whose block number, failures and latency can be changed
+// between health checks. It reports id as its chain ID, which tells the clients of a pool apart.
+type poolNode struct {
+	url     string
+	block   atomic.Uint64
+	failing atomic.Bool
+	delay   time.Duration
+}
+
+// newPoolNode starts a stub endpoint at block.
+func newPoolNode(t *testing.T, id, block uint64, delay time.Duration) *poolNode {
+	t.Helper()
+	node := &poolNode{delay: delay}
+	node.block.Store(block)
+	server := newRPCStub(t, func(method string, params []json.RawMessage) (interface{}, error) {
+		time.Sleep(node.delay)
+		if node.failing.Load() {
+			return nil, &RPCError{Code: -32000, Message: "node is syncing"}
+		}
+		switch method {
+		case "eth_blockNumber":
+			return hexutil.Uint64(node.block.Load()), nil
+		case "eth_chainId":
+			return hexutil.Uint64(id), nil
+		}
+		return nil, &RPCError{Code: -32601, Message: "the method " + method + " does not exist"}
+	})
+	node.url = server.URL
+	return node
+}
+
+// healthyIDs returns the chain IDs of the healthy clients of a pool, healthiest first.
+func healthyIDs(t *testing.T, pool *RPCPool) []uint64 {
+	t.Helper()
+	var ids []uint64
+	for _, client := range pool.Healthy() {
+		id, err := client.ChainID(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id.Uint64())
+	}
+	return ids
+}
+
+func TestRPCPoolEviction(t *testing.T) {
+	stable := newPoolNode(t, 1, 100, 0)
+	flaky := newPoolNode(t, 2, 100, 0)
+	pool, err := NewRPCPool(context.Background(), []string{stable.url, flaky.url}, RPCPoolConfig{
+		Timeout:      time.Second,
+		MaxBlockLag:  2,
+		EvictAfter:   2,
+		ReadmitAfter: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	tests := []struct {
+		name             string
+		failing          bool
+		block            uint64 // The block of the flaky endpoint, the stable one stays at 100.
+		healthy, evicted bool
+		clients          int
+	}{
+		{name: "first failure", failing: true, block: 100, healthy: false, evicted: false, clients: 1},
+		{name: "evicted after EvictAfter failures", failing: true, block: 100, healthy: false, evicted: true, clients: 1},
+		{name: "first pass stays evicted", block: 100, healthy: true, evicted: true, clients: 1},
+		{name: "readmitted after ReadmitAfter passes", block: 100, healthy: true, evicted: false, clients: 2},
+		{name: "lagging counts as failure", block: 97, healthy: false, evicted: false, clients: 1},
+		{name: "evicted again while lagging", block: 97, healthy: false, evicted: true, clients: 1},
+		{name: "caught up", block: 100, healthy: true, evicted: true, clients: 1},
+	}
+	for _, tt := range tests {
+		flaky.failing.Store(tt.failing)
+		flaky.block.Store(tt.block)
+		pool.Check(context.Background())
+
+		state := pool.State()[1]
+		if state.Healthy != tt.healthy || state.Evicted != tt.evicted {
+			t.Errorf("%s: healthy %t, evicted %t, want %t, %t (%s)", tt.name, state.Healthy, state.Evicted, tt.healthy, tt.evicted, state.LastError)
+		}
+		if clients := len(pool.Healthy()); clients != tt.clients {
+			t.Errorf("%s: %d healthy clients, want %d", tt.name, clients, tt.clients)
+		}
+	}
+}
+
+func TestRPCPoolHealthyOrder(t *testing.T) {
+	slow := newPoolNode(t, 1, 100, 100*time.Millisecond)
+	fast := newPoolNode(t, 2, 100, 0)
+	behind := newPoolNode(t, 3, 99, 0)
+	pool, err := NewRPCPool(context.Background(), []string{behind.url, slow.url, fast.url}, RPCPoolConfig{
+		Timeout:      time.Second,
+		MaxBlockLag:  2,
+		EvictAfter:   1,
+		ReadmitAfter: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	// Lower lag comes first, then lower latency
+	ids := healthyIDs(t, pool)
+	if len(ids) != 3 || ids[0] != 2 || ids[1] != 1 || ids[2] != 3 {
+		t.Errorf("healthy endpoints %v, want [2 1 3]", ids)
+	}
+	best, err := pool.Best()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := best.ChainID(context.Background()); err != nil || id.Uint64() != 2 {
+		t.Errorf("best endpoint %v, %v, want 2", id, err)
+	}
+}
+
+func TestRPCPoolNoHealthyEndpoint(t *testing.T) {
+	node := newPoolNode(t, 1, 100, 0)
+	node.failing.Store(true)
+	pool, err := NewRPCPool(context.Background(), []string{node.url}, RPCPoolConfig{Timeout: time.Second, EvictAfter: 3, ReadmitAfter: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	if _, err := pool.Best(); !errors.Is(err, ErrNoHealthyEndpoint) {
+		t.Errorf("Best = %v, want ErrNoHealthyEndpoint", err)
+	}
+
+	node.failing.Store(false)
+	pool.Check(context.Background())
+	if _, err := pool.Best(); err != nil {
+		t.Errorf("Best after a passed check = %v, want the endpoint", err)
+	}
+
+	if _, err := NewRPCPool(context.Background(), []string{""}, DefaultRPCPoolConfig()); err == nil {
+		t.Error("NewRPCPool accepted no endpoints")
+	}
+}
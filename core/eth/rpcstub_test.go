@@ -0,0 +1,67 @@
+package eth
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// rpcStubResponder answers a JSON-RPC request of an rpc stub with a result, which is marshalled as the
+// response result, or with an *RPCError, which is sent as the response error object.
+type rpcStubResponder func(method string, params []json.RawMessage) (interface{}, error)
+
+// newRPCStub starts a JSON-RPC endpoint that answers every request with respond. It is closed when the
+// test ends.
+func newRPCStub(t *testing.T, respond rpcStubResponder) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read request: %v", err)
+			return
+		}
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("failed to decode request %s: %v", body, err)
+			return
+		}
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		result, err := respond(req.Method, req.Params)
+		var rpcErr *RPCError
+		switch {
+		case errors.As(err, &rpcErr):
+			resp["error"] = rpcErr
+		case err != nil:
+			t.Errorf("stub failed to answer %s: %v", req.Method, err)
+			return
+		default:
+			resp["result"] = result
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			t.Errorf("failed to write response: %v", err)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newRawStub starts an endpoint that answers every request with status and body. It is closed when the
+// test ends.
+func newRawStub(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
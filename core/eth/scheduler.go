@@ -0,0 +1,251 @@
+package eth
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/google/uuid"
+	"github.com/primev/preconf_blob_bidder/core/tracing"
+)
+
+// BundleScheduler submits the same signed transaction as bundles for a range of consecutive blocks, so that
+// a transaction that misses its first target block gets more chances without building a new transaction.
+// Every target block gets its own replacement UUID, which lets bundles that were submitted ahead of time be
+// cancelled once the transaction is included. Submitters that do not target blocks receive the transaction once.
+type BundleScheduler struct {
+	mu        sync.Mutex
+	blocks    uint64
+	lookahead uint64
+	scheduled map[common.Hash]*scheduledTx
+}
+
+// scheduledTx is a transaction whose bundles are being submitted by a BundleScheduler.
+type scheduledTx struct {
+	tx        *types.Transaction
+	lastBlock uint64                  // The last block targeted by the transaction.
+	next      uint64                  // The next target block that has not been submitted yet.
+	targets   map[uint64]bundleTarget // The submitted bundles by target block.
+}
+
+// bundleTarget records where the bundle for one target block was sent.
+type bundleTarget struct {
+	uuid       string
+	submitters []*BundleSubmitter
+}
+
+// NewBundleScheduler creates a bundle scheduler.
+//
+// Parameters:
+// - blocks: The number of consecutive blocks every transaction targets, at least 1.
+// - lookahead: The number of upcoming target blocks that are submitted in advance, at least 1.
+//
+// Returns:
+// - A pointer to a BundleScheduler.
+func NewBundleScheduler(blocks, lookahead uint64) *BundleScheduler {
+	return &BundleScheduler{
+		blocks:    max(blocks, 1),
+		lookahead: max(lookahead, 1),
+		scheduled: make(map[common.Hash]*scheduledTx),
+	}
+}
+
+// Len returns the number of transactions that still have target blocks left.
+func (s *BundleScheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.scheduled)
+}
+
+// Schedule starts submitting signedTx for the target blocks firstBlock onwards. The first lookahead
+// target blocks are submitted right away, the remaining ones are submitted by Advance.
+//
+// Parameters:
+// - ctx: The context for the submissions.
+// - submitters: The endpoints to submit to.
+// - signedTx: The signed transaction.
+// - head: The latest block header, which the timestamps of the target blocks are predicted from.
+// - firstBlock: The first block the transaction targets.
+//
+// Returns:
+// - One SubmitResult per submission.
+func (s *BundleScheduler) Schedule(ctx context.Context, submitters []Submitter, signedTx *types.Transaction, head *types.Header, firstBlock uint64) []SubmitResult {
+	bundles, others := splitSubmitters(submitters)
+
+	s.mu.Lock()
+	scheduled := &scheduledTx{
+		tx:        signedTx,
+		lastBlock: firstBlock + s.blocks - 1,
+		next:      firstBlock,
+		targets:   make(map[uint64]bundleTarget),
+	}
+	s.scheduled[signedTx.Hash()] = scheduled
+	var jobs []bundleJob
+	if len(bundles) > 0 {
+		jobs = s.queue(scheduled, head, firstBlock+s.lookahead-1)
+	}
+	s.mu.Unlock()
+
+	results := Broadcast(ctx, others, signedTx, firstBlock)
+	return append(results, s.submit(ctx, bundles, jobs)...)
+}
+
+// Advance submits the target blocks that came within the lookahead of the new head and forgets the
+// transactions whose last target block has passed.
+//
+// Parameters:
+// - ctx: The context for the submissions.
+// - submitters: The endpoints to submit to. Only bundle submitters are used.
+// - head: The latest block header.
+//
+// Returns:
+// - One SubmitResult per submission.
+func (s *BundleScheduler) Advance(ctx context.Context, submitters []Submitter, head *types.Header) []SubmitResult {
+	number := head.Number.Uint64()
+	bundles, _ := splitSubmitters(submitters)
+
+	s.mu.Lock()
+	var jobs []bundleJob
+	for hash, scheduled := range s.scheduled {
+		for block := range scheduled.targets {
+			if block <= number {
+				delete(scheduled.targets, block)
+			}
+		}
+		if scheduled.lastBlock <= number {
+			delete(s.scheduled, hash)
+			continue
+		}
+		scheduled.next = max(scheduled.next, number+1)
+		if len(bundles) > 0 {
+			jobs = append(jobs, s.queue(scheduled, head, number+s.lookahead)...)
+		}
+	}
+	s.mu.Unlock()
+
+	return s.submit(ctx, bundles, jobs)
+}
+
+// Cancel stops submitting a transaction, for example because it was included or replaced, and cancels
+// the bundles that were already submitted for blocks after head through their replacement UUID.
+//
+// Parameters:
+// - ctx: The context for the cancellations.
+// - txHash: The hash of the transaction.
+// - head: The number of the latest block.
+//
+// Returns:
+// - One SubmitResult per cancellation.
+func (s *BundleScheduler) Cancel(ctx context.Context, txHash common.Hash, head uint64) []SubmitResult {
+	s.mu.Lock()
+	scheduled, ok := s.scheduled[txHash]
+	delete(s.scheduled, txHash)
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	ctx, span := tracing.Start(ctx, "bundle.cancel", tracing.TxHash(txHash), tracing.Slot(head))
+	defer span.End()
+
+	var (
+		results []SubmitResult
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+	)
+	for block, target := range scheduled.targets {
+		if block <= head {
+			continue
+		}
+		for _, submitter := range target.submitters {
+			wg.Add(1)
+			go func(block uint64, id string, submitter *BundleSubmitter) {
+				defer wg.Done()
+				response, err := submitter.CancelBundle(ctx, id)
+				mu.Lock()
+				results = append(results, SubmitResult{Endpoint: submitter.Endpoint(), Method: submitter.Method(), TxHash: txHash, Block: block, Response: response, Err: err})
+				mu.Unlock()
+			}(block, target.uuid, submitter)
+		}
+	}
+	wg.Wait()
+
+	return results
+}
+
+// bundleJob is the bundle of a scheduled transaction for one target block, queued for submission.
+type bundleJob struct {
+	scheduled *scheduledTx
+	block     uint64
+	params    BundleParams
+}
+
+// queue takes the unsubmitted target blocks of a scheduled transaction up to lastBlock and returns their
+// bundles for submit. The caller must hold s.mu.
+func (s *BundleScheduler) queue(scheduled *scheduledTx, head *types.Header, lastBlock uint64) []bundleJob {
+	var jobs []bundleJob
+	for ; scheduled.next <= min(lastBlock, scheduled.lastBlock); scheduled.next++ {
+		block := scheduled.next
+		target := bundleTarget{uuid: uuid.NewString()}
+		scheduled.targets[block] = target
+		jobs = append(jobs, bundleJob{
+			scheduled: scheduled,
+			block:     block,
+			params:    BundleParams{ReplacementUUID: target.uuid, TargetTime: ExpectedTimestamp(head, block)},
+		})
+	}
+	return jobs
+}
+
+// submit sends queued bundles to every submitter and records which submitters accepted them, so that Cancel
+// can cancel them. It must be called without s.mu, which is only taken to record the submitters. Bundles of
+// transactions that were cancelled in the meantime are not recorded.
+func (s *BundleScheduler) submit(ctx context.Context, submitters []*BundleSubmitter, jobs []bundleJob) []SubmitResult {
+	var results []SubmitResult
+	for _, job := range jobs {
+		tx := job.scheduled.tx
+		ctx, span := tracing.Start(ctx, "bundle.submit", tracing.TxHash(tx.Hash()), tracing.Block(job.block))
+
+		blockResults := make([]SubmitResult, len(submitters))
+		var wg sync.WaitGroup
+		for i, submitter := range submitters {
+			wg.Add(1)
+			go func(i int, submitter *BundleSubmitter) {
+				defer wg.Done()
+				response, err := submitter.SendBundle(ctx, []*types.Transaction{tx}, job.block, job.params)
+				blockResults[i] = SubmitResult{Endpoint: submitter.Endpoint(), Method: submitter.Method(), TxHash: tx.Hash(), Block: job.block, Response: response, Err: err}
+			}(i, submitter)
+		}
+		wg.Wait()
+		span.End()
+
+		s.mu.Lock()
+		if target, ok := job.scheduled.targets[job.block]; ok && s.scheduled[tx.Hash()] == job.scheduled {
+			for i, result := range blockResults {
+				if result.Err == nil {
+					target.submitters = append(target.submitters, submitters[i])
+				}
+			}
+			job.scheduled.targets[job.block] = target
+		}
+		s.mu.Unlock()
+		results = append(results, blockResults...)
+	}
+	return results
+}
+
+// splitSubmitters separates the bundle submitters, which target single blocks, from all other submitters.
+func splitSubmitters(submitters []Submitter) ([]*BundleSubmitter, []Submitter) {
+	var (
+		bundles []*BundleSubmitter
+		others  []Submitter
+	)
+	for _, submitter := range submitters {
+		if bundle, ok := submitter.(*BundleSubmitter); ok {
+			bundles = append(bundles, bundle)
+		} else {
+			others = append(others, submitter)
+		}
+	}
+	return bundles, others
+}
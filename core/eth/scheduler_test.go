@@ -0,0 +1,199 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// bundleRelay is a stub relay that records the bundles it receives and the bundles it is asked to cancel.
+type bundleRelay struct {
+	mu        sync.Mutex
+	sent      map[uint64]string // Replacement UUIDs by target block.
+	cancelled []string          // Cancelled replacement UUIDs.
+	submitter *BundleSubmitter
+}
+
+// newBundleRelay starts a stub relay. A failing relay rejects every bundle. onSend, if set, runs while a
+// bundle is being received.
+func newBundleRelay(t *testing.T, failing bool, onSend func()) *bundleRelay {
+	t.Helper()
+	relay := &bundleRelay{sent: make(map[uint64]string)}
+	server := newRPCStub(t, func(method string, params []json.RawMessage) (interface{}, error) {
+		switch method {
+		case "eth_sendBundle":
+			if onSend != nil {
+				onSend()
+			}
+			if failing {
+				return nil, &RPCError{Code: -32000, Message: "bundle rejected"}
+			}
+			var args bundleArgs
+			if err := json.Unmarshal(params[0], &args); err != nil {
+				return nil, err
+			}
+			block, err := hexutil.DecodeUint64(args.BlockNumber)
+			if err != nil {
+				return nil, err
+			}
+			relay.mu.Lock()
+			relay.sent[block] = args.ReplacementUUID
+			relay.mu.Unlock()
+			return map[string]string{"bundleHash": "0x01"}, nil
+		case "eth_cancelBundle":
+			var args cancelBundleArgs
+			if err := json.Unmarshal(params[0], &args); err != nil {
+				return nil, err
+			}
+			relay.mu.Lock()
+			relay.cancelled = append(relay.cancelled, args.ReplacementUUID)
+			relay.mu.Unlock()
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})
+	relay.submitter = NewBundleSubmitter(server.URL, BundleOptions{})
+	return relay
+}
+
+// blocks returns the target blocks the relay received bundles for, in increasing order, and forgets them.
+func (r *bundleRelay) blocks() []uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var blocks []uint64
+	for block := range r.sent {
+		blocks = append(blocks, block)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i] < blocks[j] })
+	r.sent = make(map[uint64]string)
+	return blocks
+}
+
+// testHeader returns the header of block number.
+func testHeader(number uint64) *types.Header {
+	return &types.Header{Number: new(big.Int).SetUint64(number), Time: 1000 + number*SecondsPerSlot}
+}
+
+// testTx returns an unsigned transaction with the given nonce, which is enough for the stub relays.
+func testTx(nonce uint64) *types.Transaction {
+	return types.NewTx(&types.DynamicFeeTx{Nonce: nonce, Gas: 21000, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(10)})
+}
+
+func TestBundleSchedulerSchedule(t *testing.T) {
+	relay := newBundleRelay(t, false, nil)
+	failing := newBundleRelay(t, true, nil)
+	scheduler := NewBundleScheduler(5, 2)
+
+	results := scheduler.Schedule(context.Background(), []Submitter{relay.submitter, failing.submitter}, testTx(0), testHeader(100), 101)
+	if len(results) != 4 {
+		t.Fatalf("Schedule returned %d results, want one per submitter and target block", len(results))
+	}
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if failed != 2 {
+		t.Errorf("%d submissions failed, want the 2 of the failing relay", failed)
+	}
+	if got, want := relay.blocks(), []uint64{101, 102}; !reflect.DeepEqual(got, want) {
+		t.Errorf("submitted blocks %v, want the first lookahead targets %v", got, want)
+	}
+	if scheduler.Len() != 1 {
+		t.Errorf("scheduler holds %d transactions, want 1", scheduler.Len())
+	}
+}
+
+func TestBundleSchedulerAdvance(t *testing.T) {
+	var scheduler *BundleScheduler
+	// A relay that cannot read the scheduler while a bundle is submitted reveals that the lock is held
+	relay := newBundleRelay(t, false, func() {
+		done := make(chan struct{})
+		go func() {
+			scheduler.Len()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Error("the scheduler lock is held while bundles are submitted")
+		}
+	})
+	scheduler = NewBundleScheduler(5, 2)
+	submitters := []Submitter{relay.submitter}
+	scheduler.Schedule(context.Background(), submitters, testTx(0), testHeader(100), 101)
+	relay.blocks()
+
+	tests := []struct {
+		head   uint64
+		blocks []uint64 // The target blocks submitted for the head.
+		len    int      // The number of scheduled transactions after the head.
+	}{
+		{head: 101, blocks: []uint64{103}, len: 1},
+		{head: 102, blocks: []uint64{104}, len: 1},
+		{head: 103, blocks: []uint64{105}, len: 1},
+		{head: 104, len: 1},
+		{head: 105, len: 0},
+		{head: 106, len: 0},
+	}
+	for _, tt := range tests {
+		results := scheduler.Advance(context.Background(), submitters, testHeader(tt.head))
+		if got := relay.blocks(); !reflect.DeepEqual(got, tt.blocks) || len(results) != len(tt.blocks) {
+			t.Errorf("head %d: submitted blocks %v with %d results, want %v", tt.head, got, len(results), tt.blocks)
+		}
+		if scheduler.Len() != tt.len {
+			t.Errorf("head %d: scheduler holds %d transactions, want %d", tt.head, scheduler.Len(), tt.len)
+		}
+	}
+}
+
+func TestBundleSchedulerCancel(t *testing.T) {
+	relay := newBundleRelay(t, false, nil)
+	failing := newBundleRelay(t, true, nil)
+	scheduler := NewBundleScheduler(4, 3)
+	tx := testTx(0)
+
+	scheduler.Schedule(context.Background(), []Submitter{relay.submitter, failing.submitter}, tx, testHeader(100), 101)
+	relay.mu.Lock()
+	sent := make(map[uint64]string)
+	for block, id := range relay.sent {
+		sent[block] = id
+	}
+	relay.mu.Unlock()
+	if len(sent) != 3 || sent[101] == sent[102] || sent[102] == sent[103] {
+		t.Fatalf("sent %v, want a distinct UUID for each of the 3 target blocks", sent)
+	}
+
+	results := scheduler.Cancel(context.Background(), tx.Hash(), 101)
+	if len(results) != 2 {
+		t.Errorf("Cancel returned %d results, want one per later target block of the accepting relay", len(results))
+	}
+	for _, result := range results {
+		if result.Err != nil || result.Block <= 101 {
+			t.Errorf("cancellation %+v, want a successful one for a block after the head", result)
+		}
+	}
+	sort.Strings(relay.cancelled)
+	want := []string{sent[102], sent[103]}
+	sort.Strings(want)
+	if !reflect.DeepEqual(relay.cancelled, want) {
+		t.Errorf("cancelled %v, want the UUIDs of blocks 102 and 103 %v", relay.cancelled, want)
+	}
+	if len(failing.cancelled) != 0 {
+		t.Errorf("the relay that rejected the bundles was asked to cancel %v", failing.cancelled)
+	}
+
+	if results := scheduler.Cancel(context.Background(), tx.Hash(), 101); results != nil || scheduler.Len() != 0 {
+		t.Errorf("second Cancel = %v with %d scheduled transactions, want nothing", results, scheduler.Len())
+	}
+}
@@ -5,49 +5,39 @@
 package eth
 
 import (
-	"bytes"
 	"context"
-	"crypto/ecdsa"
-	"encoding/json"
 	"errors"
-	"fmt"
-	"io/ioutil"
 	"math/big"
-	"net/http"
-	"os"
-	"path/filepath"
 	"sync"
-	"time"
 
 	"golang.org/x/exp/rand"
 
 	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
 	gokzg4844 "github.com/crate-crypto/go-kzg-4844"
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
-	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/crypto/kzg4844"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/ethereum/go-ethereum/log"
 	"github.com/holiman/uint256"
+	"github.com/primev/preconf_blob_bidder/core/logging"
 	bb "github.com/primev/preconf_blob_bidder/core/mevcommit"
+	"github.com/primev/preconf_blob_bidder/core/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// SelfETHTransfer sends an ETH transfer to the sender's own address. This function only works with
-// public RPC endpoints and does not work with custom Titan endpoints.
+// SelfETHTransfer sends an ETH transfer to the sender's own address. The client is used to read the chain
+// state, which does not work with custom Titan endpoints, and the submitter delivers the transaction.
 //
 // Parameters:
 // - client: The Ethereum client instance.
-// - authAcct: The authenticated account struct containing the address and private key.
+// - submitter: The submitter that delivers the signed transaction.
+// - authAcct: The authenticated account struct containing the address and signer.
 // - value: The amount of ETH to transfer (in wei).
 // - gasLimit: The maximum amount of gas to use for the transaction.
 // - data: Optional data to include with the transaction.
 //
 // Returns:
 // - The transaction hash as a string, or an error if the transaction fails.
-func SelfETHTransfer(client *ethclient.Client, authAcct bb.AuthAcct, value *big.Int, gasLimit uint64, data []byte) (string, error) {
+func SelfETHTransfer(client *ethclient.Client, submitter Submitter, authAcct bb.AuthAcct, value *big.Int, gasLimit uint64, data []byte) (string, error) {
 	// Get the account's nonce
 	nonce, err := client.PendingNonceAt(context.Background(), authAcct.Address)
 	if err != nil {
@@ -84,22 +74,14 @@ func SelfETHTransfer(client *ethclient.Client, authAcct bb.AuthAcct, value *big.
 		Data:      data,
 	})
 
-	// Sign the transaction with the authenticated account's private key
-	signer := types.LatestSignerForChainID(chainID)
-	signedTx, err := types.SignTx(tx, signer, authAcct.PrivateKey)
+	// Sign the transaction with the authenticated account's signer
+	signedTx, err := authAcct.Signer.SignTx(context.Background(), tx, chainID)
 	if err != nil {
 		return "", err
 	}
 
-	// Encode the signed transaction into RLP format for transmission
-	var buf bytes.Buffer
-	err = signedTx.EncodeRLP(&buf)
-	if err != nil {
-		return "", err
-	}
-
-	// Send the signed transaction to the Ethereum network
-	err = client.SendTransaction(context.Background(), signedTx)
+	// Deliver the signed transaction through the submitter
+	_, err = submitter.Submit(context.Background(), signedTx, header.Number.Uint64()+1)
 	if err != nil {
 		return "", err
 	}
@@ -120,27 +102,28 @@ func getChainID(client *ethclient.Client, ctx context.Context) (*big.Int, error)
 	return chainID, chainIDErr
 }
 
-// ExecuteBlobTransaction sends a signed blob transaction to the network. If the private flag is set to true,
-// the transaction is sent only to the Titan endpoint. Otherwise, it is sent to the specified public RPC endpoint.
+// ExecuteBlobTransaction builds and signs a blob transaction on top of the given parent block. The transaction
+// is built once and is meant to be submitted unchanged to every endpoint, so that all of them receive the same
+// transaction for the nonce and a single preconfirmation bid covers it.
 //
 // Parameters:
-// - wsClient: The Ethereum WebSocket client instance to get the nonce.
-// - rpcEndpoint: The RPC endpoint URL to send the transaction to.
-// - authAcct: The authenticated account struct containing the address and private key.
+// - ctx: The context for the client requests, which also holds the span of the slot.
+// - client: The Ethereum client to get the chain ID, nonce and fees from.
+// - parentHeader: The header of the block the transaction is built on top of.
+// - authAcct: The authenticated account struct containing the address and signer.
 // - numBlobs: The number of blobs to include in the transaction.
+// - offset: The number of blocks after the parent block that the transaction targets.
+// - fees: The fee strategy that picks the gas tip cap and gas fee cap.
+// - blobFeeBlocks: The number of blocks the blob fee cap must keep the transaction includable for.
 //
 // Returns:
-// - The transaction hash as a string, or an error if the transaction fails.
-func ExecuteBlobTransaction(wsClient *ethclient.Client, rpcEndpoint string, parentHeader *types.Header, authAcct bb.AuthAcct, numBlobs int, offset uint64) (*types.Transaction, uint64, error) {
-	privateKey := authAcct.PrivateKey
-	publicKey := privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return nil, 0, errors.New("failed to cast public key to ECDSA")
-	}
-	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
+// - The signed transaction and the target block number, or an error if building or signing fails.
+func ExecuteBlobTransaction(ctx context.Context, client *ethclient.Client, parentHeader *types.Header, authAcct bb.AuthAcct, numBlobs int, offset uint64, fees FeeStrategy, blobFeeBlocks uint64) (_ *types.Transaction, _ uint64, err error) {
+	fromAddress := authAcct.Address
 
-	ctx := context.Background()
+	ctx, span := tracing.Start(ctx, "blobtx.build", tracing.Block(parentHeader.Number.Uint64()+offset), attribute.Int("blobs", numBlobs))
+	defer func() { tracing.End(span, err) }()
+	logger := logging.FromContext(ctx, nil).With(logging.KeyAccount, fromAddress)
 
 	var (
 		gasLimit    = uint64(500_000)
@@ -151,35 +134,37 @@ func ExecuteBlobTransaction(wsClient *ethclient.Client, rpcEndpoint string, pare
 		err1, err2  error
 	)
 
-	chainID, err := getChainID(wsClient, context.Background()) // Use WebSocket client to get chain ID
+	chainID, err := getChainID(client, ctx)
 	if err != nil {
-		log.Error("Failed to get chain ID", "client", "wsClient", "error", err)
+		logger.Error("Failed to get chain ID", logging.KeyErr, err)
 		return nil, 0, err
 	}
 
 	// Fetch various transaction parameters in parallel
+	feesCtx, feesSpan := tracing.Start(ctx, "blobtx.nonce_and_fees")
 	var wg sync.WaitGroup
 	wg.Add(2)
 
 	go func() {
 		defer wg.Done()
-		log.Info("Fetching nonce using WebSocket client", "client", "wsClient")
-		nonce, err1 = wsClient.PendingNonceAt(context.Background(), fromAddress) // Use WebSocket client for nonce
+		logger.Info("Fetching nonce")
+		nonce, err1 = client.PendingNonceAt(feesCtx, fromAddress)
 		if err1 != nil {
-			log.Error("Failed to fetch nonce", "client", "wsClient", "error", err1)
+			logger.Error("Failed to fetch nonce", logging.KeyErr, err1)
 		}
 	}()
 
 	go func() {
 		defer wg.Done()
-		log.Info("Suggesting gas tip and fee cap using WebSocket client", "client", "wsClient")
-		gasTipCap, gasFeeCap, err2 = suggestGasTipAndFeeCap(wsClient, ctx) // Use WebSocket client for gas tips
+		logger.Info("Suggesting gas tip and fee cap")
+		gasTipCap, gasFeeCap, err2 = GasFees(feesCtx, fees, client, parentHeader)
 		if err2 != nil {
-			log.Error("Failed to suggest gas tip and fee cap", "client", "wsClient", "error", err2)
+			logger.Error("Failed to suggest gas tip and fee cap", logging.KeyErr, err2)
 		}
 	}()
 
 	wg.Wait()
+	tracing.End(feesSpan, errors.Join(err1, err2))
 	if err1 != nil {
 		return nil, 0, err1
 	}
@@ -187,49 +172,25 @@ func ExecuteBlobTransaction(wsClient *ethclient.Client, rpcEndpoint string, pare
 		return nil, 0, err2
 	}
 
-	log.Info("account nonce tracker", "nonce", nonce)
+	logger.Info("account nonce tracker", "nonce", nonce)
 	blockNumber = parentHeader.Number.Uint64()
 
-	// Calculate the blob fee cap and ensure it is sufficient for transaction replacement
-	parentExcessBlobGas := eip4844.CalcExcessBlobGas(*parentHeader.ExcessBlobGas, *parentHeader.BlobGasUsed)
-	blobFeeCap := eip4844.CalcBlobFee(parentExcessBlobGas)
-	blobFeeCap.Add(blobFeeCap, big.NewInt(1)) // Ensure it's at least 1 unit higher to replace a transaction
+	// Set the blob fee cap to the worst-case blob base fee over the blocks the transaction must stay valid for
+	blobFeeCap := ProjectBlobBaseFee(parentHeader, blobFeeBlocks)
 
 	// Generate random blobs and their corresponding sidecar
+	_, kzgSpan := tracing.Start(ctx, "blobtx.kzg")
 	blobs := randBlobs(numBlobs)
 	sideCar := makeSidecar(blobs)
 	blobHashes := sideCar.BlobHashes()
-
-	// Incrementally increase blob fee cap for replacement
-	incrementFactor := big.NewInt(110) // 10% increase
-	blobFeeCap.Mul(blobFeeCap, incrementFactor).Div(blobFeeCap, big.NewInt(100))
-
-	// Randomize the priority fee increment between 2 gwei and 20 gwei
-	rand.Seed(uint64(time.Now().UnixNano()))
-	priorityFeeIncrement := big.NewInt(int64(rand.Intn(30) + 60))   // Random value between 2 and 200
-	priorityFeeIncrement.Mul(priorityFeeIncrement, big.NewInt(1e7)) // Convert to 1e7 wei
-
-	gasTipCapAdjusted := new(big.Int).Add(gasTipCap, priorityFeeIncrement)
-	gasTipCapAdjusted.Mul(gasTipCapAdjusted, big.NewInt(int64(numBlobs)))
-
-	// Ensure gasTipCapAdjusted doesn't exceed your max intended value (10 gwei)
-	maxPriorityFee := big.NewInt(int64(10 * 1e9))
-	if gasTipCapAdjusted.Cmp(maxPriorityFee) > 0 {
-		gasTipCapAdjusted.Set(maxPriorityFee)
-	}
-
-	// Ensure GasFeeCap is higher than GasTipCap
-	gasFeeCapAdjusted := new(big.Int).Mul(gasTipCapAdjusted, big.NewInt(2))
-	if gasFeeCap.Cmp(gasFeeCapAdjusted) <= 0 {
-		gasFeeCapAdjusted.Add(gasFeeCapAdjusted, big.NewInt(1)) // Ensure it's higher
-	}
+	kzgSpan.End()
 
 	// Create a new BlobTx transaction
 	tx := types.NewTx(&types.BlobTx{
 		ChainID:    uint256.MustFromBig(chainID),
 		Nonce:      nonce,
-		GasTipCap:  uint256.MustFromBig(gasTipCapAdjusted),
-		GasFeeCap:  uint256.MustFromBig(gasFeeCapAdjusted),
+		GasTipCap:  uint256.MustFromBig(gasTipCap),
+		GasFeeCap:  uint256.MustFromBig(gasFeeCap),
 		Gas:        gasLimit,
 		To:         fromAddress,
 		BlobFeeCap: uint256.MustFromBig(blobFeeCap),
@@ -237,258 +198,19 @@ func ExecuteBlobTransaction(wsClient *ethclient.Client, rpcEndpoint string, pare
 		Sidecar:    sideCar,
 	})
 
-	// Sign the transaction with the authenticated account's private key
-	auth, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
-	if err != nil {
-		log.Error("Failed to create keyed transactor", "error", err)
-		return nil, 0, err
-	}
-
-	signedTx, err := auth.Signer(auth.From, tx)
+	// Sign the transaction with the authenticated account's signer
+	signCtx, signSpan := tracing.Start(ctx, "blobtx.sign")
+	signedTx, err := authAcct.Signer.SignTx(signCtx, tx, chainID)
+	tracing.End(signSpan, err)
 	if err != nil {
-		log.Error("Failed to sign transaction", "error", err)
+		logger.Error("Failed to sign transaction", logging.KeyErr, err)
 		return nil, 0, err
 	}
-
-	// TODO - Retry attempts should be handled outside of the function.
-	// retryAttempts := 5
-	// for i := 0; i < retryAttempts; i++ {
-	// 	log.Info("Attempting to send transaction", "attempt", i+1, "rpcEndpoint", rpcEndpoint)
-	// 	_, err = sendBundle(rpcEndpoint, signedTx, blockNumber+offset)
-	// 	if err != nil {
-	// 		log.Error("Failed to send transaction", "attempt", i+1, "rpcEndpoint", rpcEndpoint, "error", err)
-	// 	}
-
-	// 	if err != nil && strings.Contains(err.Error(), "replacement transaction underpriced") {
-	// 		// Increment gas fee cap slightly and try again
-	// 		incrementFactor := big.NewInt(105) // 105% increase
-	// 		gasFeeCapAdjusted.Mul(gasFeeCapAdjusted, incrementFactor).Div(gasFeeCapAdjusted, big.NewInt(100))
-
-	// 		// Recreate and sign the transaction with updated gasFeeCap
-	// 		tx = types.NewTx(&types.BlobTx{
-	// 			ChainID:    uint256.MustFromBig(chainID),
-	// 			Nonce:      nonce,
-	// 			GasTipCap:  uint256.MustFromBig(gasTipCapAdjusted),
-	// 			GasFeeCap:  uint256.MustFromBig(gasFeeCapAdjusted),
-	// 			Gas:        gasLimit,
-	// 			To:         fromAddress,
-	// 			BlobFeeCap: uint256.MustFromBig(blobFeeCap),
-	// 			BlobHashes: blobHashes,
-	// 			Sidecar:    sideCar,
-	// 		})
-	// 		signedTx, err = auth.Signer(auth.From, tx)
-	// 		if err != nil {
-	// 			log.Error("Failed to sign transaction after adjusting gas fee cap", "error", err)
-	// 			return nil, 0, err
-	// 		}
-	// 	} else if err == nil {
-	// 		log.Info("Transaction sent successfully", "attempt", i+1, "rpcEndpoint", rpcEndpoint)
-	// 		break
-	// 	}
-	// }
-
-	// if err != nil {
-	// 	log.Error("Failed to replace transaction after multiple attempts", "attempts", retryAttempts, "rpcEndpoint", rpcEndpoint, "error", err)
-	// 	return nil, 0, fmt.Errorf("failed to replace transaction after %d attempts: %v", retryAttempts, err)
-	// }
-
-	// // Record the transaction parameters and save them asynchronously
-	// currentTimeMillis := time.Now().UnixNano() / int64(time.Millisecond)
-
-	// transactionParameters := map[string]interface{}{
-	// 	"hash":          signedTx.Hash().String(),
-	// 	"chainID":       signedTx.ChainId(),
-	// 	"nonce":         signedTx.Nonce(),
-	// 	"gasTipCap":     signedTx.GasTipCap(),
-	// 	"gasFeeCap":     signedTx.GasFeeCap(),
-	// 	"gasLimit":      signedTx.Gas(),
-	// 	"to":            signedTx.To(),
-	// 	"blobFeeCap":    signedTx.BlobGasFeeCap(),
-	// 	"blobHashes":    signedTx.BlobHashes(),
-	// 	"timeSubmitted": currentTimeMillis,
-	// 	"numBlobs":      numBlobs,
-	// }
-
-	// go saveTransactionParameters("data/blobs.json", transactionParameters) // Asynchronous saving
+	span.SetAttributes(tracing.TxHash(signedTx.Hash()))
 
 	return signedTx, blockNumber + offset, nil
 }
 
-// suggestGasTipAndFeeCap suggests a gas tip cap and gas fee cap for a transaction, ensuring that the values
-// are sufficient for timely inclusion in the next block.
-//
-// Parameters:
-// - client: The Ethereum client instance.
-// - ctx: The context for making requests to the Ethereum client.
-//
-// Returns:
-// - The suggested gas tip cap and gas fee cap as big.Int pointers, or an error if the suggestions fail.
-//func suggestGasTipAndFeeCap(client *ethclient.Client, ctx context.Context) (*big.Int, *big.Int, error) {
-//	gasTipCap, err := client.SuggestGasTipCap(ctx)
-//	if err != nil {
-//		return nil, nil, err
-//	}
-//
-//	minGasTipCap := big.NewInt(1000000000) // 1 Gwei minimum gas tip cap
-//	if gasTipCap.Cmp(minGasTipCap) < 0 {
-//		gasTipCap = minGasTipCap
-//	}
-//
-//	gasFeeCap, err := client.SuggestGasPrice(ctx)
-//	if err != nil {
-//		return nil, nil, err
-//	}
-//
-//	buffer := big.NewInt(1000000000) // 1 Gwei buffer to ensure gas fee cap is higher than gas tip cap
-//	if gasFeeCap.Cmp(new(big.Int).Add(gasTipCap, buffer)) < 0 {
-//		gasFeeCap = new(big.Int).Add(gasTipCap, buffer)
-//	}
-//
-//	return gasTipCap, gasFeeCap, nil
-//}
-
-// suggestGasTipAndFeeCap suggests a gas tip cap and gas fee cap for a transaction, ensuring that the values
-// are sufficient for timely inclusion in the next block.
-//
-// Parameters:
-// - client: The Ethereum client instance.
-// - ctx: The context for making requests to the Ethereum client.
-//
-// Returns:
-// - The suggested gas tip cap and gas fee cap as big.Int pointers, or an error if the suggestions fail.
-func suggestGasTipAndFeeCap(client *ethclient.Client, ctx context.Context) (*big.Int, *big.Int, error) {
-	var (
-		gasTipCap, gasFeeCap *big.Int
-		err1, err2           error
-	)
-
-	var wg sync.WaitGroup
-	wg.Add(2)
-
-	go func() {
-		defer wg.Done()
-		gasTipCap, err1 = client.SuggestGasTipCap(ctx)
-	}()
-
-	go func() {
-		defer wg.Done()
-		gasFeeCap, err2 = client.SuggestGasPrice(ctx)
-	}()
-
-	wg.Wait()
-
-	if err1 != nil {
-		return nil, nil, err1
-	}
-	if err2 != nil {
-		return nil, nil, err2
-	}
-
-	return gasTipCap, gasFeeCap, nil
-}
-
-// sendPrivateRawTransaction sends a signed transaction directly to the Titan endpoint as a private transaction.
-//
-// Parameters:
-// - rpcEndpoint: The RPC endpoint URL to send the transaction to.
-// - signedTx: The signed transaction to be sent.
-//
-// Returns:
-// - An error if the transaction fails to send.
-func sendPrivateRawTransaction(rpcEndpoint string, signedTx *types.Transaction) error {
-	// Marshal the signed transaction to binary format
-	binary, err := signedTx.MarshalBinary()
-	if err != nil {
-		log.Error("Error marshaling transaction", "error", err)
-		return fmt.Errorf("error marshaling transaction: %v", err)
-	}
-
-	// Prepare the JSON-RPC payload
-	method := "POST"
-	payload := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      1,
-		"method":  "eth_sendPrivateRawTransaction",
-		"params": []string{
-			"0x" + common.Bytes2Hex(binary),
-		},
-	}
-
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		log.Error("Error marshaling payload", "error", err)
-		return fmt.Errorf("error marshaling payload: %v", err)
-	}
-
-	// Send the HTTP request to the Titan endpoint
-	httpClient := &http.Client{}
-	req, err := http.NewRequest(method, rpcEndpoint, bytes.NewBuffer(payloadBytes))
-	if err != nil {
-		log.Error("Error creating request", "error", err)
-		return fmt.Errorf("error creating request: %v", err)
-	}
-	req.Header.Add("Content-Type", "application/json")
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		log.Error("Error sending request", "error", err)
-		return fmt.Errorf("error sending request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Read and log the response from the Titan endpoint
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Error("Error reading response body", "error", err)
-		return fmt.Errorf("error reading response body: %v", err)
-	}
-	log.Info("Response private transaction", "body", string(body))
-
-	return nil
-}
-
-// saveTransactionParameters saves transaction parameters to a JSON file, appending them to an existing array of transactions.
-//
-// Parameters:
-// - filename: The name of the JSON file to save the transaction parameters to.
-// - params: The transaction parameters to save as a map of string keys to interface{} values.
-func saveTransactionParameters(filename string, params map[string]interface{}) {
-	// Ensure the directory exists
-	dir := filepath.Dir(filename)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		log.Error("Failed to create directory", "directory", dir, "error", err)
-		return
-	}
-
-	var transactions []map[string]interface{}
-
-	// Open the file and decode any existing transactions
-	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
-	if err != nil {
-		log.Error("Failed to open file", "filename", filename, "error", err)
-		return
-	}
-	defer file.Close()
-
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&transactions); err != nil && err.Error() != "EOF" {
-		log.Error("Failed to decode existing JSON data", "error", err)
-		return
-	}
-
-	// Append the new transaction parameters
-	transactions = append(transactions, params)
-
-	// Write the updated transactions array to the file
-	file.Seek(0, 0)  // Move to the beginning of the file
-	file.Truncate(0) // Clear the file content
-
-	encoder := json.NewEncoder(file)
-	if err := encoder.Encode(transactions); err != nil {
-		log.Error("Failed to encode parameters to JSON", "error", err)
-	}
-}
-
 // makeSidecar creates a sidecar for the given blobs, including commitments and proofs.
 //
 // Parameters:
@@ -0,0 +1,184 @@
+package eth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Reasons a simulated bundle is refused before submission.
+var (
+	ErrBundleReverted  = errors.New("bundle reverts in simulation")
+	ErrBundleUnderpaid = errors.New("bundle pays too little to the builder")
+)
+
+// TxSimulation is the simulated outcome of one transaction of a bundle.
+type TxSimulation struct {
+	TxHash       common.Hash // The hash of the transaction.
+	GasUsed      uint64      // The execution gas used by the transaction.
+	CoinbaseDiff *big.Int    // The amount the transaction paid to the block's fee recipient.
+	Error        string      // The execution error, empty if the transaction succeeded.
+	Revert       string      // The revert reason, if any.
+}
+
+// BundleSimulation is the outcome of eth_callBundle.
+type BundleSimulation struct {
+	BundleHash   string         // The bundle hash reported by the simulation endpoint.
+	GasUsed      uint64         // The execution gas used by the whole bundle.
+	CoinbaseDiff *big.Int       // The amount the whole bundle paid to the block's fee recipient.
+	Results      []TxSimulation // The outcome of each transaction, in bundle order.
+}
+
+// Reverted reports whether any transaction of the bundle failed.
+func (s *BundleSimulation) Reverted() bool {
+	for _, result := range s.Results {
+		if result.Error != "" || result.Revert != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Check refuses bundles that revert or pay the builder less than minCoinbaseDiff.
+//
+// Parameters:
+// - minCoinbaseDiff: The smallest acceptable payment to the fee recipient in wei. Nil disables the check.
+//
+// Returns:
+// - nil if the bundle may be submitted, or an error wrapping ErrBundleReverted or ErrBundleUnderpaid.
+func (s *BundleSimulation) Check(minCoinbaseDiff *big.Int) error {
+	for _, result := range s.Results {
+		if result.Error != "" || result.Revert != "" {
+			return fmt.Errorf("%w: tx %s: %s %s", ErrBundleReverted, result.TxHash, result.Error, result.Revert)
+		}
+	}
+	if minCoinbaseDiff != nil && s.CoinbaseDiff.Cmp(minCoinbaseDiff) < 0 {
+		return fmt.Errorf("%w: coinbase diff %s wei, need %s wei", ErrBundleUnderpaid, s.CoinbaseDiff, minCoinbaseDiff)
+	}
+	return nil
+}
+
+// RepricePercent returns the percentage the fees of the bundle must be scaled by, with ScaledFeeStrategy,
+// for its payment to the builder to reach minCoinbaseDiff. Blob fees are burned, so the payment grows
+// linearly with the tip. It returns 0 if the bundle paid nothing and cannot be repriced by scaling.
+func (s *BundleSimulation) RepricePercent(minCoinbaseDiff *big.Int) int64 {
+	if s.CoinbaseDiff.Sign() <= 0 {
+		return 0
+	}
+	percent := new(big.Int).Mul(minCoinbaseDiff, big.NewInt(100))
+	percent.Add(percent, new(big.Int).Sub(s.CoinbaseDiff, common.Big1))
+	percent.Div(percent, s.CoinbaseDiff)
+	if !percent.IsInt64() {
+		return 0
+	}
+	return max(percent.Int64(), 100)
+}
+
+// callBundleArgs is the eth_callBundle request object.
+type callBundleArgs struct {
+	Txs              []string `json:"txs"`
+	BlockNumber      string   `json:"blockNumber"`
+	StateBlockNumber string   `json:"stateBlockNumber"`
+}
+
+// callBundleResult is the eth_callBundle response object. Amounts are decimal strings.
+type callBundleResult struct {
+	BundleHash   string `json:"bundleHash"`
+	CoinbaseDiff string `json:"coinbaseDiff"`
+	TotalGasUsed uint64 `json:"totalGasUsed"`
+	Results      []struct {
+		TxHash       common.Hash `json:"txHash"`
+		GasUsed      uint64      `json:"gasUsed"`
+		CoinbaseDiff string      `json:"coinbaseDiff"`
+		Error        string      `json:"error"`
+		Revert       string      `json:"revert"`
+	} `json:"results"`
+}
+
+// BundleSimulator simulates bundles through eth_callBundle before they are submitted.
+type BundleSimulator struct {
+	url        string
+	signingKey *ecdsa.PrivateKey
+}
+
+// NewBundleSimulator creates a simulator for a relay or builder that supports eth_callBundle.
+//
+// Parameters:
+// - url: The simulation endpoint.
+// - signingKey: The searcher reputation key used to sign requests. Nil disables signing.
+//
+// Returns:
+// - A pointer to a BundleSimulator.
+func NewBundleSimulator(url string, signingKey *ecdsa.PrivateKey) *BundleSimulator {
+	return &BundleSimulator{url: url, signingKey: signingKey}
+}
+
+// CallBundle simulates a bundle targeting blockNumber on top of the latest state.
+//
+// Parameters:
+// - ctx: The context for the request.
+// - txs: The signed transactions of the bundle, in execution order.
+// - blockNumber: The block number the bundle targets.
+//
+// Returns:
+// - The decoded simulation, or an error if the request fails or the response cannot be decoded.
+func (s *BundleSimulator) CallBundle(ctx context.Context, txs []*types.Transaction, blockNumber uint64) (*BundleSimulation, error) {
+	args := callBundleArgs{
+		BlockNumber:      hexutil.EncodeUint64(blockNumber),
+		StateBlockNumber: "latest",
+	}
+	for _, tx := range txs {
+		binary, err := tx.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		args.Txs = append(args.Txs, hexutil.Encode(binary))
+	}
+
+	raw, err := callJSONRPC(ctx, s.url, "eth_callBundle", []callBundleArgs{args}, s.signingKey)
+	if err != nil {
+		return nil, err
+	}
+	var result callBundleResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedJSONRPCPayload, err)
+	}
+
+	sim := &BundleSimulation{BundleHash: result.BundleHash, GasUsed: result.TotalGasUsed}
+	if sim.CoinbaseDiff, err = parseAmount(result.CoinbaseDiff); err != nil {
+		return nil, err
+	}
+	for _, r := range result.Results {
+		diff, err := parseAmount(r.CoinbaseDiff)
+		if err != nil {
+			return nil, err
+		}
+		sim.Results = append(sim.Results, TxSimulation{TxHash: r.TxHash, GasUsed: r.GasUsed, CoinbaseDiff: diff, Error: r.Error, Revert: r.Revert})
+	}
+	return sim, nil
+}
+
+// parseAmount parses a wei amount written in decimal or as 0x-prefixed hex. An empty amount is zero.
+// Leading zeros of a decimal amount do not make it octal.
+func parseAmount(amount string) (*big.Int, error) {
+	if amount == "" {
+		return new(big.Int), nil
+	}
+	digits, base := amount, 10
+	if strings.HasPrefix(amount, "0x") || strings.HasPrefix(amount, "0X") {
+		digits, base = amount[2:], 16
+	}
+	value, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid amount %q", ErrMalformedJSONRPCPayload, amount)
+	}
+	return value, nil
+}
@@ -0,0 +1,177 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestCallBundle(t *testing.T) {
+	tx := types.NewTx(&types.DynamicFeeTx{Nonce: 7, Gas: 21000, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(10)})
+	txHash := tx.Hash()
+
+	tests := []struct {
+		name        string
+		result      string
+		minDiff     *big.Int
+		wantDiff    *big.Int
+		wantCheck   error
+		wantReprice int64
+	}{
+		{
+			name:      "success",
+			result:    fmt.Sprintf(`{"bundleHash":"0xb1","coinbaseDiff":"2100000","totalGasUsed":21000,"results":[{"txHash":"%s","gasUsed":21000,"coinbaseDiff":"2100000"}]}`, txHash),
+			minDiff:   big.NewInt(1000000),
+			wantDiff:  big.NewInt(2100000),
+			wantCheck: nil,
+		},
+		{
+			name:      "reverted",
+			result:    fmt.Sprintf(`{"bundleHash":"0xb1","coinbaseDiff":"0","totalGasUsed":30000,"results":[{"txHash":"%s","gasUsed":30000,"coinbaseDiff":"0","error":"execution reverted","revert":"too late"}]}`, txHash),
+			wantDiff:  new(big.Int),
+			wantCheck: ErrBundleReverted,
+		},
+		{
+			name:        "underpaid",
+			result:      fmt.Sprintf(`{"bundleHash":"0xb1","coinbaseDiff":"0400","totalGasUsed":21000,"results":[{"txHash":"%s","gasUsed":21000,"coinbaseDiff":"0400"}]}`, txHash),
+			minDiff:     big.NewInt(1000),
+			wantDiff:    big.NewInt(400),
+			wantCheck:   ErrBundleUnderpaid,
+			wantReprice: 250,
+		},
+		{
+			name:        "hex amount",
+			result:      fmt.Sprintf(`{"bundleHash":"0xb1","coinbaseDiff":"0x64","totalGasUsed":21000,"results":[{"txHash":"%s","gasUsed":21000,"coinbaseDiff":"0x64"}]}`, txHash),
+			minDiff:     big.NewInt(101),
+			wantDiff:    big.NewInt(100),
+			wantCheck:   ErrBundleUnderpaid,
+			wantReprice: 101,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newRPCStub(t, func(method string, params []json.RawMessage) (interface{}, error) {
+				if method != "eth_callBundle" {
+					return nil, fmt.Errorf("unexpected method %s", method)
+				}
+				var arg callBundleArgs
+				if err := json.Unmarshal(params[0], &arg); err != nil {
+					return nil, err
+				}
+				if arg.BlockNumber != hexutil.EncodeUint64(100) || len(arg.Txs) != 1 {
+					return nil, fmt.Errorf("unexpected args %+v", arg)
+				}
+				return json.RawMessage(tt.result), nil
+			})
+
+			sim, err := NewBundleSimulator(server.URL, nil).CallBundle(context.Background(), []*types.Transaction{tx}, 100)
+			if err != nil {
+				t.Fatalf("CallBundle: %v", err)
+			}
+			if sim.CoinbaseDiff.Cmp(tt.wantDiff) != 0 {
+				t.Errorf("coinbase diff = %s, want %s", sim.CoinbaseDiff, tt.wantDiff)
+			}
+			if len(sim.Results) != 1 || sim.Results[0].TxHash != txHash {
+				t.Fatalf("results = %+v, want one result for %s", sim.Results, txHash)
+			}
+			if got := sim.Reverted(); got != (tt.wantCheck == ErrBundleReverted) {
+				t.Errorf("Reverted() = %v", got)
+			}
+			if err := sim.Check(tt.minDiff); !errors.Is(err, tt.wantCheck) {
+				t.Errorf("Check() = %v, want %v", err, tt.wantCheck)
+			}
+			if tt.wantReprice != 0 {
+				if got := sim.RepricePercent(tt.minDiff); got != tt.wantReprice {
+					t.Errorf("RepricePercent() = %d, want %d", got, tt.wantReprice)
+				}
+			}
+		})
+	}
+}
+
+func TestCallBundleErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  func(t *testing.T) string
+		wantErr error
+	}{
+		{
+			name: "json-rpc error",
+			server: func(t *testing.T) string {
+				return newRPCStub(t, func(string, []json.RawMessage) (interface{}, error) {
+					return nil, &RPCError{Code: -32601, Message: "the method eth_callBundle does not exist/is not available"}
+				}).URL
+			},
+			wantErr: ErrUnknownMethod,
+		},
+		{
+			name: "malformed payload",
+			server: func(t *testing.T) string {
+				return newRawStub(t, http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":`).URL
+			},
+			wantErr: ErrMalformedJSONRPCPayload,
+		},
+		{
+			name: "malformed result",
+			server: func(t *testing.T) string {
+				return newRPCStub(t, func(string, []json.RawMessage) (interface{}, error) {
+					return "not a bundle", nil
+				}).URL
+			},
+			wantErr: ErrMalformedJSONRPCPayload,
+		},
+		{
+			name: "malformed amount",
+			server: func(t *testing.T) string {
+				return newRPCStub(t, func(string, []json.RawMessage) (interface{}, error) {
+					return json.RawMessage(`{"coinbaseDiff":"12e3","results":[]}`), nil
+				}).URL
+			},
+			wantErr: ErrMalformedJSONRPCPayload,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim, err := NewBundleSimulator(tt.server(t), nil).CallBundle(context.Background(), nil, 1)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CallBundle() = %+v, %v, want %v", sim, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		amount string
+		want   int64
+	}{
+		{"", 0},
+		{"0", 0},
+		{"123", 123},
+		{"0123", 123},
+		{"0x7b", 123},
+		{"0X7B", 123},
+	}
+	for _, tt := range tests {
+		got, err := parseAmount(tt.amount)
+		if err != nil {
+			t.Errorf("parseAmount(%q): %v", tt.amount, err)
+			continue
+		}
+		if got.Cmp(big.NewInt(tt.want)) != 0 {
+			t.Errorf("parseAmount(%q) = %s, want %d", tt.amount, got, tt.want)
+		}
+	}
+	for _, amount := range []string{"0x", "12.5", "-", "0b101"} {
+		if _, err := parseAmount(amount); !errors.Is(err, ErrMalformedJSONRPCPayload) {
+			t.Errorf("parseAmount(%q) error = %v, want %v", amount, err, ErrMalformedJSONRPCPayload)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package eth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/primev/preconf_blob_bidder/core/tracing"
+)
+
+// Submission methods that an endpoint can be configured with.
+const (
+	MethodRawTx     = "raw"     // Public mempool through eth_sendRawTransaction.
+	MethodBundle    = "bundle"  // Flashbots-style bundles through eth_sendBundle.
+	MethodPrivateTx = "private" // Titan private transactions through eth_sendPrivateRawTransaction.
+)
+
+// Submitter delivers signed transactions to a single endpoint with a single method.
+type Submitter interface {
+	// Endpoint returns the URL of the endpoint.
+	Endpoint() string
+	// Method returns the submission method of the endpoint.
+	Method() string
+	// Submit delivers signedTx, aiming for inclusion in blockNumber where the method supports it, and returns
+	// the raw JSON-RPC result. Errors reported by the endpoint are returned as *RPCError.
+	Submit(ctx context.Context, signedTx *types.Transaction, blockNumber uint64) (string, error)
+}
+
+// NewSubmitter creates the submitter for the given method and endpoint.
+//
+// Parameters:
+// - method: One of MethodRawTx, MethodBundle or MethodPrivateTx.
+// - url: The endpoint to submit to.
+// - bundleOptions: The signing key and validity window of bundles, used by the bundle method.
+//
+// Returns:
+// - The Submitter, or an error if the method is unknown.
+func NewSubmitter(method, url string, bundleOptions BundleOptions) (Submitter, error) {
+	switch method {
+	case MethodRawTx:
+		return NewRawTxSubmitter(url), nil
+	case MethodBundle:
+		return NewBundleSubmitter(url, bundleOptions), nil
+	case MethodPrivateTx:
+		return NewPrivateTxSubmitter(url), nil
+	default:
+		return nil, fmt.Errorf("unknown submission method %q", method)
+	}
+}
+
+// ParseSubmitter creates a submitter from an endpoint spec of the form "method=url" or "url".
+//
+// Parameters:
+// - spec: The endpoint spec.
+// - defaultMethod: The method used when the spec does not name one.
+// - bundleOptions: The signing key and validity window of bundles, used by the bundle method.
+//
+// Returns:
+// - The Submitter, or an error if the spec is invalid.
+func ParseSubmitter(spec, defaultMethod string, bundleOptions BundleOptions) (Submitter, error) {
+	method, url := defaultMethod, strings.TrimSpace(spec)
+	if name, rest, ok := strings.Cut(url, "="); ok && !strings.Contains(name, "/") {
+		method, url = name, rest
+	}
+	if url == "" {
+		return nil, fmt.Errorf("missing url in endpoint %q", spec)
+	}
+	return NewSubmitter(method, url, bundleOptions)
+}
+
+// SubmitResult is the outcome of submitting a transaction to one endpoint.
+type SubmitResult struct {
+	Endpoint string      // The endpoint the transaction was sent to.
+	Method   string      // The submission method used.
+	TxHash   common.Hash // The hash of the submitted transaction.
+	Block    uint64      // The block the submission targeted.
+	Response string      // The raw JSON-RPC result of the endpoint.
+	Err      error       // The error returned while submitting, if any.
+}
+
+// Broadcast submits the same signed transaction through every submitter in parallel.
+//
+// Parameters:
+// - ctx: The context for the submissions.
+// - submitters: The endpoints to submit to.
+// - signedTx: The signed transaction.
+// - blockNumber: The block number the transaction targets.
+//
+// Returns:
+// - One SubmitResult per submitter, in the order of submitters.
+func Broadcast(ctx context.Context, submitters []Submitter, signedTx *types.Transaction, blockNumber uint64) []SubmitResult {
+	ctx, span := tracing.Start(ctx, "broadcast", tracing.TxHash(signedTx.Hash()), tracing.Block(blockNumber))
+	defer span.End()
+
+	results := make([]SubmitResult, len(submitters))
+
+	var wg sync.WaitGroup
+	for i, submitter := range submitters {
+		wg.Add(1)
+		go func(i int, submitter Submitter) {
+			defer wg.Done()
+			response, err := submitter.Submit(ctx, signedTx, blockNumber)
+			results[i] = SubmitResult{Endpoint: submitter.Endpoint(), Method: submitter.Method(), TxHash: signedTx.Hash(), Block: blockNumber, Response: response, Err: err}
+		}(i, submitter)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// RawTxSubmitter sends transactions to the public mempool through eth_sendRawTransaction.
+type RawTxSubmitter struct {
+	url string
+}
+
+// NewRawTxSubmitter creates a submitter for a public RPC endpoint.
+func NewRawTxSubmitter(url string) *RawTxSubmitter {
+	return &RawTxSubmitter{url: url}
+}
+
+// Endpoint implements Submitter.
+func (s *RawTxSubmitter) Endpoint() string { return s.url }
+
+// Method implements Submitter.
+func (s *RawTxSubmitter) Method() string { return MethodRawTx }
+
+// Submit sends signedTx to the mempool. The target block is ignored.
+func (s *RawTxSubmitter) Submit(ctx context.Context, signedTx *types.Transaction, _ uint64) (string, error) {
+	return sendRawTransaction(ctx, s.url, "eth_sendRawTransaction", signedTx)
+}
+
+// PrivateTxSubmitter sends transactions directly to the Titan endpoint as private transactions.
+type PrivateTxSubmitter struct {
+	url string
+}
+
+// NewPrivateTxSubmitter creates a submitter for a Titan private transaction endpoint.
+func NewPrivateTxSubmitter(url string) *PrivateTxSubmitter {
+	return &PrivateTxSubmitter{url: url}
+}
+
+// Endpoint implements Submitter.
+func (s *PrivateTxSubmitter) Endpoint() string { return s.url }
+
+// Method implements Submitter.
+func (s *PrivateTxSubmitter) Method() string { return MethodPrivateTx }
+
+// Submit sends signedTx through eth_sendPrivateRawTransaction. The target block is ignored.
+func (s *PrivateTxSubmitter) Submit(ctx context.Context, signedTx *types.Transaction, _ uint64) (string, error) {
+	return sendRawTransaction(ctx, s.url, "eth_sendPrivateRawTransaction", signedTx)
+}
+
+// sendRawTransaction sends a signed transaction with a method that takes the raw transaction as only parameter.
+func sendRawTransaction(ctx context.Context, url, method string, signedTx *types.Transaction) (string, error) {
+	binary, err := signedTx.MarshalBinary()
+	if err != nil {
+		return "", fmt.Errorf("error marshaling transaction: %w", err)
+	}
+
+	result, err := callJSONRPC(ctx, url, method, []string{hexutil.Encode(binary)}, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
@@ -1,11 +1,9 @@
 package eth
 
-// CURRENTLY THIS FILE IS NOT BEING USED FOR ANYTHING.
 import (
 	"context"
-	"encoding/json"
-	"flag"
-	"io/ioutil"
+	"errors"
+	"fmt"
 	"math/big"
 	"os"
 	"path/filepath"
@@ -17,18 +15,21 @@ import (
 	gethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
-	"github.com/rs/zerolog/log"
+	"github.com/primev/preconf_blob_bidder/core/datafile"
+	"github.com/primev/preconf_blob_bidder/core/logging"
 )
 
 var (
-	executionEndpoint = flag.String("execution-endpoint", "ws://localhost:8546", "Path to RPC endpoint for execution client.")
-	dataFolder        = "data"
-	txDataFile        = "tx_data.json"
-	blockDataFile     = "block_data.json"
-	txMetricsFile     = "tx_metrics.json"
-	txInclusionFile   = "tx_inclusion.json"
+	dataFolder      = "data"
+	txDataFile      = "tx_data.json"
+	blockDataFile   = "block_data.json"
+	txMetricsFile   = "tx_metrics.json"
+	txInclusionFile = "tx_inclusion.json"
+	reorgFile       = "reorgs.json"
+	monitorWindow   = uint64(64)
 )
 
 type TxData struct {
@@ -59,84 +60,141 @@ type TxMetricsData struct {
 }
 
 type TxInclusionData struct {
+	TxHash         common.Hash
+	BlockNumber    uint64
+	BlockHash      common.Hash
 	Account        common.Address
 	BlobCount      int
 	InclusionDelay float64
 	GasTipGwei     float64
 }
 
-func main() {
-	flag.Parse()
-	log.Info().Msgf("Using RPC endpoint of %s", *executionEndpoint)
+type ReorgData struct {
+	Ancestor      uint64
+	OldHead       common.Hash
+	NewHead       common.Hash
+	NewHeadNumber uint64
+	DroppedBlocks []common.Hash
+	RevertedTxs   []common.Hash
+	Time          string
+}
+
+// includedTx is an included transaction kept while its block may still be reorged out.
+type includedTx struct {
+	tx     *gethtypes.Transaction
+	seenAt time.Time
+	block  uint64
+}
+
+// Monitor watches the blob transactions of the public mempool and the blocks that include them, and
+// records the transactions, blocks, inclusion delays and reorgs as JSON files in the data folder. It
+// returns when ctx is cancelled or one of its subscriptions fails, after writing every data file.
+//
+// Parameters:
+// - ctx: The context for the subscriptions and requests. Cancelling it stops the monitor.
+// - endpoint: The WebSocket endpoint of an execution client that serves full pending transactions.
+//
+// Returns:
+// - An error if the data files cannot be loaded, the endpoint cannot be reached or a subscription fails, nil when
+// ctx was cancelled.
+func Monitor(ctx context.Context, endpoint string) error {
+	// The records of earlier runs are kept and extended
+	if err := createDataFolder(); err != nil {
+		return err
+	}
+	var (
+		txDataList      []TxData
+		blockDataList   []BlockData
+		txMetricsList   []TxMetricsData
+		txInclusionList []TxInclusionData
+		reorgList       []ReorgData
+	)
+	if err := errors.Join(
+		loadDataFromFile(filepath.Join(dataFolder, txDataFile), &txDataList),
+		loadDataFromFile(filepath.Join(dataFolder, blockDataFile), &blockDataList),
+		loadDataFromFile(filepath.Join(dataFolder, txMetricsFile), &txMetricsList),
+		loadDataFromFile(filepath.Join(dataFolder, txInclusionFile), &txInclusionList),
+		loadDataFromFile(filepath.Join(dataFolder, reorgFile), &reorgList),
+	); err != nil {
+		return err
+	}
+
+	log.Info("using rpc endpoint", logging.KeyEndpoint, endpoint)
 
-	client, err := rpc.DialWebsocket(context.Background(), *executionEndpoint, "")
+	client, err := rpc.DialWebsocket(ctx, endpoint, "")
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to dial websocket")
+		return fmt.Errorf("failed to dial websocket: %w", err)
 	}
 
 	ec := ethclient.NewClient(client)
 	gc := gethclient.New(client)
 
 	txChan := make(chan *gethtypes.Transaction, 100)
-	pSub, err := gc.SubscribeFullPendingTransactions(context.Background(), txChan)
+	pSub, err := gc.SubscribeFullPendingTransactions(ctx, txChan)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to subscribe to full pending transactions")
+		client.Close()
+		return fmt.Errorf("failed to subscribe to full pending transactions: %w", err)
 	}
 
 	hdrChan := make(chan *gethtypes.Header, 100)
-	hSub, err := ec.SubscribeNewHead(context.Background(), hdrChan)
+	hSub, err := ec.SubscribeNewHead(ctx, hdrChan)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to subscribe to new head")
+		pSub.Unsubscribe()
+		client.Close()
+		return fmt.Errorf("failed to subscribe to new heads: %w", err)
 	}
-	chainID, err := ec.ChainID(context.Background())
+	chainID, err := ec.ChainID(ctx)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to get chain ID")
+		pSub.Unsubscribe()
+		hSub.Unsubscribe()
+		client.Close()
+		return fmt.Errorf("failed to get chain ID: %w", err)
 	}
 
 	currBaseFee := new(big.Int)
 	pendingTxs := make(map[common.Hash]*gethtypes.Transaction)
 	txTime := make(map[common.Hash]time.Time)
 
-	txDataList := loadDataFromFile[TxData](filepath.Join(dataFolder, txDataFile))
-	blockDataList := loadDataFromFile[BlockData](filepath.Join(dataFolder, blockDataFile))
-	txMetricsList := loadDataFromFile[TxMetricsData](filepath.Join(dataFolder, txMetricsFile))
-	txInclusionList := loadDataFromFile[TxInclusionData](filepath.Join(dataFolder, txInclusionFile))
+	window := NewChainWindow(monitorWindow)
+	includedTxs := make(map[common.Hash]includedTx)
 
-	createDataFolder()
+	// Subscriptions are closed and every data file is written once more before returning
+	closeAll := func() {
+		pSub.Unsubscribe()
+		hSub.Unsubscribe()
+		ec.Close()
+	}
+	flush := func() {
+		saveDataToFile(filepath.Join(dataFolder, txDataFile), txDataList)
+		saveDataToFile(filepath.Join(dataFolder, blockDataFile), blockDataList)
+		saveDataToFile(filepath.Join(dataFolder, txMetricsFile), txMetricsList)
+		saveDataToFile(filepath.Join(dataFolder, txInclusionFile), txInclusionList)
+		saveDataToFile(filepath.Join(dataFolder, reorgFile), reorgList)
+	}
 
 	for {
 		select {
+		case <-ctx.Done():
+			log.Info("stopping monitor, flushing data files")
+			closeAll()
+			flush()
+			return nil
+
 		case err := <-pSub.Err():
-			log.Error().Err(err).Msg("Pending transaction subscription error")
-			ec.Close()
-			client.Close()
-			close(txChan)
-			close(hdrChan)
-			hSub.Unsubscribe()
-			saveDataToFile(filepath.Join(dataFolder, txDataFile), txDataList)
-			saveDataToFile(filepath.Join(dataFolder, blockDataFile), blockDataList)
-			saveDataToFile(filepath.Join(dataFolder, txMetricsFile), txMetricsList)
-			saveDataToFile(filepath.Join(dataFolder, txInclusionFile), txInclusionList)
-			return
+			closeAll()
+			flush()
+			return fmt.Errorf("pending transaction subscription failed: %w", err)
 
 		case err := <-hSub.Err():
-			log.Error().Err(err).Msg("New head subscription error")
-			ec.Close()
-			client.Close()
-			close(txChan)
-			close(hdrChan)
-			pSub.Unsubscribe()
-			saveDataToFile(filepath.Join(dataFolder, txDataFile), txDataList)
-			saveDataToFile(filepath.Join(dataFolder, blockDataFile), blockDataList)
-			saveDataToFile(filepath.Join(dataFolder, txMetricsFile), txMetricsList)
-			saveDataToFile(filepath.Join(dataFolder, txInclusionFile), txInclusionList)
-			return
+			closeAll()
+			flush()
+			return fmt.Errorf("new head subscription failed: %w", err)
 
 		case tx := <-txChan:
 			if tx.Type() == gethtypes.BlobTxType {
 				tHash := tx.Hash()
 				txData := txData(tx, chainID)
-				log.Info().Fields(txData).Msg("Received new Transaction from Gossip")
+				log.Info("received new transaction from gossip", txData.logFields()...)
 				txTime[tHash] = time.Now()
 				txMetricsList = recordTxMetrics(txMetricsList, tx, chainID, txTime[tHash])
 				pendingTxs[tHash] = tx
@@ -146,6 +204,40 @@ func main() {
 			}
 
 		case h := <-hdrChan:
+			reorg, err := window.Update(ctx, ec, h)
+			if err != nil {
+				log.Error("could not check block for reorgs", logging.KeySlot, h.Number, logging.KeyErr, err)
+			}
+			if reorg != nil {
+				reorgData := ReorgData{
+					Ancestor:      reorg.Ancestor,
+					NewHead:       h.Hash(),
+					NewHeadNumber: h.Number.Uint64(),
+					DroppedBlocks: reorg.Dropped,
+					Time:          time.Now().String(),
+				}
+				if reorg.OldHead != nil {
+					reorgData.OldHead = reorg.OldHead.Hash()
+				}
+				blockDataList, txInclusionList, reorgData.RevertedTxs = rollbackReorg(reorg, blockDataList, txInclusionList, includedTxs, pendingTxs, txTime)
+				log.Warn("chain reorg, rolled back dropped blocks",
+					logging.KeySlot, reorgData.NewHeadNumber,
+					"ancestor", reorgData.Ancestor,
+					"oldHead", reorgData.OldHead,
+					"newHead", reorgData.NewHead,
+					"droppedBlocks", len(reorgData.DroppedBlocks),
+					"revertedTxs", len(reorgData.RevertedTxs))
+				reorgList = append(reorgList, reorgData)
+				saveDataToFile(filepath.Join(dataFolder, blockDataFile), blockDataList)
+				saveDataToFile(filepath.Join(dataFolder, txInclusionFile), txInclusionList)
+				saveDataToFile(filepath.Join(dataFolder, reorgFile), reorgList)
+			}
+			for hash, included := range includedTxs {
+				if included.block+monitorWindow <= h.Number.Uint64() {
+					delete(includedTxs, hash)
+				}
+			}
+
 			if h.ExcessBlobGas != nil {
 				currBaseFee = eip4844.CalcBlobFee(*h.ExcessBlobGas)
 			}
@@ -157,7 +249,12 @@ func main() {
 				BaseFeeGwei:    float64(h.BaseFee.Uint64()) / params.GWei,
 				Builder:        strings.ToValidUTF8(string(h.Extra), ""),
 			}
-			log.Info().Fields(blockData).Msg("Received new block")
+			log.Info("received new block",
+				logging.KeySlot, blockData.BlockNumber,
+				"blockHash", blockData.BlockHash,
+				"blobBaseFeeWei", blockData.BlobBaseFeeWei,
+				"baseFeeGwei", blockData.BaseFeeGwei,
+				"builder", blockData.Builder)
 			blockDataList = append(blockDataList, blockData)
 			saveDataToFile(filepath.Join(dataFolder, blockDataFile), blockDataList)
 
@@ -167,11 +264,12 @@ func main() {
 			viableBlobs := 0
 
 			for hash, tx := range pendingTxs {
-				r, err := ec.TransactionReceipt(context.Background(), hash)
+				r, err := ec.TransactionReceipt(ctx, hash)
 				if err == nil && r.BlockHash == h.Hash() {
 					txData := txData(tx, chainID)
-					log.Info().Fields(txData).Msgf("Transaction was included in block %d in %s", r.BlockNumber.Uint64(), time.Since(txTime[hash]))
-					txInclusionList = recordTxInclusion(txInclusionList, tx, chainID, time.Since(txTime[hash]))
+					log.Info("transaction was included", append(txData.logFields(), logging.KeyBlock, r.BlockNumber, "after", time.Since(txTime[hash]))...)
+					txInclusionList = recordTxInclusion(txInclusionList, tx, chainID, r, time.Since(txTime[hash]))
+					includedTxs[hash] = includedTx{tx: tx, seenAt: txTime[hash], block: r.BlockNumber.Uint64()}
 					blobsIncluded += len(tx.BlobHashes())
 					delete(pendingTxs, hash)
 					delete(txTime, hash)
@@ -180,18 +278,18 @@ func main() {
 				}
 				acc, err := gethtypes.Sender(gethtypes.NewCancunSigner(chainID), tx)
 				if err != nil {
-					log.Error().Err(err).Msg("Could not get sender's account address")
+					log.Error("could not get sender's account address", logging.KeyTxHash, hash, logging.KeyErr, err)
 					continue
 				}
 
-				currNonce, err := ec.NonceAtHash(context.Background(), acc, h.Hash())
+				currNonce, err := ec.NonceAtHash(ctx, acc, h.Hash())
 				if err != nil {
-					log.Error().Err(err).Msg("Could not get sender's account nonce")
+					log.Error("could not get sender's account nonce", logging.KeyAccount, acc, logging.KeyErr, err)
 					continue
 				}
 				if tx.Nonce() < currNonce {
 					txData := txData(tx, chainID)
-					log.Info().Fields(txData).Msgf("Transaction has been successfully replaced and included on chain in %s", time.Since(txTime[hash]))
+					log.Info("transaction was replaced and its nonce included on chain", append(txData.logFields(), "after", time.Since(txTime[hash]))...)
 					delete(pendingTxs, hash)
 					delete(txTime, hash)
 					continue
@@ -203,39 +301,49 @@ func main() {
 					viabletxs++
 					viableBlobs += len(tx.BlobHashes())
 					txData := txData(tx, chainID)
-					log.Info().Fields(txData).Msgf("Transaction was still not included after %s", time.Since(txTime[hash]))
+					log.Info("transaction is still not included", append(txData.logFields(), "after", time.Since(txTime[hash]))...)
 				}
 			}
 
-			log.Info().Msgf("Pending Transactions: %d", len(pendingTxs))
-			log.Info().Msgf("Viable Transactions: %d", viabletxs)
-			log.Info().Msgf("Viable Blobs: %d", viableBlobs)
-			log.Info().Msgf("Transaction Inclusions: %d", currentPendingTxs-len(pendingTxs))
-			log.Info().Msgf("Tx Blob Inclusions by Builder %s: %d", strings.ToValidUTF8(string(h.Extra), ""), blobsIncluded)
-			log.Info().Msgf("Blocks by Builder %s: %d", strings.ToValidUTF8(string(h.Extra), ""), 1)
-
-			log.Info().Fields(map[string]interface{}{
-				"previousPendingTxs": currentPendingTxs,
-				"currentPendingTxs":  len(pendingTxs),
-				"viableTxs":          viabletxs,
-			}).Msg("Post block Summary for blob transactions")
+			log.Info("post block summary for blob transactions",
+				logging.KeySlot, h.Number,
+				"builder", strings.ToValidUTF8(string(h.Extra), ""),
+				"previousPendingTxs", currentPendingTxs,
+				"currentPendingTxs", len(pendingTxs),
+				"viableTxs", viabletxs,
+				"viableBlobs", viableBlobs,
+				"inclusions", currentPendingTxs-len(pendingTxs),
+				"blobsIncluded", blobsIncluded)
 		}
 	}
 }
 
-func createDataFolder() {
+// createDataFolder creates the data folder unless it exists.
+func createDataFolder() error {
 	if _, err := os.Stat(dataFolder); os.IsNotExist(err) {
-		err = os.Mkdir(dataFolder, 0755)
-		if err != nil {
-			log.Fatal().Err(err).Msgf("Could not create data folder %s", dataFolder)
+		if err := os.Mkdir(dataFolder, 0755); err != nil {
+			return fmt.Errorf("could not create data folder %s: %w", dataFolder, err)
 		}
 	}
+	return nil
+}
+
+// logFields returns the fields logged for a transaction.
+func (d TxData) logFields() []interface{} {
+	return []interface{}{
+		logging.KeyTxHash, d.TxHash,
+		logging.KeyAccount, d.Account,
+		"blobCount", d.BlobCount,
+		"blobGasFeeCapGwei", d.BlobGasFeeCapGwei,
+		"gasFeeCapGwei", d.GasFeeCapGwei,
+		"gasTipCapGwei", d.GasTipCapGwei,
+	}
 }
 
 func txData(tx *gethtypes.Transaction, chainID *big.Int) TxData {
 	acc, err := gethtypes.Sender(gethtypes.NewCancunSigner(chainID), tx)
 	if err != nil {
-		log.Error().Err(err).Msg("Could not get sender's account address")
+		log.Error("could not get sender's account address", logging.KeyTxHash, tx.Hash(), logging.KeyErr, err)
 		return TxData{}
 	}
 
@@ -254,7 +362,7 @@ func txData(tx *gethtypes.Transaction, chainID *big.Int) TxData {
 func recordTxMetrics(txMetricsList []TxMetricsData, tx *gethtypes.Transaction, chainID *big.Int, txTime time.Time) []TxMetricsData {
 	acc, err := gethtypes.Sender(gethtypes.NewCancunSigner(chainID), tx)
 	if err != nil {
-		log.Error().Err(err).Msg("Could not get sender's account address")
+		log.Error("could not get sender's account address", logging.KeyTxHash, tx.Hash(), logging.KeyErr, err)
 		return txMetricsList
 	}
 	data := TxMetricsData{
@@ -263,60 +371,75 @@ func recordTxMetrics(txMetricsList []TxMetricsData, tx *gethtypes.Transaction, c
 		BlobGasFeeCap: tx.BlobGasFeeCap().Uint64(),
 		TxTime:        txTime.String(),
 	}
-	log.Info().Msgf("Observed Transaction: Account=%s, BlobCount=%d, BlobGasFeeCap=%d, TxTime=%s", data.Account, data.BlobCount, data.BlobGasFeeCap, data.TxTime)
+	log.Info("observed transaction", logging.KeyTxHash, tx.Hash(), logging.KeyAccount, data.Account, "blobCount", data.BlobCount, "blobGasFeeCap", data.BlobGasFeeCap, "txTime", data.TxTime)
 	return append(txMetricsList, data)
 }
 
-func recordTxInclusion(txInclusionList []TxInclusionData, tx *gethtypes.Transaction, chainID *big.Int, inclusionDelay time.Duration) []TxInclusionData {
+func recordTxInclusion(txInclusionList []TxInclusionData, tx *gethtypes.Transaction, chainID *big.Int, receipt *gethtypes.Receipt, inclusionDelay time.Duration) []TxInclusionData {
 	acc, err := gethtypes.Sender(gethtypes.NewCancunSigner(chainID), tx)
 	if err != nil {
-		log.Error().Err(err).Msg("Could not get sender's account address")
+		log.Error("could not get sender's account address", logging.KeyTxHash, tx.Hash(), logging.KeyErr, err)
 		return txInclusionList
 	}
 
 	gasTip, _ := tx.GasTipCap().Float64()
 	gasTipGwei := gasTip / params.GWei
 	data := TxInclusionData{
+		TxHash:         tx.Hash(),
+		BlockNumber:    receipt.BlockNumber.Uint64(),
+		BlockHash:      receipt.BlockHash,
 		Account:        acc,
 		BlobCount:      len(tx.BlobHashes()),
 		InclusionDelay: inclusionDelay.Seconds(),
 		GasTipGwei:     gasTipGwei,
 	}
-	log.Info().Msgf("Transaction Inclusion: Account=%s, BlobCount=%d, InclusionDelay=%fs, GasTip(Gwei)=%f", data.Account, data.BlobCount, data.InclusionDelay, data.GasTipGwei)
+	log.Info("transaction inclusion", logging.KeyTxHash, data.TxHash, logging.KeyAccount, data.Account, logging.KeyBlock, data.BlockNumber, "blobCount", data.BlobCount, "inclusionDelay", data.InclusionDelay, "gasTipGwei", data.GasTipGwei)
 	return append(txInclusionList, data)
 }
 
-func saveDataToFile(filename string, data interface{}) {
-	file, err := os.Create(filename)
-	if err != nil {
-		log.Fatal().Err(err).Msgf("Could not create file %s", filename)
+// rollbackReorg removes the block and inclusion records of the blocks a reorg dropped and moves the
+// transactions included in them back to pending. It returns the remaining records and the reverted transactions.
+func rollbackReorg(reorg *Reorg, blockDataList []BlockData, txInclusionList []TxInclusionData, includedTxs map[common.Hash]includedTx, pendingTxs map[common.Hash]*gethtypes.Transaction, txTime map[common.Hash]time.Time) ([]BlockData, []TxInclusionData, []common.Hash) {
+	dropped := make(map[common.Hash]bool, len(reorg.Dropped))
+	for _, hash := range reorg.Dropped {
+		dropped[hash] = true
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(data); err != nil {
-		log.Fatal().Err(err).Msgf("Could not encode data to file %s", filename)
+	keptBlocks := blockDataList[:0]
+	for _, block := range blockDataList {
+		if !dropped[block.BlockHash] {
+			keptBlocks = append(keptBlocks, block)
+		}
 	}
-}
 
-func loadDataFromFile[T any](filename string) []T {
-	var data []T
-	if _, err := os.Stat(filename); err == nil {
-		file, err := os.Open(filename)
-		if err != nil {
-			log.Fatal().Err(err).Msgf("Could not open file %s", filename)
+	var reverted []common.Hash
+	keptInclusions := txInclusionList[:0]
+	for _, inclusion := range txInclusionList {
+		if !dropped[inclusion.BlockHash] {
+			keptInclusions = append(keptInclusions, inclusion)
+			continue
 		}
-		defer file.Close()
-
-		byteValue, err := ioutil.ReadAll(file)
-		if err != nil {
-			log.Fatal().Err(err).Msgf("Could not read file %s", filename)
+		if included, ok := includedTxs[inclusion.TxHash]; ok {
+			pendingTxs[inclusion.TxHash] = included.tx
+			txTime[inclusion.TxHash] = included.seenAt
+			delete(includedTxs, inclusion.TxHash)
 		}
+		reverted = append(reverted, inclusion.TxHash)
+	}
+	return keptBlocks, keptInclusions, reverted
+}
 
-		if err := json.Unmarshal(byteValue, &data); err != nil {
-			log.Fatal().Err(err).Msgf("Could not unmarshal data from file %s", filename)
-		}
+// saveDataToFile replaces a data file with the JSON encoding of data.
+func saveDataToFile(filename string, data interface{}) {
+	if err := datafile.WriteJSON(filename, data); err != nil {
+		log.Error("could not save data file", "file", filename, logging.KeyErr, err)
+	}
+}
+
+// loadDataFromFile loads the records of a data file into data, none if it does not exist yet.
+func loadDataFromFile[T any](filename string, data *[]T) error {
+	if _, err := datafile.ReadJSON(filename, data); err != nil {
+		return fmt.Errorf("could not load data file %s: %w", filename, err)
 	}
-	return data
+	return nil
 }
@@ -0,0 +1,110 @@
+// Package logging sets up the structured logger shared by all packages and defines the field names
+// their log lines use, so that the lines of one slot, transaction or endpoint can be aggregated.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/mattn/go-isatty"
+)
+
+// Field names used by every package for the same values.
+const (
+	KeySlot     = "slot"     // The number of the head block that started the slot.
+	KeyBlock    = "block"    // The block a transaction, bundle or bid targets.
+	KeyTxHash   = "txHash"   // The hash of a transaction.
+	KeyAccount  = "account"  // The address of a sending account.
+	KeyEndpoint = "endpoint" // The URL of an RPC, relay or builder endpoint.
+	KeyProvider = "provider" // The address of a preconfirmation provider.
+	KeyErr      = "err"      // The error that caused the log line.
+)
+
+// Log formats accepted by New.
+const (
+	FormatText = "text" // Human readable lines, colored when written to a terminal.
+	FormatJSON = "json" // One JSON object per line.
+)
+
+// Logger is the structured logger injected into the components that log.
+type Logger = log.Logger
+
+// New creates a logger.
+//
+// Parameters:
+// - w: The destination of the log lines.
+// - format: FormatText or FormatJSON. Empty means FormatText.
+// - level: One of trace, debug, info, warn, error or crit. Empty means info.
+//
+// Returns:
+// - The logger, or an error if the format or the level is unknown.
+func New(w io.Writer, format, level string) (Logger, error) {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		useColor := false
+		if f, ok := w.(*os.File); ok {
+			useColor = isatty.IsTerminal(f.Fd())
+		}
+		handler = log.NewTerminalHandlerWithLevel(w, lvl, useColor)
+	case FormatJSON:
+		handler = log.JSONHandlerWithLevel(w, lvl)
+	default:
+		return nil, fmt.Errorf("unknown log format %q, want %s or %s", format, FormatText, FormatJSON)
+	}
+	return log.NewLogger(handler), nil
+}
+
+// ParseLevel parses a log level name.
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "trace":
+		return log.LevelTrace, nil
+	case "debug":
+		return log.LevelDebug, nil
+	case "", "info":
+		return log.LevelInfo, nil
+	case "warn", "warning":
+		return log.LevelWarn, nil
+	case "error":
+		return log.LevelError, nil
+	case "crit":
+		return log.LevelCrit, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q", level)
+	}
+}
+
+// fieldsKey is the context key of the fields added by WithFields.
+type fieldsKey struct{}
+
+// WithFields returns a context that carries the given key value pairs, which FromContext adds to
+// every line logged for the context.
+func WithFields(ctx context.Context, keyvals ...interface{}) context.Context {
+	fields, _ := ctx.Value(fieldsKey{}).([]interface{})
+	merged := make([]interface{}, 0, len(fields)+len(keyvals))
+	merged = append(append(merged, fields...), keyvals...)
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// FromContext returns base with the fields carried by ctx. A nil base means the default logger.
+func FromContext(ctx context.Context, base Logger) Logger {
+	if base == nil {
+		base = log.Root()
+	}
+	fields, _ := ctx.Value(fieldsKey{}).([]interface{})
+	if len(fields) == 0 {
+		return base
+	}
+	return base.With(fields...)
+}
@@ -5,23 +5,28 @@ package mevcommit
 import (
 	"context"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
+	"math/big"
 	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/log"
 	pb "github.com/primev/preconf_blob_bidder/core/bidderpb"
+	"github.com/primev/preconf_blob_bidder/core/datafile"
+	"github.com/primev/preconf_blob_bidder/core/logging"
+	"github.com/primev/preconf_blob_bidder/core/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 // SendBid sends a bid to the mev-commit client for a given set of transaction hashes or raw transactions, amount, and block number.
 // The bid will be decayed over a specified time range.
 //
 // Parameters:
+// - ctx: The context for the bid stream, which also holds the span of the slot.
 // - input: Can be either a slice of transaction hashes ([]string) or a slice of *types.Transaction.
 // - amount: The bid amount in wei as a string.
 // - blockNumber: The block number for which the bid applies.
@@ -29,8 +34,12 @@ import (
 // - decayEnd: The end timestamp for bid decay (in milliseconds).
 //
 // Returns:
-// - A pb.Bidder_SendBidClient to receive bid responses, or an error if the bid fails.
-func (b *Bidder) SendBid(input interface{}, amount string, blockNumber, decayStart, decayEnd int64) (pb.Bidder_SendBidClient, error) {
+// - The commitments received from providers for the bid, or an error if the bid fails.
+func (b *Bidder) SendBid(ctx context.Context, input interface{}, amount string, blockNumber, decayStart, decayEnd int64) (_ []*pb.Commitment, err error) {
+	ctx, span := tracing.Start(ctx, "bid.send", tracing.Block(uint64(blockNumber)))
+	defer func() { tracing.End(span, err) }()
+	logger := logging.FromContext(ctx, b.logger).With(logging.KeyBlock, blockNumber)
+
 	// Prepare variables to hold transaction hashes or raw transactions
 	var txHashes []string
 	var rawTransactions []string
@@ -42,6 +51,7 @@ func (b *Bidder) SendBid(input interface{}, amount string, blockNumber, decaySta
 		txHashes = make([]string, len(v))
 		for i, hash := range v {
 			txHashes[i] = strings.TrimPrefix(hash, "0x")
+			span.SetAttributes(tracing.TxHash(common.HexToHash(hash)))
 		}
 	case []*types.Transaction:
 		// If input is a slice of *types.Transaction, convert to raw transactions
@@ -49,13 +59,14 @@ func (b *Bidder) SendBid(input interface{}, amount string, blockNumber, decaySta
 		for i, tx := range v {
 			rlpEncodedTx, err := tx.MarshalBinary()
 			if err != nil {
-				log.Error("Failed to marshal transaction to raw format", "error", err)
+				logger.Error("failed to marshal transaction to raw format", logging.KeyTxHash, tx.Hash(), logging.KeyErr, err)
 				return nil, fmt.Errorf("failed to marshal transaction: %w", err)
 			}
 			rawTransactions[i] = hex.EncodeToString(rlpEncodedTx)
+			span.SetAttributes(tracing.TxHash(tx.Hash()))
 		}
 	default:
-		log.Warn("Unsupported input type, must be []string or []*types.Transaction")
+		logger.Warn("unsupported input type, must be []string or []*types.Transaction", "type", fmt.Sprintf("%T", input))
 		return nil, fmt.Errorf("unsupported input type: %T", input)
 	}
 
@@ -78,20 +89,21 @@ func (b *Bidder) SendBid(input interface{}, amount string, blockNumber, decaySta
 		bidRequest.RawTransactions = rawTxStrings
 	}
 
-	ctx := context.Background()
-
 	// Send the bid request to the mev-commit client
 	response, err := b.client.SendBid(ctx, bidRequest)
 	if err != nil {
-		log.Error("Failed to send bid", "error", err)
+		logger.Error("failed to send bid", logging.KeyErr, err)
 		return nil, fmt.Errorf("failed to send bid: %w", err)
 	}
 
-	var responses []interface{}
+	var (
+		responses   []interface{}
+		commitments []*pb.Commitment
+	)
 	submitTimestamp := time.Now().Unix()
 
 	// Save the bid request along with the submission timestamp
-	go saveBidRequest("data/bid.json", bidRequest, submitTimestamp)
+	b.saveInBackground(func() { b.saveBidRequest(logger, "data/bid.json", bidRequest, submitTimestamp) })
 
 	// Continuously receive bid responses
 	for {
@@ -101,69 +113,125 @@ func (b *Bidder) SendBid(input interface{}, amount string, blockNumber, decaySta
 			break
 		}
 		if err != nil {
-			log.Error("Failed to receive bid response", "error", err)
+			logger.Error("failed to receive bid response", logging.KeyErr, err)
 			return nil, fmt.Errorf("failed to send bid: %w", err)
 		}
 
-		log.Info("Bid accepted", "commitment details", msg)
+		logger.Info("bid accepted", logging.KeyProvider, msg.ProviderAddress, "commitment details", msg)
+		span.AddEvent("commitment", trace.WithAttributes(attribute.String("provider", msg.ProviderAddress)))
 		responses = append(responses, msg)
+		commitments = append(commitments, msg)
 	}
 
-	// Timer before saving bid responses
-	startTimeBeforeSaveResponses := time.Now()
-	log.Info("End Time", "time", startTimeBeforeSaveResponses)
+	logger.Debug("bid stream ended", "commitments", len(commitments))
 
 	// Save all bid responses to a file
-	go saveBidResponses("data/response.json", responses)
-	return response, nil
+	b.saveInBackground(func() { b.saveBidResponses(logger, "data/response.json", responses) })
+	return commitments, nil
 }
 
-// saveBidRequest saves the bid request and timestamp to a JSON file.
-// The data is appended to an array of existing bid requests.
+// GetDeposit returns the bidder's deposit in the current bidding window, as reported by the bidder node.
 //
 // Parameters:
-// - filename: The name of the JSON file to save the bid request to.
-// - bidRequest: The bid request to save.
-// - timestamp: The timestamp of when the bid was submitted (in Unix time).
-func saveBidRequest(filename string, bidRequest *pb.Bid, timestamp int64) {
-	// Ensure the directory exists
-	dir := filepath.Dir(filename)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		log.Error("Failed to create directory", "directory", dir, "error", err)
-		return
+// - ctx: The context for the request.
+//
+// Returns:
+// - The deposit in wei, or an error if the request fails.
+func (b *Bidder) GetDeposit(ctx context.Context) (*big.Int, error) {
+	response, err := b.client.GetDeposit(ctx, &pb.GetDepositRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deposit: %w", err)
+	}
+	amount, ok := new(big.Int).SetString(response.Amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid deposit amount %q", response.Amount)
 	}
+	return amount, nil
+}
 
-	// Prepare the data to be saved
-	data := map[string]interface{}{
-		"timestamp":  timestamp,
-		"bidRequest": bidRequest,
+// Deposit deposits an amount into a bidding window from the bidder node's account.
+//
+// Parameters:
+// - ctx: The context for the request.
+// - amount: The amount to deposit in wei.
+// - window: The window to deposit into. Zero means the current window.
+//
+// Returns:
+// - The deposited amount in wei and the window it went to, or an error if the request fails.
+func (b *Bidder) Deposit(ctx context.Context, amount *big.Int, window uint64) (*big.Int, uint64, error) {
+	request := &pb.DepositRequest{Amount: amount.String()}
+	if window != 0 {
+		request.WindowNumber = wrapperspb.UInt64(window)
 	}
+	response, err := b.client.Deposit(ctx, request)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to deposit: %w", err)
+	}
+	return parseAmount(response.Amount, response.WindowNumber)
+}
 
-	// Open the file, creating it if it doesn't exist
-	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+// Withdraw withdraws the deposit of a bidding window that has settled to the bidder node's account.
+//
+// Parameters:
+// - ctx: The context for the request.
+// - window: The window to withdraw from.
+//
+// Returns:
+// - The withdrawn amount in wei and the window it came from, or an error if the request fails.
+func (b *Bidder) Withdraw(ctx context.Context, window uint64) (*big.Int, uint64, error) {
+	response, err := b.client.Withdraw(ctx, &pb.WithdrawRequest{WindowNumber: wrapperspb.UInt64(window)})
 	if err != nil {
-		log.Error("Failed to open file", "filename", filename, "error", err)
-		return
+		return nil, 0, fmt.Errorf("failed to withdraw: %w", err)
 	}
-	defer file.Close()
+	return parseAmount(response.Amount, response.WindowNumber)
+}
+
+// parseAmount parses the amount and window of a deposit or withdrawal response.
+func parseAmount(amount string, window *wrapperspb.UInt64Value) (*big.Int, uint64, error) {
+	value, ok := new(big.Int).SetString(amount, 10)
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid amount %q", amount)
+	}
+	return value, window.GetValue(), nil
+}
+
+// saveInBackground runs a write of the data folder in a goroutine that Close waits for.
+func (b *Bidder) saveInBackground(save func()) {
+	b.writes.Add(1)
+	go func() {
+		defer b.writes.Done()
+		save()
+	}()
+}
+
+// saveBidRequest saves the bid request and timestamp to a JSON file.
+// The data is appended to an array of existing bid requests.
+//
+// Parameters:
+// - logger: The logger of the bid.
+// - filename: The name of the JSON file to save the bid request to.
+// - bidRequest: The bid request to save.
+// - timestamp: The timestamp of when the bid was submitted (in Unix time).
+func (b *Bidder) saveBidRequest(logger logging.Logger, filename string, bidRequest *pb.Bid, timestamp int64) {
+	b.filesMu.Lock()
+	defer b.filesMu.Unlock()
 
 	// Read existing data from the file
 	var existingData []map[string]interface{}
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&existingData); err != nil && err.Error() != "EOF" {
-		log.Error("Failed to decode existing JSON data", "error", err)
+	if _, err := datafile.ReadJSON(filename, &existingData); err != nil {
+		logger.Error("Failed to decode existing JSON data", "file", filename, logging.KeyErr, err)
 		return
 	}
 
 	// Append the new bid request to the existing data
-	existingData = append(existingData, data)
-
-	// Write the updated data back to the file
-	file.Seek(0, 0)  // Move to the beginning of the file
-	file.Truncate(0) // Clear the file content
-	encoder := json.NewEncoder(file)
-	if err := encoder.Encode(existingData); err != nil {
-		log.Error("Failed to encode data to JSON", "error", err)
+	existingData = append(existingData, map[string]interface{}{
+		"timestamp":  timestamp,
+		"bidRequest": bidRequest,
+	})
+
+	// Replace the file with the updated data
+	if err := datafile.WriteJSON(filename, existingData); err != nil {
+		logger.Error("Failed to save bid request", "file", filename, logging.KeyErr, err)
 	}
 }
 
@@ -171,40 +239,25 @@ func saveBidRequest(filename string, bidRequest *pb.Bid, timestamp int64) {
 // The responses are appended to an array of existing responses.
 //
 // Parameters:
+// - logger: The logger of the bid.
 // - filename: The name of the JSON file to save the bid responses to.
 // - responses: A slice of bid responses to save.
-func saveBidResponses(filename string, responses []interface{}) {
-	// Ensure the directory exists
-	dir := filepath.Dir(filename)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		log.Error("Failed to create directory", "directory", dir, "error", err)
-		return
-	}
-
-	// Open the file, creating it if it doesn't exist
-	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
-	if err != nil {
-		log.Error("Failed to open file", "filename", filename, "error", err)
-		return
-	}
-	defer file.Close()
+func (b *Bidder) saveBidResponses(logger logging.Logger, filename string, responses []interface{}) {
+	b.filesMu.Lock()
+	defer b.filesMu.Unlock()
 
 	// Read existing data from the file
 	var existingData []interface{}
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&existingData); err != nil && err.Error() != "EOF" {
-		log.Error("Failed to decode existing JSON data", "error", err)
+	if _, err := datafile.ReadJSON(filename, &existingData); err != nil {
+		logger.Error("Failed to decode existing JSON data", "file", filename, logging.KeyErr, err)
 		return
 	}
 
 	// Append the new bid responses to the existing data
 	existingData = append(existingData, responses...)
 
-	// Write the updated responses back to the file
-	file.Seek(0, 0)  // Move to the beginning of the file
-	file.Truncate(0) // Clear the file content
-	encoder := json.NewEncoder(file)
-	if err := encoder.Encode(existingData); err != nil {
-		log.Error("Failed to encode data to JSON", "error", err)
+	// Replace the file with the updated responses
+	if err := datafile.WriteJSON(filename, existingData); err != nil {
+		logger.Error("Failed to save bid responses", "file", filename, logging.KeyErr, err)
 	}
 }
@@ -4,20 +4,30 @@
 package mevcommit
 
 import (
+	"bufio"
+	"context"
 	"crypto/ecdsa"
+	"errors"
+	"fmt"
 	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 
 	pb "github.com/primev/preconf_blob_bidder/core/bidderpb"
+	"github.com/primev/preconf_blob_bidder/core/logging"
 	"google.golang.org/grpc"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 )
 
 // BidderConfig holds the configuration settings for the mev-commit bidder node.
@@ -29,7 +39,12 @@ type BidderConfig struct {
 
 // Bidder utilizes the mev-commit bidder client to interact with the mev-commit chain.
 type Bidder struct {
-	client pb.BidderClient // gRPC client for interacting with the mev-commit bidder service.
+	conn   *grpc.ClientConn // gRPC connection to the bidder node.
+	client pb.BidderClient  // gRPC client for interacting with the mev-commit bidder service.
+	logger logging.Logger   // Logger of the bid stream, in the format and level of the BidderConfig.
+
+	writes  sync.WaitGroup // Writes of bid requests and responses to the data folder that are in progress.
+	filesMu sync.Mutex     // Serializes the writes, which read and replace the same files.
 }
 
 // GethConfig holds configuration settings for a Geth node to connect to the mev-commit chain.
@@ -37,11 +52,13 @@ type GethConfig struct {
 	Endpoint string `json:"endpoint" yaml:"endpoint"` // The RPC endpoint for connecting to the Ethereum node.
 }
 
-// AuthAcct holds the private key, public key, address, and transaction authorization information for an account.
+// AuthAcct holds the signer, address, and transaction authorization information for an account.
+// PrivateKey and PublicKey are only set for accounts loaded from a raw hex key.
 type AuthAcct struct {
 	PrivateKey *ecdsa.PrivateKey  // The private key for the account.
 	PublicKey  *ecdsa.PublicKey   // The public key derived from the private key.
-	Address    common.Address     // The Ethereum address derived from the public key.
+	Address    common.Address     // The Ethereum address of the account.
+	Signer     Signer             // The signer used for all transactions of the account.
 	Auth       *bind.TransactOpts // The transaction options for signing transactions.
 }
 
@@ -51,18 +68,41 @@ type AuthAcct struct {
 // - cfg: The BidderConfig struct containing the server address and logging settings.
 //
 // Returns:
-// - A pointer to a Bidder struct, or an error if the connection fails.
+// - A pointer to a Bidder struct, or an error if the log settings are invalid or the connection fails.
 func NewBidderClient(cfg BidderConfig) (*Bidder, error) {
+	logger, err := logging.New(os.Stderr, cfg.LogFmt, cfg.LogLevel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bidder log settings: %w", err)
+	}
+	logger = logger.With(logging.KeyEndpoint, cfg.ServerAddress)
+
 	// Establish a gRPC connection to the bidder service
 	conn, err := grpc.Dial(cfg.ServerAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
-		log.Crit("Failed to connect to gRPC server", "err", err)
+		logger.Error("failed to connect to gRPC server", logging.KeyErr, err)
 		return nil, err
 	}
 
 	// Create a new bidder client using the gRPC connection
 	client := pb.NewBidderClient(conn)
-	return &Bidder{client: client}, nil
+	return &Bidder{conn: conn, client: client, logger: logger}, nil
+}
+
+// ConnState returns the state of the gRPC connection to the bidder node. An idle connection is
+// asked to reconnect, so that the next call reports whether the node can be reached.
+func (b *Bidder) ConnState() connectivity.State {
+	state := b.conn.GetState()
+	if state == connectivity.Idle {
+		b.conn.Connect()
+	}
+	return state
+}
+
+// Close waits for the bid requests and responses being written to the data folder and closes the gRPC
+// connection to the bidder node.
+func (b *Bidder) Close() error {
+	b.writes.Wait()
+	return b.conn.Close()
 }
 
 // NewGethClient connects to an Ethereum-compatible chain using the provided RPC endpoint.
@@ -88,7 +128,7 @@ func NewGethClient(endpoint string) (*ethclient.Client, error) {
 // which contains the account's private key, public key, address, and transaction authorization.
 //
 // Parameters:
-// - privateKeyHex: The hex-encoded private key string.
+// - privateKeyHex: The hex-encoded private key string, with or without 0x prefix.
 //
 // Returns:
 // - A pointer to an AuthAcct struct, or an error if authentication fails.
@@ -98,36 +138,118 @@ func AuthenticateAddress(privateKeyHex string) (AuthAcct, error) {
 	}
 
 	// Convert the hex-encoded private key to an ECDSA private key
-	privateKey, err := crypto.HexToECDSA(privateKeyHex)
+	privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(privateKeyHex, "0x"))
 	if err != nil {
-		log.Crit("Failed to load private key", "err", err)
-		return AuthAcct{}, err
+		return AuthAcct{}, fmt.Errorf("failed to load private key: %w", err)
 	}
 
 	// Extract the public key from the private key
 	publicKey := privateKey.Public()
 	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
 	if !ok {
-		log.Crit("Failed to assert public key type")
+		return AuthAcct{}, errors.New("failed to assert public key type")
 	}
 
-	// Generate the Ethereum address from the public key
-	address := crypto.PubkeyToAddress(*publicKeyECDSA)
+	acct := NewAuthAcct(NewKeySigner(privateKey))
+
+	// Return the AuthAcct struct containing the private key, public key, address, signer, and transaction options
+	acct.PrivateKey = privateKey
+	acct.PublicKey = publicKeyECDSA
+	return acct, nil
+}
 
+// NewAuthAcct creates an AuthAcct whose transactions are all signed by signer.
+//
+// Parameters:
+// - signer: The signer of the account.
+//
+// Returns:
+// - An AuthAcct struct without private key.
+func NewAuthAcct(signer Signer) AuthAcct {
 	// Set the chain ID (currently hardcoded for Holesky testnet)
 	chainID := big.NewInt(17000) // Holesky
 
-	// Create the transaction options with the private key and chain ID
-	auth, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
-	if err != nil {
-		log.Crit("Failed to create authorized transactor", "err", err)
+	// Create the transaction options that delegate signing to the signer
+	auth := &bind.TransactOpts{
+		From: signer.Address(),
+		Signer: func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != signer.Address() {
+				return nil, bind.ErrNotAuthorized
+			}
+			return signer.SignTx(context.Background(), tx, chainID)
+		},
+		Context: context.Background(),
 	}
 
-	// Return the AuthAcct struct containing the private key, public key, address, and transaction options
 	return AuthAcct{
-		PrivateKey: privateKey,
-		PublicKey:  publicKeyECDSA,
-		Address:    address,
-		Auth:       auth,
-	}, nil
+		Address: signer.Address(),
+		Signer:  signer,
+		Auth:    auth,
+	}
+}
+
+// LoadAccounts loads hex-encoded private keys from a file or a directory and authenticates each of them.
+// A file holds one key per line, blank lines and lines starting with '#' are ignored. In a directory,
+// every regular file holds a single key.
+//
+// Parameters:
+// - path: The path to the key file or key directory.
+//
+// Returns:
+// - A slice of AuthAcct structs in the order the keys were read, or an error if a key cannot be loaded.
+func LoadAccounts(path string) ([]AuthAcct, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat key path: %w", err)
+	}
+
+	var keys []string
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key directory: %w", err)
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(path, entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read key file %s: %w", entry.Name(), err)
+			}
+			keys = append(keys, strings.TrimSpace(string(data)))
+		}
+	} else {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open key file: %w", err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			keys = append(keys, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+	}
+
+	accounts := make([]AuthAcct, 0, len(keys))
+	for i, key := range keys {
+		if key == "" {
+			return nil, fmt.Errorf("invalid private key #%d: empty key", i+1)
+		}
+		acct, err := AuthenticateAddress(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key #%d: %w", i+1, err)
+		}
+		accounts = append(accounts, acct)
+	}
+
+	return accounts, nil
 }
@@ -0,0 +1,56 @@
+package mevcommit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestAuthenticateAddress(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	keyHex := hexutil.Encode(crypto.FromECDSA(key))
+
+	for _, input := range []string{keyHex, strings.TrimPrefix(keyHex, "0x")} {
+		acct, err := AuthenticateAddress(input)
+		if err != nil {
+			t.Fatalf("AuthenticateAddress(%q): %v", input, err)
+		}
+		if acct.Address != address || acct.PrivateKey == nil {
+			t.Errorf("AuthenticateAddress(%q) = %s, want %s", input, acct.Address, address)
+		}
+	}
+	if _, err := AuthenticateAddress("0xnotakey"); err == nil {
+		t.Error("AuthenticateAddress accepted an invalid key")
+	}
+}
+
+func TestLoadAccounts(t *testing.T) {
+	first, _ := crypto.GenerateKey()
+	second, _ := crypto.GenerateKey()
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "keys")
+	content := "# senders\n" + hexutil.Encode(crypto.FromECDSA(first)) + "\n\n" + strings.TrimPrefix(hexutil.Encode(crypto.FromECDSA(second)), "0x") + "\n"
+	if err := os.WriteFile(keyFile, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	accounts, err := LoadAccounts(keyFile)
+	if err != nil {
+		t.Fatalf("LoadAccounts: %v", err)
+	}
+	if len(accounts) != 2 || accounts[0].Address != crypto.PubkeyToAddress(first.PublicKey) || accounts[1].Address != crypto.PubkeyToAddress(second.PublicKey) {
+		t.Fatalf("accounts = %+v, want both keys in order", accounts)
+	}
+
+	if err := os.WriteFile(keyFile, []byte(hexutil.Encode(crypto.FromECDSA(first))+"\nbad\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadAccounts(keyFile); err == nil || !strings.Contains(err.Error(), "invalid private key #2") {
+		t.Fatalf("LoadAccounts() error = %v, want invalid private key #2", err)
+	}
+}
@@ -5,7 +5,6 @@ package mevcommit
 import (
 	"context"
 	"fmt"
-	"log"
 	"math/big"
 	"os"
 	"strings"
@@ -16,6 +15,8 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/primev/preconf_blob_bidder/core/logging"
 )
 
 // Contract addresses used within the mev-commit protocol.
@@ -43,6 +44,14 @@ type CommitmentStoredEvent struct {
 	SharedSecretKey     []byte
 }
 
+// Provider is a preconfirmation provider registered in the ProviderRegistry contract.
+type Provider struct {
+	Address           common.Address // The address of the provider.
+	RegisteredStake   *big.Int       // The stake the provider registered with, in wei.
+	Stake             *big.Int       // The current stake of the provider, in wei.
+	RegisteredAtBlock uint64         // The mev-commit chain block the provider registered in.
+}
+
 // LoadABI loads the ABI from the specified file path and parses it.
 //
 // Parameters:
@@ -53,13 +62,13 @@ type CommitmentStoredEvent struct {
 func LoadABI(filePath string) (abi.ABI, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
-		log.Println("Failed to load ABI file:", err)
+		log.Error("failed to load ABI file", "file", filePath, logging.KeyErr, err)
 		return abi.ABI{}, err
 	}
 
 	parsedABI, err := abi.JSON(strings.NewReader(string(data)))
 	if err != nil {
-		log.Println("Failed to parse ABI file:", err)
+		log.Error("failed to parse ABI file", "file", filePath, logging.KeyErr, err)
 		return abi.ABI{}, err
 	}
 
@@ -77,7 +86,7 @@ func WindowHeight(client *ethclient.Client) (*big.Int, error) {
 	// Load the BlockTracker contract ABI
 	blockTrackerABI, err := LoadABI("abi/BlockTracker.abi")
 	if err != nil {
-		log.Println("Failed to load ABI file:", err)
+		log.Error("failed to load ABI file", logging.KeyErr, err)
 		return nil, err
 	}
 
@@ -88,14 +97,14 @@ func WindowHeight(client *ethclient.Client) (*big.Int, error) {
 	var currentWindowResult []interface{}
 	err = blockTrackerContract.Call(nil, &currentWindowResult, "getCurrentWindow")
 	if err != nil {
-		log.Println("Failed to get current window:", err)
+		log.Error("failed to get current window", logging.KeyErr, err)
 		return nil, err
 	}
 
 	// Extract the current window as *big.Int
 	currentWindow, ok := currentWindowResult[0].(*big.Int)
 	if !ok {
-		log.Println("Failed to convert current window to *big.Int")
+		log.Error("failed to convert current window to *big.Int")
 		return nil, fmt.Errorf("conversion to *big.Int failed")
 	}
 
@@ -177,7 +186,7 @@ func DepositIntoWindow(client *ethclient.Client, depositWindow *big.Int, authAcc
 
 	// Check the transaction status
 	if receipt.Status == 1 {
-		fmt.Println("Transaction successful")
+		log.Info("deposit successful", logging.KeyTxHash, tx.Hash(), "window", depositWindow, "amount", minDeposit)
 		return tx, nil
 	} else {
 		return nil, fmt.Errorf("transaction failed")
@@ -252,87 +261,164 @@ func WithdrawFromWindow(client *ethclient.Client, authAcct *AuthAcct, window *bi
 
 	// Check the withdrawal transaction status
 	if withdrawalReceipt.Status == 1 {
-		fmt.Println("Withdrawal successful")
+		log.Info("withdrawal successful", logging.KeyTxHash, withdrawalTx.Hash(), "window", window)
 		return withdrawalTx, nil
 	} else {
 		return nil, fmt.Errorf("withdrawal failed")
 	}
 }
 
-// ListenForCommitmentStoredEvent listens for the CommitmentStored event on the Ethereum blockchain.
-// This function will print event details when the CommitmentStored event is detected.
+// ListProviders lists the providers registered in the ProviderRegistry contract, whose address is read from
+// the PreConfCommitmentStore contract, with their current stake.
 //
 // Parameters:
-// - client: The Ethereum client instance.
+// - ctx: The context for the requests.
+// - client: The client of the mev-commit chain.
 //
-// Note: The event listener is not currently functioning correctly (as per the TODO comment).
-func ListenForCommitmentStoredEvent(client *ethclient.Client) {
-	// Load the PreConfCommitmentStore contract ABI
-	contractAbi, err := LoadABI("abi/PreConfCommitmentStore.abi")
+// Returns:
+// - The providers in the order they registered, or an error if a call fails.
+func ListProviders(ctx context.Context, client *ethclient.Client) ([]Provider, error) {
+	commitmentStoreABI, err := LoadABI("abi/PreConfCommitmentStore.abi")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ABI file: %v", err)
+	}
+	providerRegistryABI, err := LoadABI("abi/ProviderRegistry.abi")
 	if err != nil {
-		log.Fatalf("Failed to load contract ABI: %v", err)
+		return nil, fmt.Errorf("failed to load ABI file: %v", err)
 	}
 
-	// Subscribe to new block headers
-	headers := make(chan *types.Header)
-	sub, err := client.SubscribeNewHead(context.Background(), headers)
+	// Look up the ProviderRegistry contract used by the commitment store
+	commitmentStoreContract := bind.NewBoundContract(common.HexToAddress(preConfCommitmentStoreAddress), commitmentStoreABI, client, client, client)
+	var registryResult []interface{}
+	if err := commitmentStoreContract.Call(&bind.CallOpts{Context: ctx}, &registryResult, "providerRegistry"); err != nil {
+		return nil, fmt.Errorf("failed to call providerRegistry function: %v", err)
+	}
+	registryAddress, ok := registryResult[0].(common.Address)
+	if !ok {
+		return nil, fmt.Errorf("failed to convert providerRegistry to common.Address")
+	}
+	providerRegistryContract := bind.NewBoundContract(registryAddress, providerRegistryABI, client, client, client)
+
+	// Every registration emits a ProviderRegistered event
+	logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: big.NewInt(0),
+		Addresses: []common.Address{registryAddress},
+		Topics:    [][]common.Hash{{providerRegistryABI.Events["ProviderRegistered"].ID}},
+	})
 	if err != nil {
-		log.Fatalf("Failed to subscribe to new head: %v", err)
+		return nil, fmt.Errorf("failed to filter ProviderRegistered events: %v", err)
 	}
 
-	// Listen for new block headers and filter logs for the CommitmentStored event
-	for {
-		select {
-		case err := <-sub.Err():
-			log.Fatalf("Error with header subscription: %v", err)
-		case header := <-headers:
-			query := ethereum.FilterQuery{
-				Addresses: []common.Address{common.HexToAddress(preConfCommitmentStoreAddress)},
-				FromBlock: header.Number,
-				ToBlock:   header.Number,
-			}
+	var providers []Provider
+	for _, vLog := range logs {
+		var event struct {
+			Provider     common.Address
+			StakedAmount *big.Int
+		}
+		if err := providerRegistryContract.UnpackLog(&event, "ProviderRegistered", vLog); err != nil {
+			return nil, fmt.Errorf("failed to unpack ProviderRegistered event: %v", err)
+		}
 
-			logs := make(chan types.Log)
-			subLogs, err := client.SubscribeFilterLogs(context.Background(), query, logs)
-			if err != nil {
-				log.Printf("Failed to subscribe to logs: %v", err)
-				continue
-			}
+		var stakeResult []interface{}
+		if err := providerRegistryContract.Call(&bind.CallOpts{Context: ctx}, &stakeResult, "providerStakes", event.Provider); err != nil {
+			return nil, fmt.Errorf("failed to call providerStakes function: %v", err)
+		}
+		stake, ok := stakeResult[0].(*big.Int)
+		if !ok {
+			return nil, fmt.Errorf("failed to convert providerStakes to *big.Int")
+		}
 
-			// Process incoming logs
-			for {
-				select {
-				case err := <-subLogs.Err():
-					log.Printf("Error with log subscription: %v", err)
-					break
-				case vLog := <-logs:
-					var event CommitmentStoredEvent
-
-					// Unpack the log data into the CommitmentStoredEvent struct
-					err := contractAbi.UnpackIntoInterface(&event, "CommitmentStored", vLog.Data)
-					if err != nil {
-						log.Printf("Failed to unpack log data: %v", err)
-						continue
-					}
-
-					// Print event details
-					fmt.Printf("CommitmentStored Event: \n")
-					fmt.Printf("CommitmentIndex: %x\n", event.CommitmentIndex)
-					fmt.Printf("Bidder: %s\n", event.Bidder.Hex())
-					fmt.Printf("Commiter: %s\n", event.Commiter.Hex())
-					fmt.Printf("Bid: %d\n", event.Bid)
-					fmt.Printf("BlockNumber: %d\n", event.BlockNumber)
-					fmt.Printf("BidHash: %x\n", event.BidHash)
-					fmt.Printf("DecayStartTimeStamp: %d\n", event.DecayStartTimeStamp)
-					fmt.Printf("DecayEndTimeStamp: %d\n", event.DecayEndTimeStamp)
-					fmt.Printf("TxnHash: %s\n", event.TxnHash)
-					fmt.Printf("CommitmentHash: %x\n", event.CommitmentHash)
-					fmt.Printf("BidSignature: %x\n", event.BidSignature)
-					fmt.Printf("CommitmentSignature: %x\n", event.CommitmentSignature)
-					fmt.Printf("DispatchTimestamp: %d\n", event.DispatchTimestamp)
-					fmt.Printf("SharedSecretKey: %x\n", event.SharedSecretKey)
-				}
+		providers = append(providers, Provider{
+			Address:           event.Provider,
+			RegisteredStake:   event.StakedAmount,
+			Stake:             stake,
+			RegisteredAtBlock: vLog.BlockNumber,
+		})
+	}
+	return providers, nil
+}
+
+// Kinds of commitment settlements in the BidderRegistry contract.
+const (
+	SettlementPayment = "payment" // FundsRewarded, the bid was paid to the provider.
+	SettlementRefund  = "refund"  // FundsRetrieved, the bid was returned to the bidder.
+)
+
+// settlementDigestsPerQuery is the number of commitment digests filtered for in one log query.
+const settlementDigestsPerQuery = 200
+
+// Settlement is the settlement of a commitment in the BidderRegistry contract.
+type Settlement struct {
+	Kind     string         // SettlementPayment or SettlementRefund.
+	Digest   common.Hash    // The digest of the settled commitment.
+	Bidder   common.Address // The bidder that made the bid.
+	Provider common.Address // The provider that was paid, zero for refunds.
+	Window   *big.Int       // The bidding window the bid was charged to.
+	Amount   *big.Int       // The amount paid or returned, in wei.
+	Block    uint64         // The mev-commit chain block the settlement happened in.
+}
+
+// ListSettlements lists the payments and refunds of the given commitments, from their FundsRewarded and
+// FundsRetrieved events in the BidderRegistry contract.
+//
+// Parameters:
+// - ctx: The context for the requests.
+// - client: The client of the mev-commit chain.
+// - digests: The digests of the commitments.
+// - fromBlock: The first mev-commit chain block to search.
+//
+// Returns:
+// - The settlements, or an error if the events cannot be read.
+func ListSettlements(ctx context.Context, client *ethclient.Client, digests []common.Hash, fromBlock uint64) ([]Settlement, error) {
+	bidderRegistryABI, err := LoadABI("abi/BidderRegistry.abi")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ABI file: %v", err)
+	}
+	address := common.HexToAddress(bidderRegistryAddress)
+	bidderRegistryContract := bind.NewBoundContract(address, bidderRegistryABI, client, client, client)
+	kinds := map[common.Hash]string{
+		bidderRegistryABI.Events["FundsRewarded"].ID:  SettlementPayment,
+		bidderRegistryABI.Events["FundsRetrieved"].ID: SettlementRefund,
+	}
+	eventNames := map[string]string{SettlementPayment: "FundsRewarded", SettlementRefund: "FundsRetrieved"}
+
+	var settlements []Settlement
+	for start := 0; start < len(digests); start += settlementDigestsPerQuery {
+		// The commitment digest is the first indexed field of both events
+		logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(fromBlock),
+			Addresses: []common.Address{address},
+			Topics: [][]common.Hash{
+				{bidderRegistryABI.Events["FundsRewarded"].ID, bidderRegistryABI.Events["FundsRetrieved"].ID},
+				digests[start:min(start+settlementDigestsPerQuery, len(digests))],
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to filter settlement events: %v", err)
+		}
+
+		for _, vLog := range logs {
+			kind := kinds[vLog.Topics[0]]
+			var event struct {
+				CommitmentDigest [32]byte
+				Bidder           common.Address
+				Provider         common.Address
+				Window           *big.Int
+				Amount           *big.Int
+			}
+			if err := bidderRegistryContract.UnpackLog(&event, eventNames[kind], vLog); err != nil {
+				return nil, fmt.Errorf("failed to unpack %s event: %v", eventNames[kind], err)
 			}
+			settlements = append(settlements, Settlement{
+				Kind:     kind,
+				Digest:   event.CommitmentDigest,
+				Bidder:   event.Bidder,
+				Provider: event.Provider,
+				Window:   event.Window,
+				Amount:   event.Amount,
+				Block:    vLog.BlockNumber,
+			})
 		}
 	}
+	return settlements, nil
 }
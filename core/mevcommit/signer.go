@@ -0,0 +1,250 @@
+package mevcommit
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Signer signs transactions on behalf of a single account without exposing how the key is stored.
+type Signer interface {
+	// Address returns the address of the account the signer signs for.
+	Address() common.Address
+	// SignTx returns a signed copy of tx for the given chain ID.
+	SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// KeySigner signs transactions with a private key held in memory.
+type KeySigner struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+// NewKeySigner creates a signer for the given private key.
+//
+// Parameters:
+// - key: The ECDSA private key to sign with.
+//
+// Returns:
+// - A pointer to a KeySigner.
+func NewKeySigner(key *ecdsa.PrivateKey) *KeySigner {
+	return &KeySigner{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}
+}
+
+// NewKeystoreSigner decrypts a go-ethereum encrypted keystore JSON file and returns a signer for its key.
+//
+// Parameters:
+// - keyFile: The path to the encrypted keystore JSON file.
+// - passwordFile: The path to a file holding the keystore password. Trailing newlines are ignored.
+//
+// Returns:
+// - A pointer to a KeySigner, or an error if the keystore cannot be read or decrypted.
+func NewKeystoreSigner(keyFile, passwordFile string) (*KeySigner, error) {
+	password, err := readPassword(passwordFile)
+	if err != nil {
+		return nil, err
+	}
+	return decryptKeystore(keyFile, password)
+}
+
+// Address returns the address of the signing key.
+func (s *KeySigner) Address() common.Address {
+	return s.address
+}
+
+// SignTx signs tx with the private key using the latest signer for chainID.
+func (s *KeySigner) SignTx(_ context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), s.key)
+}
+
+// RemoteSigner signs transactions through an external signer such as Web3Signer or Clef over JSON-RPC.
+// Blob sidecars are never sent to the remote signer, they are attached to the signed transaction locally.
+type RemoteSigner struct {
+	client  *rpc.Client
+	address common.Address
+	method  string
+}
+
+// NewRemoteSigner connects to an external signer.
+//
+// Parameters:
+// - ctx: The context for dialing the signer.
+// - endpoint: The JSON-RPC endpoint of the signer.
+// - address: The account the signer signs for.
+// - method: The signing method, eth_signTransaction for Web3Signer or account_signTransaction for Clef.
+//
+// Returns:
+// - A pointer to a RemoteSigner, or an error if the connection fails.
+func NewRemoteSigner(ctx context.Context, endpoint string, address common.Address, method string) (*RemoteSigner, error) {
+	if method == "" {
+		method = "eth_signTransaction"
+	}
+	client, err := rpc.DialContext(ctx, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to remote signer: %w", err)
+	}
+	return &RemoteSigner{client: client, address: address, method: method}, nil
+}
+
+// Address returns the address of the remote account.
+func (s *RemoteSigner) Address() common.Address {
+	return s.address
+}
+
+// signTxArgs are the transaction arguments understood by the eth_signTransaction family of methods.
+type signTxArgs struct {
+	From                 common.Address  `json:"from"`
+	To                   *common.Address `json:"to,omitempty"`
+	Gas                  hexutil.Uint64  `json:"gas"`
+	GasPrice             *hexutil.Big    `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas,omitempty"`
+	MaxFeePerBlobGas     *hexutil.Big    `json:"maxFeePerBlobGas,omitempty"`
+	BlobVersionedHashes  []common.Hash   `json:"blobVersionedHashes,omitempty"`
+	Value                *hexutil.Big    `json:"value"`
+	Nonce                hexutil.Uint64  `json:"nonce"`
+	Data                 hexutil.Bytes   `json:"data"`
+	ChainID              *hexutil.Big    `json:"chainId"`
+}
+
+// SignTx asks the remote signer to sign tx and checks that the result was signed by the expected account.
+func (s *RemoteSigner) SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	args := signTxArgs{
+		From:    s.address,
+		To:      tx.To(),
+		Gas:     hexutil.Uint64(tx.Gas()),
+		Value:   (*hexutil.Big)(tx.Value()),
+		Nonce:   hexutil.Uint64(tx.Nonce()),
+		Data:    tx.Data(),
+		ChainID: (*hexutil.Big)(chainID),
+	}
+	switch tx.Type() {
+	case types.LegacyTxType, types.AccessListTxType:
+		args.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	default:
+		args.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
+	}
+	if tx.Type() == types.BlobTxType {
+		args.MaxFeePerBlobGas = (*hexutil.Big)(tx.BlobGasFeeCap())
+		args.BlobVersionedHashes = tx.BlobHashes()
+	}
+
+	// Web3Signer returns the raw transaction, Clef wraps it in an object next to the decoded transaction.
+	var result json.RawMessage
+	if err := s.client.CallContext(ctx, &result, s.method, args); err != nil {
+		return nil, fmt.Errorf("remote signer failed to sign transaction: %w", err)
+	}
+	var raw hexutil.Bytes
+	if err := json.Unmarshal(result, &raw); err != nil {
+		var wrapped struct {
+			Raw hexutil.Bytes `json:"raw"`
+		}
+		if err := json.Unmarshal(result, &wrapped); err != nil || len(wrapped.Raw) == 0 {
+			return nil, fmt.Errorf("unexpected remote signer response: %s", string(result))
+		}
+		raw = wrapped.Raw
+	}
+
+	signed := new(types.Transaction)
+	if err := signed.UnmarshalBinary(raw); err != nil {
+		return nil, fmt.Errorf("failed to decode signed transaction: %w", err)
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover signer of remote signed transaction: %w", err)
+	}
+	if sender != s.address || signed.Nonce() != tx.Nonce() {
+		return nil, fmt.Errorf("remote signer returned a transaction from %s with nonce %d, expected %s with nonce %d", sender, signed.Nonce(), s.address, tx.Nonce())
+	}
+
+	if sidecar := tx.BlobTxSidecar(); sidecar != nil && signed.BlobTxSidecar() == nil {
+		signed = signed.WithBlobTxSidecar(sidecar)
+	}
+	return signed, nil
+}
+
+// LoadKeystoreAccounts decrypts a keystore JSON file, or every keystore file in a directory, with the
+// password stored in passwordFile and authenticates each account.
+//
+// Parameters:
+// - path: The path to a keystore JSON file or a keystore directory.
+// - passwordFile: The path to a file holding the keystore password.
+//
+// Returns:
+// - A slice of AuthAcct structs, or an error if a keystore file cannot be decrypted.
+func LoadKeystoreAccounts(path, passwordFile string) ([]AuthAcct, error) {
+	password, err := readPassword(passwordFile)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat keystore path: %w", err)
+	}
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read keystore directory: %w", err)
+		}
+		files = files[:0]
+		for _, entry := range entries {
+			// Skip editor backups and hidden files like the keystore package does.
+			if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") || strings.HasSuffix(entry.Name(), "~") {
+				continue
+			}
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+
+	var accounts []AuthAcct
+	for _, file := range files {
+		signer, err := decryptKeystore(file, password)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, NewAuthAcct(signer))
+	}
+	if len(accounts) == 0 {
+		return nil, errors.New("no keystore files found")
+	}
+
+	return accounts, nil
+}
+
+// decryptKeystore decrypts a single keystore JSON file.
+func decryptKeystore(keyFile, password string) (*KeySigner, error) {
+	keyJSON, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore file: %w", err)
+	}
+	key, err := keystore.DecryptKey(keyJSON, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt keystore file %s: %w", filepath.Base(keyFile), err)
+	}
+	return NewKeySigner(key.PrivateKey), nil
+}
+
+// readPassword reads a password file, dropping trailing newlines.
+func readPassword(passwordFile string) (string, error) {
+	data, err := os.ReadFile(passwordFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
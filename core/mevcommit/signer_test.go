@@ -0,0 +1,277 @@
+package mevcommit
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/holiman/uint256"
+)
+
+// newSignerStub starts a remote signer that answers every signing request with respond. It is closed when
+// the test ends.
+func newSignerStub(t *testing.T, method string, respond func(args signTxArgs) interface{}) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params []signTxArgs    `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+			return
+		}
+		if req.Method != method || len(req.Params) != 1 {
+			t.Errorf("unexpected request %s with %d params", req.Method, len(req.Params))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": respond(req.Params[0])})
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// signArgs signs the transaction described by args with key, as a remote signer does. The nonce is shifted
+// by nonceOffset.
+func signArgs(t *testing.T, args signTxArgs, key *ecdsa.PrivateKey, nonceOffset uint64) hexutil.Bytes {
+	t.Helper()
+	chainID := args.ChainID.ToInt()
+	var inner types.TxData
+	if len(args.BlobVersionedHashes) > 0 {
+		inner = &types.BlobTx{
+			ChainID:    uint256.MustFromBig(chainID),
+			Nonce:      uint64(args.Nonce) + nonceOffset,
+			GasTipCap:  uint256.MustFromBig(args.MaxPriorityFeePerGas.ToInt()),
+			GasFeeCap:  uint256.MustFromBig(args.MaxFeePerGas.ToInt()),
+			Gas:        uint64(args.Gas),
+			To:         *args.To,
+			Value:      uint256.MustFromBig(args.Value.ToInt()),
+			Data:       args.Data,
+			BlobFeeCap: uint256.MustFromBig(args.MaxFeePerBlobGas.ToInt()),
+			BlobHashes: args.BlobVersionedHashes,
+		}
+	} else {
+		inner = &types.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     uint64(args.Nonce) + nonceOffset,
+			GasTipCap: args.MaxPriorityFeePerGas.ToInt(),
+			GasFeeCap: args.MaxFeePerGas.ToInt(),
+			Gas:       uint64(args.Gas),
+			To:        args.To,
+			Value:     args.Value.ToInt(),
+			Data:      args.Data,
+		}
+	}
+	signed, err := types.SignNewTx(key, types.LatestSignerForChainID(chainID), inner)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	raw, err := signed.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	return raw
+}
+
+func TestRemoteSignerSignTx(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	otherKey, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	chainID := big.NewInt(17000)
+	to := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+
+	dynamicTx := types.NewTx(&types.DynamicFeeTx{ChainID: chainID, Nonce: 3, GasTipCap: big.NewInt(2), GasFeeCap: big.NewInt(30), Gas: 21000, To: &to, Value: big.NewInt(1)})
+	sidecar := &types.BlobTxSidecar{
+		Blobs:       []kzg4844.Blob{{}},
+		Commitments: []kzg4844.Commitment{{}},
+		Proofs:      []kzg4844.Proof{{}},
+	}
+	blobTx := types.NewTx(&types.BlobTx{
+		ChainID:    uint256.MustFromBig(chainID),
+		Nonce:      4,
+		GasTipCap:  uint256.NewInt(2),
+		GasFeeCap:  uint256.NewInt(30),
+		Gas:        21000,
+		To:         to,
+		Value:      uint256.NewInt(0),
+		BlobFeeCap: uint256.NewInt(5),
+		BlobHashes: sidecar.BlobHashes(),
+		Sidecar:    sidecar,
+	})
+
+	tests := []struct {
+		name    string
+		method  string
+		tx      *types.Transaction
+		respond func(t *testing.T, args signTxArgs) interface{}
+		wantErr string
+	}{
+		{
+			name:   "web3signer raw result",
+			method: "eth_signTransaction",
+			tx:     dynamicTx,
+			respond: func(t *testing.T, args signTxArgs) interface{} {
+				return signArgs(t, args, key, 0)
+			},
+		},
+		{
+			name:   "clef wrapped result",
+			method: "account_signTransaction",
+			tx:     dynamicTx,
+			respond: func(t *testing.T, args signTxArgs) interface{} {
+				return map[string]interface{}{"raw": signArgs(t, args, key, 0), "tx": map[string]string{}}
+			},
+		},
+		{
+			name:   "blob sidecar reattached",
+			method: "eth_signTransaction",
+			tx:     blobTx,
+			respond: func(t *testing.T, args signTxArgs) interface{} {
+				if args.MaxFeePerBlobGas == nil || len(args.BlobVersionedHashes) != 1 {
+					t.Errorf("blob fields not sent: %+v", args)
+				}
+				return signArgs(t, args, key, 0)
+			},
+		},
+		{
+			name:   "wrong sender",
+			method: "eth_signTransaction",
+			tx:     dynamicTx,
+			respond: func(t *testing.T, args signTxArgs) interface{} {
+				return signArgs(t, args, otherKey, 0)
+			},
+			wantErr: "remote signer returned a transaction from",
+		},
+		{
+			name:   "wrong nonce",
+			method: "eth_signTransaction",
+			tx:     dynamicTx,
+			respond: func(t *testing.T, args signTxArgs) interface{} {
+				return signArgs(t, args, key, 1)
+			},
+			wantErr: "remote signer returned a transaction from",
+		},
+		{
+			name:   "unexpected result",
+			method: "eth_signTransaction",
+			tx:     dynamicTx,
+			respond: func(t *testing.T, args signTxArgs) interface{} {
+				return map[string]string{"signature": "0x00"}
+			},
+			wantErr: "unexpected remote signer response",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := newSignerStub(t, tt.method, func(args signTxArgs) interface{} { return tt.respond(t, args) })
+			signer, err := NewRemoteSigner(context.Background(), url, address, tt.method)
+			if err != nil {
+				t.Fatalf("NewRemoteSigner: %v", err)
+			}
+
+			signed, err := signer.SignTx(context.Background(), tt.tx, chainID)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("SignTx() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SignTx: %v", err)
+			}
+			sender, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+			if err != nil || sender != address {
+				t.Errorf("sender = %s, %v, want %s", sender, err, address)
+			}
+			if signed.Nonce() != tt.tx.Nonce() {
+				t.Errorf("nonce = %d, want %d", signed.Nonce(), tt.tx.Nonce())
+			}
+			if tt.tx.BlobTxSidecar() != nil && signed.BlobTxSidecar() != tt.tx.BlobTxSidecar() {
+				t.Errorf("blob sidecar was not reattached")
+			}
+		})
+	}
+}
+
+// writeKeystore imports key into a keystore directory encrypted with password and returns the key file.
+func writeKeystore(t *testing.T, dir string, key *ecdsa.PrivateKey, password string) string {
+	t.Helper()
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	account, err := ks.ImportECDSA(key, password)
+	if err != nil {
+		t.Fatalf("failed to import key: %v", err)
+	}
+	return account.URL.Path
+}
+
+func TestLoadKeystoreAccounts(t *testing.T) {
+	dir := t.TempDir()
+	keyDir := filepath.Join(dir, "keystore")
+	first, _ := crypto.GenerateKey()
+	second, _ := crypto.GenerateKey()
+	firstFile := writeKeystore(t, keyDir, first, "secret")
+	writeKeystore(t, keyDir, second, "secret")
+	if err := os.WriteFile(filepath.Join(keyDir, ".hidden"), []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	passwordFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(passwordFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	wrongPasswordFile := filepath.Join(dir, "wrong")
+	if err := os.WriteFile(wrongPasswordFile, []byte("guess\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("file", func(t *testing.T) {
+		accounts, err := LoadKeystoreAccounts(firstFile, passwordFile)
+		if err != nil {
+			t.Fatalf("LoadKeystoreAccounts: %v", err)
+		}
+		if len(accounts) != 1 || accounts[0].Address != crypto.PubkeyToAddress(first.PublicKey) {
+			t.Fatalf("accounts = %+v, want %s", accounts, crypto.PubkeyToAddress(first.PublicKey))
+		}
+	})
+	t.Run("directory", func(t *testing.T) {
+		accounts, err := LoadKeystoreAccounts(keyDir, passwordFile)
+		if err != nil {
+			t.Fatalf("LoadKeystoreAccounts: %v", err)
+		}
+		got := map[common.Address]bool{}
+		for _, acct := range accounts {
+			got[acct.Address] = true
+		}
+		if len(accounts) != 2 || !got[crypto.PubkeyToAddress(first.PublicKey)] || !got[crypto.PubkeyToAddress(second.PublicKey)] {
+			t.Fatalf("accounts = %+v, want both keys", accounts)
+		}
+	})
+	t.Run("wrong password", func(t *testing.T) {
+		if _, err := LoadKeystoreAccounts(firstFile, wrongPasswordFile); !errors.Is(err, keystore.ErrDecrypt) {
+			t.Fatalf("LoadKeystoreAccounts() error = %v, want %v", err, keystore.ErrDecrypt)
+		}
+		if _, err := NewKeystoreSigner(firstFile, wrongPasswordFile); !errors.Is(err, keystore.ErrDecrypt) {
+			t.Fatalf("NewKeystoreSigner() error = %v, want %v", err, keystore.ErrDecrypt)
+		}
+	})
+	t.Run("empty directory", func(t *testing.T) {
+		if _, err := LoadKeystoreAccounts(t.TempDir(), passwordFile); err == nil {
+			t.Fatal("LoadKeystoreAccounts() succeeded without keystore files")
+		}
+	})
+}
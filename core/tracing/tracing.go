@@ -0,0 +1,139 @@
+// Package tracing provides OpenTelemetry spans for the stages of a slot, from the receipt of a new head
+// to the receipt checks of the transactions sent in it. Spans are dropped unless an OTLP exporter is set up.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans of this module.
+const instrumentationName = "github.com/primev/preconf_blob_bidder"
+
+// Attribute keys shared by the spans of all stages.
+const (
+	SlotKey     = attribute.Key("slot")     // The number of the head block that started the slot.
+	BlockKey    = attribute.Key("block")    // The block a transaction, bundle or bid targets.
+	TxHashKey   = attribute.Key("tx.hash")  // The hash of the transaction being processed.
+	EndpointKey = attribute.Key("endpoint") // The endpoint a request is sent to.
+)
+
+// Slot returns the slot attribute for the given head block number.
+func Slot(number uint64) attribute.KeyValue {
+	return SlotKey.Int64(int64(number))
+}
+
+// Block returns the target block attribute.
+func Block(number uint64) attribute.KeyValue {
+	return BlockKey.Int64(int64(number))
+}
+
+// TxHash returns the transaction hash attribute.
+func TxHash(hash common.Hash) attribute.KeyValue {
+	return TxHashKey.String(hash.Hex())
+}
+
+// Endpoint returns the endpoint attribute. Only the host is kept, so that API keys in paths and queries
+// do not end up in traces.
+func Endpoint(endpoint string) attribute.KeyValue {
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		endpoint = u.Host
+	}
+	return EndpointKey.String(endpoint)
+}
+
+// Start starts a span as a child of the span in ctx.
+//
+// Parameters:
+// - ctx: The context holding the parent span, if any.
+// - name: The name of the stage.
+// - attrs: The attributes of the span.
+//
+// Returns:
+// - The context holding the new span and the span, which must be ended with End.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartSlot starts the root span of a slot. The span starts at the timestamp of the head block, so that
+// it shows how much of the slot passed before the head was received and how the rest of it was spent.
+//
+// Parameters:
+// - ctx: The parent context, whose span is not used as parent.
+// - header: The head block that started the slot.
+//
+// Returns:
+// - The context holding the slot span and the span, which must be ended with End.
+func StartSlot(ctx context.Context, header *types.Header) (context.Context, trace.Span) {
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, "slot",
+		trace.WithNewRoot(),
+		trace.WithTimestamp(time.Unix(int64(header.Time), 0)),
+		trace.WithAttributes(Slot(header.Number.Uint64()), attribute.String("block.hash", header.Hash().Hex())),
+	)
+	span.AddEvent("head received")
+	return ctx, span
+}
+
+// End ends a span, marking it as failed when err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Setup installs an OTLP/HTTP exporter as the destination of all spans. Without an endpoint spans are
+// not recorded at all and the returned shutdown function does nothing.
+//
+// Parameters:
+// - ctx: The context for creating the exporter.
+// - endpoint: The OTLP/HTTP collector, as host:port or as an http or https URL. Empty disables tracing.
+// - serviceName: The service name the spans are reported under.
+//
+// Returns:
+// - A function that flushes the pending spans and stops the exporter, or an error if the endpoint is invalid.
+func Setup(ctx context.Context, endpoint, serviceName string) (func(context.Context) error, error) {
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		switch u.Scheme {
+		case "http":
+			opts = []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host), otlptracehttp.WithInsecure()}
+		case "https":
+			opts = []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host)}
+		default:
+			return nil, fmt.Errorf("unsupported otlp endpoint scheme %q", u.Scheme)
+		}
+		if u.Path != "" && u.Path != "/" {
+			opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+		}
+	}
+
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create otlp exporter: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
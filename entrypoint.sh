@@ -1,8 +1,19 @@
 #!/bin/sh
 
-/app/bidder				\
+# Accounts are loaded from an encrypted keystore or a remote signer, never from a raw key.
+if [ -n "${REMOTE_SIGNER_URL}" ]; then
+    SIGNER_FLAGS="--remote-signer ${REMOTE_SIGNER_URL} --remote-signer-accounts ${REMOTE_SIGNER_ACCOUNTS} --remote-signer-method ${REMOTE_SIGNER_METHOD:-eth_signTransaction}"
+else
+    SIGNER_FLAGS="--keystore ${KEYSTORE_PATH} --password-file ${KEYSTORE_PASSWORD_FILE}"
+fi
+
+/app/bidder send-blob			\
+    --config=${CONFIG_FILE}		\
     --rpc-endpoints ${RPC_ENDPOINTS} 	\
     --ws-endpoint ${WS_ENDPOINT}	\
-    --privatekey ${PRIVATE_KEY}		\
+    ${SIGNER_FLAGS}			\
+    --metrics-addr ${METRICS_ADDR:-:9090}	\
+    --otlp-endpoint=${OTLP_ENDPOINT}	\
+    --log-fmt ${LOG_FMT:-text}		\
+    --log-level ${LOG_LEVEL:-info}	\
     --use-payload ${USE_PAYLOAD}
-